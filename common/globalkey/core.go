@@ -6,6 +6,12 @@ const (
 	// cache:phone_code:login:195xxx
 	PhoneCodeCachePrefix     = "cache:phone_code:"
 	ArticleFirstCachePage    = "cache:article:first_page:"
+	HotArticleCacheKey       = "cache:article:hot"
 	DraftArtCachePrefix      = "cache:article:draft:"
 	PublishedArtCachedPrefix = "cache:article:pub:"
+	SmsTokenCachePrefix      = "cache:sms_token:"
+	// sms:token_usage:{1}:202401, 同一个 token 的 key 在同一个 slot
+	SmsTokenUsagePrefix = "sms:token_usage:"
+	// CacheInvalidateChannel 本地缓存失效通知的频道
+	CacheInvalidateChannel = "cache:invalidate"
 )
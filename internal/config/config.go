@@ -1,11 +1,35 @@
 package config
 
+import "time"
+
 type Config struct {
-	DB     DB     `yaml:"db"`
-	Redis  Redis  `yaml:"redis"`
-	Wechat Wechat `yaml:"wechat"`
-	Ding   Ding   `yaml:"ding"`
-	Kafka  Kafka  `yaml:"kafka"`
+	Server  Server  `yaml:"server"`
+	DB      DB      `yaml:"db"`
+	Redis   Redis   `yaml:"redis"`
+	Wechat  Wechat  `yaml:"wechat"`
+	Ding    Ding    `yaml:"ding"`
+	Kafka   Kafka   `yaml:"kafka"`
+	Metrics Metrics `yaml:"metrics"`
+	Trace   Trace   `yaml:"trace"`
+	Article Article `yaml:"article"`
+	OSS     OSS     `yaml:"oss"`
+	Media   Media   `yaml:"media"`
+	Code    Code    `yaml:"code"`
+	SMS     SMS     `yaml:"sms"`
+	// LocalCache 在 redis 前面加一层本地缓存
+	LocalCache LocalCache `yaml:"localCache"`
+	Admin      Admin      `yaml:"admin"`
+}
+
+// Admin 管理后台
+type Admin struct {
+	// Uids 可以访问管理后台的用户
+	Uids []int64 `yaml:"uids"`
+}
+
+type Server struct {
+	// ShutdownTimeout 优雅退出的最长等待时间, 比如 30s
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
 }
 
 type DB struct {
@@ -36,8 +60,195 @@ type Mongo struct {
 	Username string `yaml:"username"`
 	Password string `yaml:"password"`
 	URI      string `yaml:"uri"`
+	// Database 默认 redbook
+	Database string `yaml:"database"`
+	// NodeId 雪花算法的节点 id, 多实例部署时每个实例要不同
+	NodeId int64 `yaml:"nodeId"`
 }
 
 type Kafka struct {
 	Addrs []string `yaml:"addrs"`
 }
+
+type Metrics struct {
+	// Addr prometheus 拉取指标的地址, 默认 :8081
+	Addr string `yaml:"addr"`
+}
+
+type Trace struct {
+	// Exporter 链路数据导出到哪里: stdout, file, zipkin, 为空时不采集
+	Exporter string `yaml:"exporter"`
+	// File exporter 为 file 时写入的文件
+	File string `yaml:"file"`
+	// Endpoint exporter 为 zipkin 时的上报地址, 比如 http://localhost:9411/api/v2/spans
+	Endpoint string `yaml:"endpoint"`
+	// SampleRatio 采样比例, 取值 (0, 1], 默认全部采样
+	SampleRatio float64 `yaml:"sampleRatio"`
+}
+
+type Article struct {
+	// Storage 文章的存储: gorm(默认), mongo, s3.
+	// s3 只把线上库的正文放到对象存储, 其余数据仍然在 mysql
+	Storage  string   `yaml:"storage"`
+	Revision Revision `yaml:"revision"`
+	Trash    Trash    `yaml:"trash"`
+}
+
+// Trash 回收站里的文章超过 Retention 之后彻底删除, 默认 720h
+type Trash struct {
+	Retention time.Duration `yaml:"retention"`
+}
+
+// Revision 历史版本的保留策略: 超过 MaxAge 的版本会被删除, 但每篇文章至少保留最新的 Keep 个
+type Revision struct {
+	// MaxAge 默认 720h
+	MaxAge time.Duration `yaml:"maxAge"`
+	// Keep 默认 20
+	Keep int `yaml:"keep"`
+}
+
+type OSS struct {
+	// Provider 对象存储的实现: s3(默认), local.
+	// local 把对象保存在本地目录 Dir, 下载链接由服务自己的 /objects 路由提供, 用于本地开发
+	Provider string `yaml:"provider"`
+	Bucket   string `yaml:"bucket"`
+	Region   string `yaml:"region"`
+	Endpoint string `yaml:"endpoint"`
+	// 访问密钥
+	AccessKeyId     string `yaml:"accessKeyId"`
+	SecretAccessKey string `yaml:"secretAccessKey"`
+
+	Dir string `yaml:"dir"`
+	// BaseURL local 下载链接的前缀, 比如 http://localhost:8080/objects
+	BaseURL string `yaml:"baseURL"`
+	// Secret local 下载链接的签名密钥
+	Secret string `yaml:"secret"`
+}
+
+type Media struct {
+	// MaxSize 上传图片的最大字节数, 默认 10MB
+	MaxSize int64 `yaml:"maxSize"`
+	// GCGrace 没有被引用的图片多久之后回收, 默认 24h
+	GCGrace time.Duration `yaml:"gcGrace"`
+}
+
+// LocalCache 每种缓存单独开启, 一个实例修改缓存时通过 redis 的 Channel 通知其他实例删除本地缓存
+type LocalCache struct {
+	// Channel 默认 cache:invalidate
+	Channel     string          `yaml:"channel"`
+	User        LocalCacheEntry `yaml:"user"`
+	Article     LocalCacheEntry `yaml:"article"`
+	Interactive LocalCacheEntry `yaml:"interactive"`
+}
+
+type LocalCacheEntry struct {
+	Enabled bool `yaml:"enabled"`
+	// Size 最多缓存的 key 的数量, 默认 10000
+	Size int `yaml:"size"`
+	// TTL 默认 1m, 丢失失效通知时最多读到这么久之前的数据
+	TTL time.Duration `yaml:"ttl"`
+}
+
+type Code struct {
+	// Cache 验证码保存在哪里: redis(默认), local.
+	// local 保存在进程内, 只能用于单实例部署和测试
+	Cache string `yaml:"cache"`
+	// LocalSize local 最多保存的手机号数量, 默认 100000
+	LocalSize int `yaml:"localSize"`
+	// Template 验证码短信的模板, 配置了 sms.templates 时默认 login_code, 否则默认 1
+	Template string `yaml:"template"`
+}
+
+// SMS 配置了多个服务商时按照 Failover 切换, 一个都没有配置时只打印短信内容
+type SMS struct {
+	// Failover 切换策略: round_robin(默认) 轮询, 失败时换下一个; timeout 连续超时之后切换
+	Failover string `yaml:"failover"`
+	// TimeoutThreshold timeout 策略连续超时几次之后切换, 默认 3
+	TimeoutThreshold int32    `yaml:"timeoutThreshold"`
+	Tencent          Tencent  `yaml:"tencent"`
+	Aliyun           Aliyun   `yaml:"aliyun"`
+	Async            SMSAsync `yaml:"async"`
+	Auth             SMSAuth  `yaml:"auth"`
+	// DefaultLocale 模板的默认语言, 默认 zh-CN
+	DefaultLocale string `yaml:"defaultLocale"`
+	// Templates 为空时模板 id 直接传给服务商
+	Templates []SMSTemplate `yaml:"templates"`
+}
+
+type SMSTemplate struct {
+	Name   string             `yaml:"name"`
+	Params []SMSTemplateParam `yaml:"params"`
+	// Locales key 是语言, 比如 zh-CN
+	Locales map[string]SMSTemplateLocale `yaml:"locales"`
+}
+
+type SMSTemplateParam struct {
+	Name   string `yaml:"name"`
+	MinLen int    `yaml:"minLen"`
+	MaxLen int    `yaml:"maxLen"`
+	Digits bool   `yaml:"digits"`
+}
+
+type SMSTemplateLocale struct {
+	// Content 预览用, 参数写成 {name}
+	Content string `yaml:"content"`
+	// Providers key 是服务商, 比如 tencent
+	Providers map[string]SMSProviderTemplate `yaml:"providers"`
+}
+
+type SMSProviderTemplate struct {
+	Id string `yaml:"id"`
+	// Params 服务商模板的参数顺序, 默认和模板一致
+	Params []string `yaml:"params"`
+}
+
+// SMSAuth 业务方凭证的签名密钥, 为空时不开放业务方发送短信和凭证管理的接口
+type SMSAuth struct {
+	Key string `yaml:"key"`
+}
+
+// SMSAsync 服务商响应变慢或者出错变多时转为异步发送, 没有配置的值使用默认值.
+// 管理后台的群发和定时发送也是异步短信, 所以后台发送任务总是会运行
+type SMSAsync struct {
+	// Enabled 验证码短信是否可以转为异步
+	Enabled bool `yaml:"enabled"`
+	// RetryMax 异步发送最多重试几次, 默认 3
+	RetryMax int64 `yaml:"retryMax"`
+	// Window 统计的时间窗口, 默认 10s
+	Window time.Duration `yaml:"window"`
+	// LatencyThreshold 平均响应时间超过它就转异步, 默认 500ms
+	LatencyThreshold time.Duration `yaml:"latencyThreshold"`
+	// ErrRateThreshold 错误率超过它就转异步, 默认 0.3
+	ErrRateThreshold float64 `yaml:"errRateThreshold"`
+	// MinRequests 窗口内请求数少于它时不切换, 默认 10
+	MinRequests int `yaml:"minRequests"`
+	// CoolDown 转异步之后至少多久才能恢复同步, 默认 1m
+	CoolDown time.Duration `yaml:"coolDown"`
+	// ProbeRatio 异步期间继续同步发送的比例, 用来判断服务商是否恢复, 默认 0.01
+	ProbeRatio float64 `yaml:"probeRatio"`
+	// MinProbes 冷却之后窗口内至少有几个探测的请求才判断是否恢复, 不够时直接同步发送来探测, 默认 3
+	MinProbes int `yaml:"minProbes"`
+	// Workers 后台发送异步短信的协程数, 默认 4
+	Workers int `yaml:"workers"`
+	// BatchSize 每次抢占的异步短信数, 默认 10
+	BatchSize int `yaml:"batchSize"`
+	// RateLimit 后台每分钟最多发送多少条异步短信, 和验证码的限流分开计算, 默认 600
+	RateLimit int `yaml:"rateLimit"`
+}
+
+type Tencent struct {
+	SecretId  string `yaml:"secretId"`
+	SecretKey string `yaml:"secretKey"`
+	Region    string `yaml:"region"`
+	AppId     string `yaml:"appId"`
+	SignName  string `yaml:"signName"`
+}
+
+// Aliyun 阿里云的模板参数按名字传, 名字来自 sms.templates 的配置
+type Aliyun struct {
+	AccessKeyId     string `yaml:"accessKeyId"`
+	AccessKeySecret string `yaml:"accessKeySecret"`
+	SignName        string `yaml:"signName"`
+	// Endpoint 默认 https://dysmsapi.aliyuncs.com
+	Endpoint string `yaml:"endpoint"`
+}
@@ -1,5 +1,7 @@
 package domain
 
+import "time"
+
 type ArticleStatus uint8
 
 const (
@@ -9,6 +11,12 @@ const (
 	ArticleStatusUnPublished
 	ArticleStatusPublished
 	ArticleStatusPrivate
+	// ArticleStatusScheduled 定时发表, 到 PublishAt 之后自动发表
+	ArticleStatusScheduled
+	// ArticleStatusTrashed 放进回收站, 可以恢复, 超过保留时间后彻底删除
+	ArticleStatusTrashed
+	// ArticleStatusPurging 正在彻底删除, 不能再恢复. 清理失败时保持这个状态, 下一次继续清理
+	ArticleStatusPurging
 )
 
 func (s ArticleStatus) ToUint8() uint8 {
@@ -20,7 +28,25 @@ type Article struct {
 	Tittle   string
 	Content  string
 	AuthorId int64
+	// Cover 封面, Images 正文里按顺序出现的图片, 都是 Media.Key
+	Cover  string
+	Images []string
 	ArticleStatus
+	// PublishAt 定时发表的时间, 只有 ArticleStatusScheduled 时有意义
+	PublishAt time.Time
+	Ctime     time.Time
+	Utime     time.Time
+	// Dtime 放进回收站的时间, 只有 ArticleStatusTrashed 时有意义
+	Dtime time.Time
+}
+
+// MediaKeys 文章引用的所有图片
+func (a Article) MediaKeys() []string {
+	keys := make([]string, 0, len(a.Images)+1)
+	if a.Cover != "" {
+		keys = append(keys, a.Cover)
+	}
+	return append(keys, a.Images...)
 }
 
 func (a Article) Abstract() string {
@@ -0,0 +1,44 @@
+package domain
+
+import "time"
+
+type RevisionKind uint8
+
+const (
+	RevisionKindUnknown RevisionKind = iota
+	// RevisionKindDraft 保存草稿
+	RevisionKindDraft
+	// RevisionKindPublish 发表
+	RevisionKindPublish
+)
+
+func (k RevisionKind) ToUint8() uint8 {
+	return uint8(k)
+}
+
+// ArticleRevision 文章每一次保存或发表时的快照, 创建之后不会再修改
+type ArticleRevision struct {
+	Id        int64
+	ArticleId int64
+	// Operator 谁做的修改
+	Operator int64
+	Kind     RevisionKind
+	Tittle   string
+	Content  string
+	Cover    string
+	Images   []string
+	Ctime    time.Time
+}
+
+// RevisionDiff 两个版本之间的差异, 正文按行比较
+type RevisionDiff struct {
+	From    ArticleRevision
+	To      ArticleRevision
+	Content []DiffLine
+}
+
+type DiffLine struct {
+	// Op = 不变, + 新增, - 删除
+	Op   string
+	Text string
+}
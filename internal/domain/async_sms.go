@@ -1,8 +1,50 @@
 package domain
 
+import "time"
+
+type AsyncSmsStatus uint8
+
+const (
+	// AsyncSmsStatusWaiting 等待发送, 包括失败之后等待重试
+	AsyncSmsStatusWaiting AsyncSmsStatus = iota
+	AsyncSmsStatusSuccess
+	// AsyncSmsStatusFailed 重试次数用完, 不会再发送
+	AsyncSmsStatusFailed
+	// AsyncSmsStatusSending 已经被抢占, 正在发送
+	AsyncSmsStatusSending
+)
+
+func (s AsyncSmsStatus) ToUint8() uint8 {
+	return uint8(s)
+}
+
+func (s AsyncSmsStatus) String() string {
+	switch s {
+	case AsyncSmsStatusWaiting:
+		return "waiting"
+	case AsyncSmsStatusSuccess:
+		return "success"
+	case AsyncSmsStatusFailed:
+		return "failed"
+	case AsyncSmsStatusSending:
+		return "sending"
+	default:
+		return "unknown"
+	}
+}
+
 type AsyncSms struct {
-	Id       int64
+	Id int64
+	// RetryCnt 已经发送了几次
+	RetryCnt int64
+	// RetryMax 最多发送几次
 	RetryMax int64
+	Status   AsyncSmsStatus
+	// LastErr 最近一次发送失败的原因
+	LastErr     string
+	NextRetryAt time.Time
+	Ctime       time.Time
+	Utime       time.Time
 	AsyncSmsConfig
 }
 
@@ -11,3 +53,24 @@ type AsyncSmsConfig struct {
 	Args    []string
 	Numbers []string
 }
+
+// AsyncSmsAttempt 异步短信的一次发送记录
+type AsyncSmsAttempt struct {
+	SmsId int64
+	// Attempt 第几次发送, 从 1 开始
+	Attempt int64
+	// Err 为空表示发送成功
+	Err     string
+	Latency time.Duration
+	Ctime   time.Time
+}
+
+// AsyncSmsQuery 管理后台查询异步短信的条件, 零值表示不按这个条件过滤
+type AsyncSmsQuery struct {
+	Status *AsyncSmsStatus
+	// Number 发送给这个手机号的短信
+	Number string
+	// 创建时间在 [Start, End) 之间
+	Start time.Time
+	End   time.Time
+}
@@ -0,0 +1,17 @@
+package domain
+
+// Collection 收藏夹, Id 为 0 代表默认收藏夹
+type Collection struct {
+	Id   int64
+	Uid  int64
+	Name string
+}
+
+// CollectItem 收藏夹里的一条收藏
+type CollectItem struct {
+	Cid   int64
+	Biz   string
+	BizId int64
+	// 收藏时间, 毫秒
+	Ctime int64
+}
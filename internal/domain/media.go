@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+// Media 用户上传的图片, Key 和 ThumbKey 是对象存储里的 key
+type Media struct {
+	Id       int64
+	Uid      int64
+	Key      string
+	ThumbKey string
+	MimeType string
+	Size     int64
+	Width    int
+	Height   int
+	// ArticleId 引用这张图片的文章, 0 表示还没有被引用
+	ArticleId int64
+	Ctime     time.Time
+	Utime     time.Time
+}
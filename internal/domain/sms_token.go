@@ -0,0 +1,33 @@
+package domain
+
+import "time"
+
+// SmsToken 业务方从管理后台申请的短信凭证, 只能使用绑定的模板
+type SmsToken struct {
+	Id int64
+	// Biz 业务方
+	Biz  string
+	Tpls []string
+	// MonthlyQuota 每个自然月最多发送的号码数, 0 表示不限制
+	MonthlyQuota int64
+	// RatePerMinute 每分钟最多请求几次, 0 表示不限制
+	RatePerMinute int64
+	Revoked       bool
+	// Creator 签发的管理员
+	Creator int64
+	// ExpireAt 零值表示不过期
+	ExpireAt time.Time
+	Ctime    time.Time
+	Utime    time.Time
+	// Used 本月已经用掉的额度, 只有查询列表时才有
+	Used int64
+}
+
+func (t SmsToken) AllowTpl(tplId string) bool {
+	for _, tpl := range t.Tpls {
+		if tpl == tplId {
+			return true
+		}
+	}
+	return false
+}
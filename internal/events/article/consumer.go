@@ -1 +1,96 @@
 package article
+
+import (
+	"context"
+	"errors"
+	"github.com/IBM/sarama"
+	"github.com/lutcoding/redbook/internal/repository"
+	"github.com/lutcoding/redbook/pkg/saramax"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	topicReadEventRetry      = "article_read_retry"
+	topicReadEventDeadLetter = "article_read_dlq"
+)
+
+// InteractiveReadEventConsumer 消费文章阅读事件, 攒批后批量更新阅读数
+type InteractiveReadEventConsumer struct {
+	client sarama.Client
+	repo   repository.InteractiveRepository
+
+	cg       sarama.ConsumerGroup
+	producer sarama.SyncProducer
+	done     chan struct{}
+}
+
+func NewInteractiveReadEventConsumer(client sarama.Client,
+	repo repository.InteractiveRepository) *InteractiveReadEventConsumer {
+	return &InteractiveReadEventConsumer{
+		client: client,
+		repo:   repo,
+	}
+}
+
+func (c *InteractiveReadEventConsumer) Start(ctx context.Context) error {
+	cg, err := sarama.NewConsumerGroupFromClient("interactive", c.client)
+	if err != nil {
+		return err
+	}
+	producer, err := sarama.NewSyncProducerFromClient(c.client)
+	if err != nil {
+		_ = cg.Close()
+		return err
+	}
+	c.cg, c.producer, c.done = cg, producer, make(chan struct{})
+	handler := saramax.NewBatchHandler[ReadEvent](c.consume).
+		SetBatchSize(10).
+		SetBatchDuration(time.Second).
+		SetRetry(saramax.Retry{
+			MaxRetries:      3,
+			InitialInterval: time.Millisecond * 100,
+			MaxInterval:     time.Second,
+			Producer:        saramax.NewMetricsSyncProducer(producer),
+			RetryTopic:      topicReadEventRetry,
+			DeadLetterTopic: topicReadEventDeadLetter,
+		})
+	go func() {
+		defer close(c.done)
+		// 发生 rebalance 之后 Consume 会返回, 需要重新加入消费组
+		for ctx.Err() == nil {
+			err := cg.Consume(ctx, []string{TopicReadEvent, topicReadEventRetry}, handler)
+			if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+				return
+			}
+			if err != nil {
+				zap.L().Error("消费阅读事件出错", zap.Error(err))
+				time.Sleep(time.Second)
+			}
+		}
+	}()
+	return nil
+}
+
+func (c *InteractiveReadEventConsumer) Close() error {
+	if c.cg == nil {
+		return nil
+	}
+	err := c.cg.Close()
+	<-c.done
+	// 最后关闭 producer, 保证正在转发的失败消息能发出去
+	return multierr.Append(err, c.producer.Close())
+}
+
+func (c *InteractiveReadEventConsumer) consume(msgs []*sarama.ConsumerMessage, evts []ReadEvent) error {
+	bizs := make([]string, 0, len(evts))
+	bizIds := make([]int64, 0, len(evts))
+	for _, evt := range evts {
+		bizs = append(bizs, "article")
+		bizIds = append(bizIds, evt.Aid)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	return c.repo.BatchIncrReadCnt(ctx, bizs, bizIds)
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"github.com/IBM/sarama"
+	"github.com/lutcoding/redbook/pkg/saramax"
 )
 
+const TopicReadEvent = "article_read"
+
 type Producer interface {
 	ProduceReadEvent(ctx context.Context, event ReadEvent) error
 }
@@ -20,10 +23,14 @@ func (k *KafkaProducer) ProduceReadEvent(ctx context.Context, event ReadEvent) e
 	if err != nil {
 		return err
 	}
-	_, _, err = k.producer.SendMessage(&sarama.ProducerMessage{
+	msg := &sarama.ProducerMessage{
 		Topic: k.topic,
 		Value: sarama.ByteEncoder(bytes),
-	})
+	}
+	// 链路信息放在 header 里, 消费者据此关联到发起阅读的请求
+	_, span := saramax.StartProducerSpan(ctx, msg)
+	_, _, err = k.producer.SendMessage(msg)
+	saramax.EndSpan(span, err)
 	return err
 }
 
@@ -1,5 +1,10 @@
 package events
 
+import "context"
+
 type Consumer interface {
-	Start() error
+	// Start 在后台开始消费, ctx 被取消后停止消费
+	Start(ctx context.Context) error
+	// Close 停止消费并释放资源, 会等待正在处理的消息处理完
+	Close() error
 }
@@ -2,15 +2,22 @@ package integration
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"github.com/gin-gonic/gin"
 	"github.com/lutcoding/redbook/common/globalkey"
+	"github.com/lutcoding/redbook/internal/domain"
+	events "github.com/lutcoding/redbook/internal/events/article"
 	"github.com/lutcoding/redbook/internal/repository"
+	"github.com/lutcoding/redbook/internal/repository/cache"
 	"github.com/lutcoding/redbook/internal/repository/dao"
 	article2 "github.com/lutcoding/redbook/internal/repository/dao/article"
+	"github.com/lutcoding/redbook/internal/service"
 	articleService "github.com/lutcoding/redbook/internal/service/article"
 	"github.com/lutcoding/redbook/internal/web/article"
 	"github.com/lutcoding/redbook/pkg/ginx/middlewares"
+	"github.com/lutcoding/redbook/pkg/objstore"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
@@ -19,7 +26,10 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 type ArticleSuite struct {
@@ -28,6 +38,13 @@ type ArticleSuite struct {
 	db     *gorm.DB
 }
 
+// noopProducer 测试不需要阅读事件
+type noopProducer struct{}
+
+func (noopProducer) ProduceReadEvent(ctx context.Context, event events.ReadEvent) error {
+	return nil
+}
+
 func (s *ArticleSuite) SetupSuite() {
 	s.server = gin.Default()
 	db, err := gorm.Open(mysql.Open("root:root@tcp(localhost:13316)/webook"))
@@ -35,10 +52,19 @@ func (s *ArticleSuite) SetupSuite() {
 	err = dao.InitTables(db)
 	assert.NoError(s.T(), err)
 	s.db = db
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	store, err := objstore.NewLocalStore(s.T().TempDir(), "http://localhost/objects", []byte("secret"))
+	require.NoError(s.T(), err)
 	articleDAO := article2.NewGORMArticleDao(db)
-	articleRepo := repository.NewArticleCacheRepository(articleDAO)
-	svc := articleService.NewService(articleRepo)
-	handler := article.NewHandler(svc)
+	articleRepo := repository.NewArticleCacheRepository(articleDAO, cache.NewArticleRedisCache(client))
+	interactiveRepo := repository.NewInteractiveCacheRepository(dao.NewGORMInteractiveDAO(db),
+		cache.NewInteractiveRedisCache(client))
+	revisionRepo := repository.NewArticleRevisionCacheRepository(dao.NewGORMArticleRevisionDAO(db))
+	mediaSvc := service.NewMediaService(repository.NewMediaCacheRepository(dao.NewGORMMediaDAO(db)), store)
+	svc := articleService.NewService(articleRepo, revisionRepo, interactiveRepo, mediaSvc, noopProducer{})
+	rankingRepo := repository.NewRankingCacheRepository(cache.NewRankingRedisCache(client), cache.NewRankingLocalCache())
+	handler := article.NewHandler(svc, service.NewInteractiveService(interactiveRepo),
+		service.NewRankingService(articleRepo, interactiveRepo, rankingRepo))
 	s.server.Use(func(ctx *gin.Context) {
 		ctx.Set(globalkey.JwtUserId, int64(1))
 	})
@@ -48,6 +74,60 @@ func (s *ArticleSuite) SetupSuite() {
 func (s *ArticleSuite) TearDownTest() {
 	err := s.db.Exec("TRUNCATE TABLE `articles`").Error
 	assert.NoError(s.T(), err)
+	err = s.db.Exec("TRUNCATE TABLE `publish_articles`").Error
+	assert.NoError(s.T(), err)
+}
+
+// 多个实例同时发表同一篇定时文章, 只有一个能发表成功
+func (s *ArticleSuite) TestPublishScheduled() {
+	t := s.T()
+	ctx := context.Background()
+	now := time.Now().UnixMilli()
+	scheduled := domain.ArticleStatusScheduled.ToUint8()
+	require.NoError(t, s.db.Create(&article2.Article{
+		Id: 1, Tittle: "due", AuthorId: 1, Status: scheduled, PublishAt: now - 1000,
+	}).Error)
+	require.NoError(t, s.db.Create(&article2.Article{
+		Id: 2, Tittle: "later", AuthorId: 1, Status: scheduled, PublishAt: now + 60_000,
+	}).Error)
+	articleDAO := article2.NewGORMArticleDao(s.db)
+
+	var (
+		wg  sync.WaitGroup
+		cnt atomic.Int32
+	)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := articleDAO.PublishScheduled(ctx, 1, now)
+			assert.NoError(t, err)
+			if ok {
+				cnt.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), cnt.Load())
+	var art article2.Article
+	require.NoError(t, s.db.Where("id = ?", 1).First(&art).Error)
+	assert.Equal(t, domain.ArticleStatusPublished.ToUint8(), art.Status)
+	assert.Equal(t, int64(0), art.PublishAt)
+	var pub article2.PublishArticle
+	require.NoError(t, s.db.Where("id = ?", 1).First(&pub).Error)
+	assert.Equal(t, "due", pub.Tittle)
+
+	// 没到时间不发表, 作者取消之后也不会再发表
+	ok, err := articleDAO.PublishScheduled(ctx, 2, now)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	require.NoError(t, articleDAO.CancelSchedule(ctx, 2, 1))
+	ok, err = articleDAO.PublishScheduled(ctx, 2, now+120_000)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	var pubCnt int64
+	require.NoError(t, s.db.Model(&article2.PublishArticle{}).Where("id = ?", 2).Count(&pubCnt).Error)
+	assert.Equal(t, int64(0), pubCnt)
 }
 
 func (s *ArticleSuite) TestEdit() {
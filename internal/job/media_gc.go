@@ -0,0 +1,36 @@
+package job
+
+import (
+	"context"
+	"github.com/lutcoding/redbook/internal/service"
+	"go.uber.org/zap"
+	"time"
+)
+
+// MediaGCJob 定时回收上传之后一直没有被文章引用的图片
+type MediaGCJob struct {
+	svc *service.MediaService
+	// grace 宽限期, 上传之后或者解除引用之后超过这个时间才会回收
+	grace   time.Duration
+	timeout time.Duration
+}
+
+func NewMediaGCJob(svc *service.MediaService, grace time.Duration, timeout time.Duration) *MediaGCJob {
+	return &MediaGCJob{
+		svc:     svc,
+		grace:   grace,
+		timeout: timeout,
+	}
+}
+
+func (j *MediaGCJob) Name() string {
+	return "media_gc"
+}
+
+func (j *MediaGCJob) Run(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, j.timeout)
+	defer cancel()
+	cnt, err := j.svc.GC(ctx, j.grace)
+	zap.L().Info("回收图片", zap.Int("cnt", cnt))
+	return err
+}
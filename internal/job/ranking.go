@@ -0,0 +1,30 @@
+package job
+
+import (
+	"context"
+	"github.com/lutcoding/redbook/internal/service"
+	"time"
+)
+
+// RankingJob 定时重新计算热榜
+type RankingJob struct {
+	svc     *service.RankingService
+	timeout time.Duration
+}
+
+func NewRankingJob(svc *service.RankingService, timeout time.Duration) *RankingJob {
+	return &RankingJob{
+		svc:     svc,
+		timeout: timeout,
+	}
+}
+
+func (j *RankingJob) Name() string {
+	return "ranking"
+}
+
+func (j *RankingJob) Run(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, j.timeout)
+	defer cancel()
+	return j.svc.RankTopN(ctx)
+}
@@ -0,0 +1,38 @@
+package job
+
+import (
+	"context"
+	"github.com/lutcoding/redbook/internal/service/article"
+	"go.uber.org/zap"
+	"time"
+)
+
+// RevisionPruneJob 定时清理文章的历史版本
+type RevisionPruneJob struct {
+	svc *article.Service
+	// 超过 maxAge 的版本会被删除, 但每篇文章至少保留 keep 个
+	maxAge  time.Duration
+	keep    int
+	timeout time.Duration
+}
+
+func NewRevisionPruneJob(svc *article.Service, maxAge time.Duration, keep int, timeout time.Duration) *RevisionPruneJob {
+	return &RevisionPruneJob{
+		svc:     svc,
+		maxAge:  maxAge,
+		keep:    keep,
+		timeout: timeout,
+	}
+}
+
+func (j *RevisionPruneJob) Name() string {
+	return "revision_prune"
+}
+
+func (j *RevisionPruneJob) Run(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, j.timeout)
+	defer cancel()
+	cnt, err := j.svc.PruneRevisions(ctx, j.maxAge, j.keep)
+	zap.L().Info("清理文章历史版本", zap.Int64("cnt", cnt))
+	return err
+}
@@ -0,0 +1,35 @@
+package job
+
+import (
+	"context"
+	"github.com/lutcoding/redbook/internal/service/article"
+	"go.uber.org/zap"
+	"time"
+)
+
+// ScheduledPublishJob 定时发表到期的文章
+type ScheduledPublishJob struct {
+	svc     *article.Service
+	timeout time.Duration
+}
+
+func NewScheduledPublishJob(svc *article.Service, timeout time.Duration) *ScheduledPublishJob {
+	return &ScheduledPublishJob{
+		svc:     svc,
+		timeout: timeout,
+	}
+}
+
+func (j *ScheduledPublishJob) Name() string {
+	return "scheduled_publish"
+}
+
+func (j *ScheduledPublishJob) Run(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, j.timeout)
+	defer cancel()
+	cnt, err := j.svc.PublishDue(ctx)
+	if cnt > 0 {
+		zap.L().Info("定时发表文章", zap.Int("cnt", cnt))
+	}
+	return err
+}
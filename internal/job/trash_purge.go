@@ -0,0 +1,37 @@
+package job
+
+import (
+	"context"
+	"github.com/lutcoding/redbook/internal/service/article"
+	"go.uber.org/zap"
+	"time"
+)
+
+// TrashPurgeJob 彻底删除在回收站里超过保留时间的文章
+type TrashPurgeJob struct {
+	svc       *article.Service
+	retention time.Duration
+	timeout   time.Duration
+}
+
+func NewTrashPurgeJob(svc *article.Service, retention time.Duration, timeout time.Duration) *TrashPurgeJob {
+	return &TrashPurgeJob{
+		svc:       svc,
+		retention: retention,
+		timeout:   timeout,
+	}
+}
+
+func (j *TrashPurgeJob) Name() string {
+	return "trash_purge"
+}
+
+func (j *TrashPurgeJob) Run(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, j.timeout)
+	defer cancel()
+	cnt, err := j.svc.PurgeTrash(ctx, j.retention)
+	if cnt > 0 {
+		zap.L().Info("彻底删除回收站文章", zap.Int("cnt", cnt))
+	}
+	return err
+}
@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"context"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"time"
+)
+
+const defaultShutdownTimeout = time.Second * 30
+
+// closer 退出时需要释放的资源
+type closer struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// onShutdown 注册退出时需要释放的资源, 退出时按照注册的逆序释放:
+// 先停止接收请求和消费消息, 最后再关闭底层的客户端
+func (s *Server) onShutdown(name string, fn func(ctx context.Context) error) {
+	s.closers = append(s.closers, closer{name: name, fn: fn})
+}
+
+// shutdown 释放所有资源, 所有资源共享 cfg.Server.ShutdownTimeout 的期限,
+// 超过期限还没释放完的资源不再等待
+func (s *Server) shutdown() error {
+	timeout := s.cfg.Server.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var err error
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		c := s.closers[i]
+		done := make(chan error, 1)
+		go func() {
+			done <- c.fn(ctx)
+		}()
+		var er error
+		select {
+		case er = <-done:
+		case <-ctx.Done():
+			er = ctx.Err()
+		}
+		if er != nil {
+			zap.L().Error("释放资源失败", zap.String("name", c.name), zap.Error(er))
+			err = multierr.Append(err, er)
+			continue
+		}
+		zap.L().Info("释放资源成功", zap.String("name", c.name))
+	}
+	s.closers = nil
+	return err
+}
@@ -2,13 +2,25 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"github.com/lutcoding/redbook/internal/domain"
 	"github.com/lutcoding/redbook/internal/repository/cache"
 	"github.com/lutcoding/redbook/internal/repository/dao/article"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+	"strconv"
 	"time"
 )
 
+var (
+	ErrArticleNotFound   = article.ErrRecordNotFound
+	ErrArticleNotTrashed = article.ErrNotTrashed
+)
+
+// loadPubTimeout 缓存未命中时从数据库加载线上文章的超时时间
+const loadPubTimeout = time.Second
+
 type ArticleRepository interface {
 	Create(ctx context.Context, article domain.Article) (int64, error)
 	Update(ctx context.Context, article domain.Article) error
@@ -19,18 +31,32 @@ type ArticleRepository interface {
 	GetDraft(ctx context.Context, id int64) (domain.Article, error)
 	GetPub(ctx context.Context, id int64) (domain.Article, error)
 	ListByTime(ctx context.Context, start time.Time, limit, offset int) ([]domain.Article, error)
+	CancelSchedule(ctx context.Context, id int64, authorId int64) error
+	ListScheduled(ctx context.Context, before time.Time, afterId int64, limit int) ([]domain.Article, error)
+	PublishScheduled(ctx context.Context, art domain.Article) (bool, error)
+	Delete(ctx context.Context, id int64, authorId int64) error
+	Restore(ctx context.Context, id int64, authorId int64) error
+	ListTrash(ctx context.Context, uid int64, limit, offset int) ([]domain.Article, error)
+	ListTrashedBefore(ctx context.Context, before time.Time, afterId int64, limit int) ([]domain.Article, error)
+	MarkPurging(ctx context.Context, art domain.Article, before time.Time) (bool, error)
+	Purge(ctx context.Context, art domain.Article) error
 	preCache(ctx context.Context, arts []domain.Article)
 }
 
 type ArticleCacheRepository struct {
 	dao   article.ArticleDAO
 	cache cache.ArticleCache
+	// pubGroup 合并同一篇线上文章并发的缓存未命中
+	pubGroup singleflight.Group
+	// delPubDelay 第二次删除线上文章缓存的延迟, 要比 loadPubTimeout 长
+	delPubDelay time.Duration
 }
 
 func NewArticleCacheRepository(dao article.ArticleDAO, cache cache.ArticleCache) *ArticleCacheRepository {
 	return &ArticleCacheRepository{
-		dao:   dao,
-		cache: cache,
+		dao:         dao,
+		cache:       cache,
+		delPubDelay: loadPubTimeout + time.Millisecond*500,
 	}
 }
 
@@ -73,6 +99,7 @@ func (repo *ArticleCacheRepository) Sync(ctx context.Context, art domain.Article
 			zap.L().Error("删除缓存redis失败", zap.Error(err))
 		}
 	}()
+	go repo.warmPub(id)
 	return id, nil
 }
 
@@ -81,6 +108,8 @@ func (repo *ArticleCacheRepository) SyncStatus(ctx context.Context, id int64, au
 	if err != nil {
 		return err
 	}
+	// 读者不能再读到这篇文章, 先同步删除线上版本的缓存
+	repo.delPub(ctx, id)
 	go func() {
 		err := repo.cache.DelFirstPage(ctx, authorId)
 		if err != nil {
@@ -149,16 +178,73 @@ func (repo *ArticleCacheRepository) GetDraft(ctx context.Context, id int64) (dom
 	return repo.entityToDraftDomain(art), nil
 }
 
+// GetPub 先查缓存, 未命中时同一篇文章的并发请求只有一个会查数据库
 func (repo *ArticleCacheRepository) GetPub(ctx context.Context, id int64) (domain.Article, error) {
-	art, err := repo.dao.GetPubById(ctx, id)
+	art, err := repo.cache.GetPub(ctx, id)
+	switch {
+	case err == nil:
+		return art, nil
+	case errors.Is(err, cache.ErrPubNotFound):
+		return domain.Article{}, ErrArticleNotFound
+	case !errors.Is(err, cache.ErrKeyNotExist):
+		// redis 出问题时继续查数据库, 有 singleflight 挡住一部分请求
+		zap.L().Error("查询文章缓存失败", zap.Int64("aid", id), zap.Error(err))
+	}
+	// 共享的加载不能用发起者的 ctx, 否则它断开时所有等待的请求都会失败, 缓存也写不进去.
+	// 只保留链路信息, 每个请求仍然按自己的 ctx 结束等待
+	sc := trace.SpanContextFromContext(ctx)
+	ch := repo.pubGroup.DoChan(strconv.FormatInt(id, 10), func() (any, error) {
+		loadCtx, cancel := context.WithTimeout(trace.ContextWithSpanContext(context.Background(), sc), loadPubTimeout)
+		defer cancel()
+		return repo.loadPub(loadCtx, id)
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return domain.Article{}, res.Err
+		}
+		return res.Val.(domain.Article), nil
+	case <-ctx.Done():
+		return domain.Article{}, ctx.Err()
+	}
+}
+
+// loadPub 从数据库加载线上文章并写入缓存, 不存在的文章也会缓存一小段时间
+func (repo *ArticleCacheRepository) loadPub(ctx context.Context, id int64) (domain.Article, error) {
+	entity, err := repo.dao.GetPubById(ctx, id)
+	if errors.Is(err, article.ErrRecordNotFound) {
+		if er := repo.cache.SetPubNotFound(ctx, id); er != nil {
+			zap.L().Error("设置缓存redis失败", zap.Int64("aid", id), zap.Error(er))
+		}
+		return domain.Article{}, err
+	}
 	if err != nil {
 		return domain.Article{}, err
 	}
-	return repo.entityToPubDomain(art), nil
+	art := repo.entityToPubDomain(entity)
+	if er := repo.cache.SetPub(ctx, art); er != nil {
+		zap.L().Error("设置缓存redis失败", zap.Int64("aid", id), zap.Error(er))
+	}
+	return art, nil
+}
+
+// warmPub 刚发表的文章马上会有读者访问, 直接加载到缓存, 同时覆盖旧的版本
+func (repo *ArticleCacheRepository) warmPub(id int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), loadPubTimeout)
+	defer cancel()
+	_, err := repo.loadPub(ctx, id)
+	if err == nil || errors.Is(err, article.ErrRecordNotFound) {
+		return
+	}
+	zap.L().Error("预热文章缓存失败", zap.Int64("aid", id), zap.Error(err))
+	// 加载失败时至少不能留下旧的版本
+	if err = repo.cache.DelPub(ctx, id); err != nil {
+		zap.L().Error("删除缓存redis失败", zap.Int64("aid", id), zap.Error(err))
+	}
 }
 
 func (repo *ArticleCacheRepository) ListByTime(ctx context.Context, start time.Time, limit, offset int) ([]domain.Article, error) {
-	arts, err := repo.dao.GetPubPageByTime(ctx, time.Now(), limit, offset)
+	arts, err := repo.dao.GetPubPageByTime(ctx, start, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -172,6 +258,139 @@ func (repo *ArticleCacheRepository) ListByTime(ctx context.Context, start time.T
 	return fn(arts), nil
 }
 
+func (repo *ArticleCacheRepository) CancelSchedule(ctx context.Context, id int64, authorId int64) error {
+	err := repo.dao.CancelSchedule(ctx, id, authorId)
+	if err != nil {
+		return err
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		err := repo.cache.DelFirstPage(ctx, authorId)
+		if err != nil {
+			zap.L().Error("删除缓存redis失败", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+func (repo *ArticleCacheRepository) ListScheduled(ctx context.Context, before time.Time, afterId int64, limit int) ([]domain.Article, error) {
+	arts, err := repo.dao.ListScheduled(ctx, before.UnixMilli(), afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Article, len(arts))
+	for i, art := range arts {
+		res[i] = repo.entityToDraftDomain(art)
+	}
+	return res, nil
+}
+
+func (repo *ArticleCacheRepository) PublishScheduled(ctx context.Context, art domain.Article) (bool, error) {
+	ok, err := repo.dao.PublishScheduled(ctx, art.Id, time.Now().UnixMilli())
+	if err != nil || !ok {
+		return ok, err
+	}
+	go repo.warmPub(art.Id)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		err := repo.cache.DelFirstPage(ctx, art.AuthorId)
+		if err != nil {
+			zap.L().Error("删除缓存redis失败", zap.Error(err))
+		}
+	}()
+	return true, nil
+}
+
+func (repo *ArticleCacheRepository) Delete(ctx context.Context, id int64, authorId int64) error {
+	err := repo.dao.Delete(ctx, id, authorId, time.Now().UnixMilli())
+	if err != nil {
+		return err
+	}
+	repo.delCache(ctx, id, authorId)
+	return nil
+}
+
+func (repo *ArticleCacheRepository) Restore(ctx context.Context, id int64, authorId int64) error {
+	err := repo.dao.Restore(ctx, id, authorId)
+	if err != nil {
+		return err
+	}
+	repo.delCache(ctx, id, authorId)
+	return nil
+}
+
+func (repo *ArticleCacheRepository) ListTrash(ctx context.Context, uid int64, limit, offset int) ([]domain.Article, error) {
+	arts, err := repo.dao.GetTrashPageByAuthor(ctx, uid, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Article, len(arts))
+	for i, art := range arts {
+		res[i] = repo.entityToDraftDomain(art)
+	}
+	return res, nil
+}
+
+func (repo *ArticleCacheRepository) ListTrashedBefore(ctx context.Context, before time.Time, afterId int64, limit int) ([]domain.Article, error) {
+	arts, err := repo.dao.ListTrashedBefore(ctx, before.UnixMilli(), afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Article, len(arts))
+	for i, art := range arts {
+		res[i] = repo.entityToDraftDomain(art)
+	}
+	return res, nil
+}
+
+func (repo *ArticleCacheRepository) MarkPurging(ctx context.Context, art domain.Article, before time.Time) (bool, error) {
+	ok, err := repo.dao.MarkPurging(ctx, art.Id, before.UnixMilli())
+	if err != nil || !ok {
+		return ok, err
+	}
+	// 文章从作者的回收站里消失了
+	repo.delCache(ctx, art.Id, art.AuthorId)
+	return true, nil
+}
+
+func (repo *ArticleCacheRepository) Purge(ctx context.Context, art domain.Article) error {
+	return repo.dao.Purge(ctx, art.Id)
+}
+
+// delCache 删除文章相关的所有缓存: 作者的草稿首页, 草稿, 线上版本.
+// 线上版本同步删除, 返回之后读者就读不到了
+func (repo *ArticleCacheRepository) delCache(ctx context.Context, id int64, authorId int64) {
+	repo.delPub(ctx, id)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := repo.cache.DelFirstPage(ctx, authorId); err != nil {
+			zap.L().Error("删除缓存redis失败", zap.Error(err))
+		}
+		if err := repo.cache.DelDraft(ctx, id); err != nil {
+			zap.L().Error("删除缓存redis失败", zap.Error(err))
+		}
+	}()
+}
+
+// delPub 删除线上文章的缓存. 删除之前已经开始的 loadPub 可能读到旧的数据, 在删除之后写回缓存,
+// 所以新的请求不再合并到这些加载里, 并且等它们都超时之后再删除一次
+func (repo *ArticleCacheRepository) delPub(ctx context.Context, id int64) {
+	repo.pubGroup.Forget(strconv.FormatInt(id, 10))
+	if err := repo.cache.DelPub(ctx, id); err != nil {
+		zap.L().Error("删除缓存redis失败", zap.Int64("aid", id), zap.Error(err))
+	}
+	time.AfterFunc(repo.delPubDelay, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := repo.cache.DelPub(ctx, id); err != nil {
+			zap.L().Error("删除缓存redis失败", zap.Int64("aid", id), zap.Error(err))
+		}
+	})
+}
+
 func (repo *ArticleCacheRepository) preCache(ctx context.Context, arts []domain.Article) {
 	const size = 1024 * 1024
 	if len(arts) > 0 && len(arts[0].Content) < size {
@@ -183,23 +402,40 @@ func (repo *ArticleCacheRepository) preCache(ctx context.Context, arts []domain.
 }
 
 func (repo *ArticleCacheRepository) domainToEntity(art domain.Article) article.Article {
-	return article.Article{
+	res := article.Article{
 		Id:       art.Id,
 		Tittle:   art.Tittle,
 		Content:  art.Content,
+		Cover:    art.Cover,
+		Images:   art.Images,
 		AuthorId: art.AuthorId,
 		Status:   art.ArticleStatus.ToUint8(),
 	}
+	if !art.PublishAt.IsZero() {
+		res.PublishAt = art.PublishAt.UnixMilli()
+	}
+	return res
 }
 
 func (repo *ArticleCacheRepository) entityToDraftDomain(art article.Article) domain.Article {
-	return domain.Article{
+	res := domain.Article{
 		Id:            art.Id,
 		Tittle:        art.Tittle,
 		Content:       art.Content,
+		Cover:         art.Cover,
+		Images:        art.Images,
 		AuthorId:      art.AuthorId,
 		ArticleStatus: domain.ArticleStatus(art.Status),
+		Ctime:         time.UnixMilli(art.CreateTime),
+		Utime:         time.UnixMilli(art.UpdateTime),
+	}
+	if art.PublishAt > 0 {
+		res.PublishAt = time.UnixMilli(art.PublishAt)
+	}
+	if art.DeleteTime > 0 {
+		res.Dtime = time.UnixMilli(art.DeleteTime)
 	}
+	return res
 }
 
 func (repo *ArticleCacheRepository) entityToPubDomain(art article.PublishArticle) domain.Article {
@@ -207,7 +443,11 @@ func (repo *ArticleCacheRepository) entityToPubDomain(art article.PublishArticle
 		Id:            art.Id,
 		Tittle:        art.Tittle,
 		Content:       art.Content,
+		Cover:         art.Cover,
+		Images:        art.Images,
 		AuthorId:      art.AuthorId,
 		ArticleStatus: domain.ArticleStatus(art.Status),
+		Ctime:         time.UnixMilli(art.CreateTime),
+		Utime:         time.UnixMilli(art.UpdateTime),
 	}
 }
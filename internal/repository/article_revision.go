@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/internal/repository/dao"
+	"time"
+)
+
+var ErrRevisionNotFound = dao.ErrRevisionNotFound
+
+type ArticleRevisionRepository interface {
+	Create(ctx context.Context, r domain.ArticleRevision) (int64, error)
+	List(ctx context.Context, articleId int64, limit, offset int) ([]domain.ArticleRevision, error)
+	FindById(ctx context.Context, id int64) (domain.ArticleRevision, error)
+	ListArticleIdsBefore(ctx context.Context, before time.Time, afterArticleId int64, limit int) ([]int64, error)
+	DeleteBefore(ctx context.Context, articleId int64, before time.Time, keep int) (int64, error)
+	DeleteByArticle(ctx context.Context, articleId int64) error
+	// MediaKeys 文章所有版本引用的图片, 已经去重
+	MediaKeys(ctx context.Context, articleId int64) ([]string, error)
+}
+
+type ArticleRevisionCacheRepository struct {
+	dao dao.ArticleRevisionDAO
+}
+
+func NewArticleRevisionCacheRepository(dao dao.ArticleRevisionDAO) *ArticleRevisionCacheRepository {
+	return &ArticleRevisionCacheRepository{
+		dao: dao,
+	}
+}
+
+func (repo *ArticleRevisionCacheRepository) Create(ctx context.Context, r domain.ArticleRevision) (int64, error) {
+	return repo.dao.Insert(ctx, dao.ArticleRevision{
+		ArticleId: r.ArticleId,
+		Operator:  r.Operator,
+		Kind:      r.Kind.ToUint8(),
+		Tittle:    r.Tittle,
+		Content:   r.Content,
+		Cover:     r.Cover,
+		Images:    r.Images,
+	})
+}
+
+func (repo *ArticleRevisionCacheRepository) List(ctx context.Context, articleId int64, limit, offset int) ([]domain.ArticleRevision, error) {
+	rs, err := repo.dao.List(ctx, articleId, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.ArticleRevision, 0, len(rs))
+	for _, r := range rs {
+		res = append(res, repo.toDomain(r))
+	}
+	return res, nil
+}
+
+func (repo *ArticleRevisionCacheRepository) FindById(ctx context.Context, id int64) (domain.ArticleRevision, error) {
+	r, err := repo.dao.FindById(ctx, id)
+	if err != nil {
+		return domain.ArticleRevision{}, err
+	}
+	return repo.toDomain(r), nil
+}
+
+func (repo *ArticleRevisionCacheRepository) ListArticleIdsBefore(ctx context.Context, before time.Time, afterArticleId int64, limit int) ([]int64, error) {
+	return repo.dao.ListArticleIdsBefore(ctx, before.UnixMilli(), afterArticleId, limit)
+}
+
+func (repo *ArticleRevisionCacheRepository) DeleteBefore(ctx context.Context, articleId int64, before time.Time, keep int) (int64, error) {
+	return repo.dao.DeleteBefore(ctx, articleId, before.UnixMilli(), keep)
+}
+
+func (repo *ArticleRevisionCacheRepository) DeleteByArticle(ctx context.Context, articleId int64) error {
+	return repo.dao.DeleteByArticle(ctx, articleId)
+}
+
+func (repo *ArticleRevisionCacheRepository) MediaKeys(ctx context.Context, articleId int64) ([]string, error) {
+	rs, err := repo.dao.ListMedia(ctx, articleId)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]struct{})
+	var res []string
+	for _, r := range rs {
+		keys := domain.Article{Cover: r.Cover, Images: r.Images}.MediaKeys()
+		for _, key := range keys {
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			res = append(res, key)
+		}
+	}
+	return res, nil
+}
+
+func (repo *ArticleRevisionCacheRepository) toDomain(r dao.ArticleRevision) domain.ArticleRevision {
+	return domain.ArticleRevision{
+		Id:        r.Id,
+		ArticleId: r.ArticleId,
+		Operator:  r.Operator,
+		Kind:      domain.RevisionKind(r.Kind),
+		Tittle:    r.Tittle,
+		Content:   r.Content,
+		Cover:     r.Cover,
+		Images:    r.Images,
+		Ctime:     time.UnixMilli(r.CreateTime),
+	}
+}
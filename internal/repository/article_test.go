@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/lutcoding/redbook/common/globalkey"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/internal/repository/cache"
+	"github.com/lutcoding/redbook/internal/repository/dao/article"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestArticleCacheRepository_GetPub(t *testing.T) {
+	mr := miniredis.RunT(t)
+	dao := &fakePubDAO{pubs: map[int64]article.PublishArticle{1: {Id: 1, Tittle: "hello"}}}
+	repo := NewArticleCacheRepository(dao, cache.NewArticleRedisCache(redis.NewClient(&redis.Options{Addr: mr.Addr()})))
+	ctx := context.Background()
+
+	// 第一次查数据库, 之后读缓存
+	for i := 0; i < 3; i++ {
+		art, err := repo.GetPub(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", art.Tittle)
+	}
+	assert.Equal(t, 1, dao.loads())
+	// 过期时间加上了最多 20% 的随机时间
+	ttl := mr.TTL(pubKey(1))
+	assert.GreaterOrEqual(t, ttl, time.Minute*10)
+	assert.LessOrEqual(t, ttl, time.Minute*12)
+
+	// 不存在的文章也缓存, 时间更短
+	for i := 0; i < 3; i++ {
+		_, err := repo.GetPub(ctx, 2)
+		assert.Equal(t, ErrArticleNotFound, err)
+	}
+	assert.Equal(t, 2, dao.loads())
+	ttl = mr.TTL(pubKey(2))
+	assert.GreaterOrEqual(t, ttl, time.Minute)
+	assert.LessOrEqual(t, ttl, time.Minute*6/5)
+}
+
+// 同一篇文章并发的缓存未命中只查一次数据库
+func TestArticleCacheRepository_GetPubSingleflight(t *testing.T) {
+	mr := miniredis.RunT(t)
+	dao := &fakePubDAO{
+		pubs:    map[int64]article.PublishArticle{1: {Id: 1, Tittle: "hello"}},
+		loading: make(chan struct{}, 10),
+		block:   make(chan struct{}),
+	}
+	repo := NewArticleCacheRepository(dao, cache.NewArticleRedisCache(redis.NewClient(&redis.Options{Addr: mr.Addr()})))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			art, err := repo.GetPub(context.Background(), 1)
+			assert.NoError(t, err)
+			assert.Equal(t, "hello", art.Tittle)
+		}()
+	}
+	<-dao.loading
+	// 等其他请求都合并到这次加载
+	time.Sleep(time.Millisecond * 50)
+	close(dao.block)
+	wg.Wait()
+	assert.Equal(t, 1, dao.loads())
+}
+
+// 设为私密之前开始的加载把旧的版本写回缓存, 延迟之后会再删除一次
+func TestArticleCacheRepository_SyncStatusRace(t *testing.T) {
+	mr := miniredis.RunT(t)
+	block := make(chan struct{})
+	dao := &fakePubDAO{
+		pubs:    map[int64]article.PublishArticle{1: {Id: 1, Tittle: "hello"}},
+		loading: make(chan struct{}, 1),
+		block:   block,
+	}
+	repo := NewArticleCacheRepository(dao, cache.NewArticleRedisCache(redis.NewClient(&redis.Options{Addr: mr.Addr()})))
+	repo.delPubDelay = time.Millisecond * 100
+	ctx := context.Background()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = repo.GetPub(ctx, 1)
+	}()
+	<-dao.loading
+	require.NoError(t, repo.SyncStatus(ctx, 1, 1, domain.ArticleStatusPrivate))
+	close(block)
+	<-done
+	assert.True(t, mr.Exists(pubKey(1)), "旧的版本被写回了缓存")
+
+	require.Eventually(t, func() bool {
+		return !mr.Exists(pubKey(1))
+	}, time.Second, time.Millisecond*10)
+	_, err := repo.GetPub(ctx, 1)
+	assert.Equal(t, ErrArticleNotFound, err)
+}
+
+func pubKey(id int64) string {
+	return globalkey.PublishedArtCachedPrefix + strconv.FormatInt(id, 10)
+}
+
+// fakePubDAO block 不为空时, GetPubById 读到数据之后通知 loading, 然后等 block 关闭再返回
+type fakePubDAO struct {
+	article.ArticleDAO
+	mu      sync.Mutex
+	pubs    map[int64]article.PublishArticle
+	cnt     int
+	loading chan struct{}
+	block   chan struct{}
+}
+
+func (d *fakePubDAO) GetPubById(ctx context.Context, id int64) (article.PublishArticle, error) {
+	d.mu.Lock()
+	d.cnt++
+	art, ok := d.pubs[id]
+	block := d.block
+	d.mu.Unlock()
+	if block != nil {
+		d.loading <- struct{}{}
+		<-block
+	}
+	if !ok {
+		return article.PublishArticle{}, article.ErrRecordNotFound
+	}
+	return art, nil
+}
+
+// SyncStatus 线上库只保留读者能读到的文章
+func (d *fakePubDAO) SyncStatus(ctx context.Context, id int64, authorId int64, status uint8) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if status != domain.ArticleStatusPublished.ToUint8() {
+		delete(d.pubs, id)
+	}
+	d.block = nil
+	return nil
+}
+
+func (d *fakePubDAO) loads() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.cnt
+}
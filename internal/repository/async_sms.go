@@ -5,14 +5,31 @@ import (
 	"encoding/json"
 	"github.com/lutcoding/redbook/internal/domain"
 	"github.com/lutcoding/redbook/internal/repository/dao"
+	"strings"
+	"time"
 )
 
-var ErrWaitingSMSNotFound = dao.ErrWaitingSMSNotFound
+var ErrAsyncSmsNotFound = dao.ErrAsyncSmsNotFound
+
+// maxSmsErrLen 失败原因最多保存的字节数, 和数据库字段长度一致
+const maxSmsErrLen = 1024
 
 type AsyncSmsRepository interface {
 	Add(ctx context.Context, sms domain.AsyncSms) error
-	GetWaitingSms(ctx context.Context) (domain.AsyncSms, error)
-	ReportScheduleResult(ctx context.Context, id int64, success bool) error
+	AddBatch(ctx context.Context, smses []domain.AsyncSms) error
+	// ClaimWaitingSms 抢占最多 limit 条到了发送时间的短信, lease 之内没有结果的会被重新抢占
+	ClaimWaitingSms(ctx context.Context, limit int, lease time.Duration) ([]domain.AsyncSms, error)
+	MarkSuccess(ctx context.Context, attempt domain.AsyncSmsAttempt) error
+	MarkFailed(ctx context.Context, attempt domain.AsyncSmsAttempt, nextRetryAt time.Time) error
+	// Postpone 抢占之后没有真正发送(比如被限流), 改回等待发送并且不占用重试次数
+	Postpone(ctx context.Context, sms domain.AsyncSms, nextRetryAt time.Time) error
+
+	FindById(ctx context.Context, id int64) (domain.AsyncSms, error)
+	List(ctx context.Context, query domain.AsyncSmsQuery, limit, offset int) ([]domain.AsyncSms, error)
+	Count(ctx context.Context, query domain.AsyncSmsQuery) (int64, error)
+	ListAttempts(ctx context.Context, smsId int64) ([]domain.AsyncSmsAttempt, error)
+	// Resend 发送成功或者失败的短信重新发送, 其他状态返回 false
+	Resend(ctx context.Context, id int64) (bool, error)
 }
 
 type AsyncSmsCacheRepository struct {
@@ -29,29 +46,123 @@ func (repo *AsyncSmsCacheRepository) Add(ctx context.Context, sms domain.AsyncSm
 	return repo.dao.Insert(ctx, repo.domainToEntity(sms))
 }
 
-func (repo *AsyncSmsCacheRepository) GetWaitingSms(ctx context.Context) (domain.AsyncSms, error) {
-	sms, err := repo.dao.GetWaitingSms(ctx)
+func (repo *AsyncSmsCacheRepository) AddBatch(ctx context.Context, smses []domain.AsyncSms) error {
+	entities := make([]dao.AsyncSms, 0, len(smses))
+	for _, sms := range smses {
+		entities = append(entities, repo.domainToEntity(sms))
+	}
+	return repo.dao.InsertBatch(ctx, entities)
+}
+
+func (repo *AsyncSmsCacheRepository) ClaimWaitingSms(ctx context.Context, limit int, lease time.Duration) ([]domain.AsyncSms, error) {
+	now := time.Now()
+	smses, err := repo.dao.ClaimWaitingSms(ctx, now.UnixMilli(), now.Add(lease).UnixMilli(), limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.AsyncSms, 0, len(smses))
+	for _, sms := range smses {
+		res = append(res, repo.entityToDomain(sms))
+	}
+	return res, nil
+}
+
+func (repo *AsyncSmsCacheRepository) MarkSuccess(ctx context.Context, attempt domain.AsyncSmsAttempt) error {
+	return repo.dao.MarkSuccess(ctx, repo.attemptToEntity(attempt))
+}
+
+func (repo *AsyncSmsCacheRepository) MarkFailed(ctx context.Context, attempt domain.AsyncSmsAttempt, nextRetryAt time.Time) error {
+	return repo.dao.MarkFailed(ctx, repo.attemptToEntity(attempt), nextRetryAt.UnixMilli())
+}
+
+func (repo *AsyncSmsCacheRepository) Postpone(ctx context.Context, sms domain.AsyncSms, nextRetryAt time.Time) error {
+	return repo.dao.Postpone(ctx, sms.Id, sms.RetryCnt, nextRetryAt.UnixMilli())
+}
+
+func (repo *AsyncSmsCacheRepository) FindById(ctx context.Context, id int64) (domain.AsyncSms, error) {
+	sms, err := repo.dao.FindById(ctx, id)
 	if err != nil {
 		return domain.AsyncSms{}, err
 	}
 	return repo.entityToDomain(sms), nil
 }
 
-func (repo *AsyncSmsCacheRepository) ReportScheduleResult(ctx context.Context, id int64, success bool) error {
-	if success {
-		return repo.dao.MarkSuccess(ctx, id)
+func (repo *AsyncSmsCacheRepository) List(ctx context.Context, query domain.AsyncSmsQuery, limit, offset int) ([]domain.AsyncSms, error) {
+	smses, err := repo.dao.List(ctx, repo.toFilter(query), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.AsyncSms, 0, len(smses))
+	for _, sms := range smses {
+		res = append(res, repo.entityToDomain(sms))
+	}
+	return res, nil
+}
+
+func (repo *AsyncSmsCacheRepository) Count(ctx context.Context, query domain.AsyncSmsQuery) (int64, error) {
+	return repo.dao.Count(ctx, repo.toFilter(query))
+}
+
+func (repo *AsyncSmsCacheRepository) ListAttempts(ctx context.Context, smsId int64) ([]domain.AsyncSmsAttempt, error) {
+	attempts, err := repo.dao.ListAttempts(ctx, smsId)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.AsyncSmsAttempt, 0, len(attempts))
+	for _, attempt := range attempts {
+		res = append(res, domain.AsyncSmsAttempt{
+			SmsId:   attempt.SmsId,
+			Attempt: attempt.Attempt,
+			Err:     attempt.Err,
+			Latency: time.Duration(attempt.Latency) * time.Millisecond,
+			Ctime:   time.UnixMilli(attempt.CreateTime),
+		})
+	}
+	return res, nil
+}
+
+func (repo *AsyncSmsCacheRepository) Resend(ctx context.Context, id int64) (bool, error) {
+	return repo.dao.Resend(ctx, id, time.Now().UnixMilli())
+}
+
+func (repo *AsyncSmsCacheRepository) toFilter(query domain.AsyncSmsQuery) dao.AsyncSmsFilter {
+	filter := dao.AsyncSmsFilter{
+		Number: query.Number,
+	}
+	if query.Status != nil {
+		status := int(*query.Status)
+		filter.Status = &status
+	}
+	if !query.Start.IsZero() {
+		filter.StartTime = query.Start.UnixMilli()
+	}
+	if !query.End.IsZero() {
+		filter.EndTime = query.End.UnixMilli()
+	}
+	return filter
+}
+
+func (repo *AsyncSmsCacheRepository) attemptToEntity(attempt domain.AsyncSmsAttempt) dao.AsyncSmsAttempt {
+	return dao.AsyncSmsAttempt{
+		SmsId:   attempt.SmsId,
+		Attempt: attempt.Attempt,
+		Err:     truncateErr(attempt.Err),
+		Latency: attempt.Latency.Milliseconds(),
 	}
-	return repo.dao.MarkFailed(ctx, id)
 }
 
 func (repo *AsyncSmsCacheRepository) domainToEntity(sms domain.AsyncSms) dao.AsyncSms {
 	bytes, _ := json.Marshal(sms.AsyncSmsConfig)
-	return dao.AsyncSms{
+	res := dao.AsyncSms{
 		Id:             sms.Id,
 		RetryCnt:       0,
 		RetryMax:       sms.RetryMax,
 		AsyncSmsConfig: bytes,
 	}
+	if !sms.NextRetryAt.IsZero() {
+		res.NextRetryAt = sms.NextRetryAt.UnixMilli()
+	}
+	return res
 }
 
 func (repo *AsyncSmsCacheRepository) entityToDomain(sms dao.AsyncSms) domain.AsyncSms {
@@ -59,7 +170,21 @@ func (repo *AsyncSmsCacheRepository) entityToDomain(sms dao.AsyncSms) domain.Asy
 	json.Unmarshal(sms.AsyncSmsConfig, &conf)
 	return domain.AsyncSms{
 		Id:             sms.Id,
+		RetryCnt:       sms.RetryCnt,
 		RetryMax:       sms.RetryMax,
+		Status:         domain.AsyncSmsStatus(sms.Status),
+		LastErr:        sms.LastErr,
+		NextRetryAt:    time.UnixMilli(sms.NextRetryAt),
+		Ctime:          time.UnixMilli(sms.CreateTime),
+		Utime:          time.UnixMilli(sms.UpdateTime),
 		AsyncSmsConfig: conf,
 	}
 }
+
+// truncateErr 截断过长的错误信息, 不能截断在多字节字符中间
+func truncateErr(msg string) string {
+	if len(msg) <= maxSmsErrLen {
+		return msg
+	}
+	return strings.ToValidUTF8(msg[:maxSmsErrLen], "")
+}
@@ -3,14 +3,27 @@ package cache
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/lutcoding/redbook/common/globalkey"
 	"github.com/lutcoding/redbook/internal/domain"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
+	"math/rand"
 	"time"
 )
 
+// ErrPubNotFound 命中了不存在的文章的缓存
+var ErrPubNotFound = errors.New("文章不存在")
+
+const (
+	pubExpiration = time.Minute * 10
+	// 不存在的文章只缓存很短的时间, 文章发表后很快就能读到
+	pubNotFoundExpiration = time.Minute
+	// pubNotFound 不存在的文章缓存为空值, 正常的文章序列化后不会是空
+	pubNotFound = ""
+)
+
 type ArticleCache interface {
 	SetFirstPage(ctx context.Context, uid int64, arts []domain.Article) error
 	DelFirstPage(ctx context.Context, uid int64) error
@@ -18,9 +31,12 @@ type ArticleCache interface {
 
 	SetDraft(ctx context.Context, art domain.Article) error
 	GetDraft(ctx context.Context, uid int64) (domain.Article, error)
+	DelDraft(ctx context.Context, id int64) error
 
 	// SetPub 正常来说，创作者和读者的 Redis 集群要分开，因为读者是一个核心中的核心
 	SetPub(ctx context.Context, art domain.Article) error
+	// SetPubNotFound 缓存不存在的文章, 防止缓存穿透
+	SetPubNotFound(ctx context.Context, id int64) error
 	DelPub(ctx context.Context, uid int64) error
 	GetPub(ctx context.Context, uid int64) (domain.Article, error)
 }
@@ -49,6 +65,7 @@ func (cache *ArticleRedisCache) SetFirstPage(ctx context.Context, uid int64, art
 
 func (cache *ArticleRedisCache) GetFirstPage(ctx context.Context, uid int64) ([]domain.Article, error) {
 	bytes, err := cache.client.Get(ctx, cache.firstPageKey(uid)).Bytes()
+	observeGet("article_first_page", err)
 	if err != nil {
 		return nil, err
 	}
@@ -72,6 +89,7 @@ func (cache *ArticleRedisCache) SetDraft(ctx context.Context, art domain.Article
 
 func (cache *ArticleRedisCache) GetDraft(ctx context.Context, id int64) (domain.Article, error) {
 	bytes, err := cache.client.Get(ctx, cache.draftKey(id)).Bytes()
+	observeGet("article_draft", err)
 	if err != nil {
 		return domain.Article{}, err
 	}
@@ -80,12 +98,20 @@ func (cache *ArticleRedisCache) GetDraft(ctx context.Context, id int64) (domain.
 	return art, err
 }
 
+func (cache *ArticleRedisCache) DelDraft(ctx context.Context, id int64) error {
+	return cache.client.Del(ctx, cache.draftKey(id)).Err()
+}
+
 func (cache *ArticleRedisCache) SetPub(ctx context.Context, art domain.Article) error {
 	val, err := json.Marshal(art)
 	if err != nil {
 		return err
 	}
-	return cache.client.Set(ctx, cache.pubKey(art.Id), val, time.Minute*10).Err()
+	return cache.client.Set(ctx, cache.pubKey(art.Id), val, jitter(pubExpiration)).Err()
+}
+
+func (cache *ArticleRedisCache) SetPubNotFound(ctx context.Context, id int64) error {
+	return cache.client.Set(ctx, cache.pubKey(id), pubNotFound, jitter(pubNotFoundExpiration)).Err()
 }
 
 func (cache *ArticleRedisCache) DelPub(ctx context.Context, id int64) error {
@@ -94,9 +120,13 @@ func (cache *ArticleRedisCache) DelPub(ctx context.Context, id int64) error {
 
 func (cache *ArticleRedisCache) GetPub(ctx context.Context, id int64) (domain.Article, error) {
 	val, err := cache.client.Get(ctx, cache.pubKey(id)).Bytes()
+	observeGet("article_pub", err)
 	if err != nil {
 		return domain.Article{}, err
 	}
+	if string(val) == pubNotFound {
+		return domain.Article{}, ErrPubNotFound
+	}
 	var res domain.Article
 	err = json.Unmarshal(val, &res)
 	return res, err
@@ -114,3 +144,8 @@ func (cache *ArticleRedisCache) firstPageKey(uid int64) string {
 func (cache *ArticleRedisCache) draftKey(id int64) string {
 	return fmt.Sprintf("%v%v", globalkey.DraftArtCachePrefix, id)
 }
+
+// jitter 在过期时间上增加最多 20% 的随机时间, 避免同时写入的缓存同时过期
+func jitter(expiration time.Duration) time.Duration {
+	return expiration + time.Duration(rand.Int63n(int64(expiration)/5+1))
+}
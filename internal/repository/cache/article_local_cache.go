@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"context"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/pkg/localcache"
+	"go.uber.org/multierr"
+	"strconv"
+	"time"
+)
+
+const (
+	articleFirstPageLocalCacheName = "article_first_page"
+	articleDraftLocalCacheName     = "article_draft"
+	articlePubLocalCacheName       = "article_pub"
+)
+
+// ArticleLocalCache 在 ArticleCache 前面加一层本地缓存, 草稿首页, 草稿, 线上文章分别缓存.
+// 不存在的文章只缓存在 redis
+type ArticleLocalCache struct {
+	redis     ArticleCache
+	firstPage *localcache.Cache[[]domain.Article]
+	draft     *localcache.Cache[domain.Article]
+	pub       *localcache.Cache[domain.Article]
+	inv       *Invalidator
+}
+
+func NewArticleLocalCache(redis ArticleCache, inv *Invalidator, size int, ttl time.Duration) *ArticleLocalCache {
+	cache := &ArticleLocalCache{
+		redis:     redis,
+		firstPage: localcache.New[[]domain.Article](articleFirstPageLocalCacheName, size, ttl).StartCleanup(ttl),
+		draft:     localcache.New[domain.Article](articleDraftLocalCacheName, size, ttl).StartCleanup(ttl),
+		pub:       localcache.New[domain.Article](articlePubLocalCacheName, size, ttl).StartCleanup(ttl),
+		inv:       inv,
+	}
+	inv.Register(articleFirstPageLocalCacheName, cache.firstPage.Delete)
+	inv.Register(articleDraftLocalCacheName, cache.draft.Delete)
+	inv.Register(articlePubLocalCacheName, cache.pub.Delete)
+	return cache
+}
+
+// Close 停止清理过期的 key
+func (cache *ArticleLocalCache) Close() error {
+	return multierr.Combine(cache.firstPage.Close(), cache.draft.Close(), cache.pub.Close())
+}
+
+func (cache *ArticleLocalCache) SetFirstPage(ctx context.Context, uid int64, arts []domain.Article) error {
+	key := strconv.FormatInt(uid, 10)
+	err := cache.redis.SetFirstPage(ctx, uid, arts)
+	if err != nil {
+		cache.firstPage.Delete(key)
+		return err
+	}
+	local := make([]domain.Article, len(arts))
+	copy(local, arts)
+	cache.firstPage.Set(key, local)
+	cache.inv.Publish(ctx, articleFirstPageLocalCacheName, key)
+	return nil
+}
+
+func (cache *ArticleLocalCache) DelFirstPage(ctx context.Context, uid int64) error {
+	// 先修改 redis, 否则其他实例可能在删除本地缓存之后又加载到旧的值
+	err := cache.redis.DelFirstPage(ctx, uid)
+	key := strconv.FormatInt(uid, 10)
+	cache.firstPage.Delete(key)
+	cache.inv.Publish(ctx, articleFirstPageLocalCacheName, key)
+	return err
+}
+
+// GetFirstPage 返回副本, 调用方修改结果不会影响本地缓存
+func (cache *ArticleLocalCache) GetFirstPage(ctx context.Context, uid int64) ([]domain.Article, error) {
+	key := strconv.FormatInt(uid, 10)
+	if arts, ok := cache.firstPage.Get(key); ok {
+		res := make([]domain.Article, len(arts))
+		copy(res, arts)
+		return res, nil
+	}
+	arts, err := cache.redis.GetFirstPage(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	local := make([]domain.Article, len(arts))
+	copy(local, arts)
+	cache.firstPage.Set(key, local)
+	return arts, nil
+}
+
+func (cache *ArticleLocalCache) SetDraft(ctx context.Context, art domain.Article) error {
+	key := strconv.FormatInt(art.Id, 10)
+	err := cache.redis.SetDraft(ctx, art)
+	if err != nil {
+		cache.draft.Delete(key)
+		return err
+	}
+	cache.draft.Set(key, art)
+	cache.inv.Publish(ctx, articleDraftLocalCacheName, key)
+	return nil
+}
+
+func (cache *ArticleLocalCache) GetDraft(ctx context.Context, id int64) (domain.Article, error) {
+	key := strconv.FormatInt(id, 10)
+	if art, ok := cache.draft.Get(key); ok {
+		return art, nil
+	}
+	art, err := cache.redis.GetDraft(ctx, id)
+	if err != nil {
+		return domain.Article{}, err
+	}
+	cache.draft.Set(key, art)
+	return art, nil
+}
+
+func (cache *ArticleLocalCache) DelDraft(ctx context.Context, id int64) error {
+	err := cache.redis.DelDraft(ctx, id)
+	key := strconv.FormatInt(id, 10)
+	cache.draft.Delete(key)
+	cache.inv.Publish(ctx, articleDraftLocalCacheName, key)
+	return err
+}
+
+func (cache *ArticleLocalCache) SetPub(ctx context.Context, art domain.Article) error {
+	key := strconv.FormatInt(art.Id, 10)
+	err := cache.redis.SetPub(ctx, art)
+	if err != nil {
+		cache.pub.Delete(key)
+		return err
+	}
+	cache.pub.Set(key, art)
+	cache.inv.Publish(ctx, articlePubLocalCacheName, key)
+	return nil
+}
+
+func (cache *ArticleLocalCache) SetPubNotFound(ctx context.Context, id int64) error {
+	err := cache.redis.SetPubNotFound(ctx, id)
+	key := strconv.FormatInt(id, 10)
+	cache.pub.Delete(key)
+	cache.inv.Publish(ctx, articlePubLocalCacheName, key)
+	return err
+}
+
+func (cache *ArticleLocalCache) DelPub(ctx context.Context, id int64) error {
+	err := cache.redis.DelPub(ctx, id)
+	key := strconv.FormatInt(id, 10)
+	cache.pub.Delete(key)
+	cache.inv.Publish(ctx, articlePubLocalCacheName, key)
+	return err
+}
+
+func (cache *ArticleLocalCache) GetPub(ctx context.Context, id int64) (domain.Article, error) {
+	key := strconv.FormatInt(id, 10)
+	if art, ok := cache.pub.Get(key); ok {
+		return art, nil
+	}
+	art, err := cache.redis.GetPub(ctx, id)
+	if err != nil {
+		return domain.Article{}, err
+	}
+	cache.pub.Set(key, art)
+	return art, nil
+}
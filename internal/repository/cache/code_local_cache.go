@@ -1,10 +1,102 @@
 package cache
 
-// CodeLocalCache 单实例生产环境下
-// 考虑并发问题
+import (
+	"context"
+	"fmt"
+	"github.com/lutcoding/redbook/pkg/localcache"
+	"sync"
+	"time"
+)
+
+// CodeLocalCache 单实例部署或者测试环境下使用, 语义和 set_code.lua, verify_code.lua 一致.
+// 检查和修改要在一个锁里完成, 相当于 lua 脚本的原子性
 type CodeLocalCache struct {
+	mu    sync.Mutex
+	cache *localcache.Cache[*codeItem]
+	// 验证码的有效期
+	expiration time.Duration
+	// 多久之后才能重新发送
+	resendInterval time.Duration
+	// 最多验证几次
+	maxAttempts int
+}
+
+type codeItem struct {
+	code string
+	// cnt 还可以验证几次, 验证成功之后是 -1
+	cnt   int
+	ctime time.Time
+}
+
+// CodeLocalCacheOption 在创建后台清理之前生效, 不能创建之后再修改
+type CodeLocalCacheOption func(c *CodeLocalCache)
+
+// WithCodeExpiration 验证码的有效期, 默认 10 分钟
+func WithCodeExpiration(expiration time.Duration) CodeLocalCacheOption {
+	return func(c *CodeLocalCache) {
+		c.expiration = expiration
+	}
+}
+
+// NewCodeLocalCache capacity 最多保存的手机号数量, 超过时淘汰最久没有用过的
+func NewCodeLocalCache(capacity int, opts ...CodeLocalCacheOption) *CodeLocalCache {
+	c := &CodeLocalCache{
+		expiration:     time.Minute * 10,
+		resendInterval: time.Minute,
+		maxAttempts:    3,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	// 定期删除过期的验证码, 不然没有再访问的手机号会一直占着内存直到被淘汰
+	c.cache = localcache.New[*codeItem]("code", capacity, c.expiration).StartCleanup(time.Minute)
+	return c
+}
+
+func (c *CodeLocalCache) Set(ctx context.Context, biz, phone, code string) error {
+	key := c.key(biz, phone)
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, ok := c.cache.Get(key)
+	if ok && now.Sub(item.ctime) < c.resendInterval {
+		return ErrCodeSendTooFrequent
+	}
+	c.cache.Set(key, &codeItem{
+		code:  code,
+		cnt:   c.maxAttempts,
+		ctime: now,
+	})
+	return nil
+}
+
+// Verify 验证码不存在或者已经过期时返回 false
+func (c *CodeLocalCache) Verify(ctx context.Context, biz, phone, inputCode string) (bool, error) {
+	key := c.key(biz, phone)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, ok := c.cache.Get(key)
+	if !ok {
+		return false, nil
+	}
+	switch {
+	case item.cnt <= 0:
+		// 一直输错, 或者已经用过了
+		return false, ErrCodeVerifyTooManyTimes
+	case item.code == inputCode:
+		item.cnt = -1
+		return true, nil
+	default:
+		item.cnt--
+		return false, nil
+	}
+}
+
+// Close 停止后台清理过期的验证码
+func (c *CodeLocalCache) Close() error {
+	return c.cache.Close()
 }
 
-func NewCodeLocalCache() *CodeLocalCache {
-	return &CodeLocalCache{}
+func (c *CodeLocalCache) key(biz, phone string) string {
+	return fmt.Sprintf("%s:%s", biz, phone)
 }
@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestCodeLocalCache_Set(t *testing.T) {
+	c := NewCodeLocalCache(10)
+	defer c.Close()
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "login", "152", "123456"))
+	assert.Equal(t, ErrCodeSendTooFrequent, c.Set(ctx, "login", "152", "654321"))
+	// 不同业务互不影响
+	assert.NoError(t, c.Set(ctx, "register", "152", "654321"))
+
+	// 超过重新发送的间隔
+	c.resendInterval = 0
+	require.NoError(t, c.Set(ctx, "login", "152", "654321"))
+	ok, err := c.Verify(ctx, "login", "152", "654321")
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestCodeLocalCache_Verify(t *testing.T) {
+	testCases := []struct {
+		name   string
+		inputs []string
+		wantOk bool
+		// 最后一次验证的错误
+		wantErr error
+	}{
+		{
+			name:   "验证成功",
+			inputs: []string{"123456"},
+			wantOk: true,
+		},
+		{
+			name:   "输错之后验证成功",
+			inputs: []string{"111111", "111111", "123456"},
+			wantOk: true,
+		},
+		{
+			name:    "输错三次",
+			inputs:  []string{"111111", "111111", "111111", "123456"},
+			wantErr: ErrCodeVerifyTooManyTimes,
+		},
+		{
+			name:    "验证码用过了",
+			inputs:  []string{"123456", "123456"},
+			wantErr: ErrCodeVerifyTooManyTimes,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewCodeLocalCache(10)
+			defer c.Close()
+			ctx := context.Background()
+			require.NoError(t, c.Set(ctx, "login", "152", "123456"))
+			var (
+				ok  bool
+				err error
+			)
+			for _, input := range tc.inputs {
+				ok, err = c.Verify(ctx, "login", "152", input)
+			}
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantOk, ok)
+		})
+	}
+}
+
+func TestCodeLocalCache_Expire(t *testing.T) {
+	c := NewCodeLocalCache(10, WithCodeExpiration(time.Millisecond))
+	defer c.Close()
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "login", "152", "123456"))
+	time.Sleep(time.Millisecond * 5)
+	ok, err := c.Verify(ctx, "login", "152", "123456")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	// 过期之后可以重新发送
+	assert.NoError(t, c.Set(ctx, "login", "152", "123456"))
+}
@@ -26,12 +26,14 @@ const fieldCollectCnt = "collect_cnt"
 
 type InteractiveCache interface {
 	IncrReadCntIfPresent(ctx context.Context, biz string, bizId int64) error
+	BatchIncrReadCntIfPresent(ctx context.Context, bizs []string, bizIds []int64) error
 	IncrLikeCntIfPresent(ctx context.Context, biz string, bizId int64) error
 	IncrCollectCntIfPresent(ctx context.Context, biz string, bizId int64) error
 	DecrLikeCntIfPresent(ctx context.Context, biz string, bizId int64) error
 	DecrCollectCntIfPresent(ctx context.Context, biz string, bizId int64) error
 	SetInteractiveInfo(ctx context.Context, biz string, bizId int64, info domain.Interactive) error
 	GetInteractiveInfo(ctx context.Context, biz string, bizId int64) (domain.Interactive, error)
+	DelInteractiveInfo(ctx context.Context, biz string, bizId int64) error
 }
 
 type InteractiveRedisCache struct {
@@ -45,12 +47,13 @@ func (cache *InteractiveRedisCache) SetInteractiveInfo(ctx context.Context, biz
 
 func (cache *InteractiveRedisCache) GetInteractiveInfo(ctx context.Context, biz string, bizId int64) (domain.Interactive, error) {
 	result, err := cache.client.HGetAll(ctx, cache.key(biz, bizId)).Result()
+	if err == nil && len(result) == 0 {
+		err = ErrNotExistKey
+	}
+	observeGet("interactive", err)
 	if err != nil {
 		return domain.Interactive{}, err
 	}
-	if len(result) == 0 {
-		return domain.Interactive{}, ErrNotExistKey
-	}
 	var res domain.Interactive
 	res.ReadCnt, err = strconv.ParseInt(result[fieldReadCnt], 10, 64)
 	res.LikeCnt, err = strconv.ParseInt(result[fieldLikeCnt], 10, 64)
@@ -59,6 +62,10 @@ func (cache *InteractiveRedisCache) GetInteractiveInfo(ctx context.Context, biz
 	return res, nil
 }
 
+func (cache *InteractiveRedisCache) DelInteractiveInfo(ctx context.Context, biz string, bizId int64) error {
+	return cache.client.Del(ctx, cache.key(biz, bizId)).Err()
+}
+
 func (cache *InteractiveRedisCache) IncrLikeCntIfPresent(ctx context.Context, biz string, bizId int64) error {
 	return cache.client.Eval(ctx, luaIncrCnt, []string{cache.key(biz, bizId)}, fieldLikeCnt, 1).Err()
 }
@@ -79,6 +86,16 @@ func (cache *InteractiveRedisCache) IncrReadCntIfPresent(ctx context.Context, bi
 	return cache.client.Eval(ctx, luaIncrCnt, []string{cache.key(biz, bizId)}, fieldReadCnt, 1).Err()
 }
 
+// BatchIncrReadCntIfPresent 使用 pipeline 批量执行, 减少网络往返
+func (cache *InteractiveRedisCache) BatchIncrReadCntIfPresent(ctx context.Context, bizs []string, bizIds []int64) error {
+	pipe := cache.client.Pipeline()
+	for i := range bizs {
+		pipe.Eval(ctx, luaIncrCnt, []string{cache.key(bizs[i], bizIds[i])}, fieldReadCnt, 1)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
 func (cache *InteractiveRedisCache) key(biz string, bizId int64) string {
 	return fmt.Sprintf("interactive:%s:%d", biz, bizId)
 }
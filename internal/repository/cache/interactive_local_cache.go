@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/pkg/localcache"
+	"time"
+)
+
+const interactiveLocalCacheName = "interactive"
+
+// InteractiveLocalCache 在 InteractiveCache 前面加一层本地缓存.
+// 计数变化的时候直接删除本地缓存, 下次从 redis 重新加载
+type InteractiveLocalCache struct {
+	redis InteractiveCache
+	local *localcache.Cache[domain.Interactive]
+	inv   *Invalidator
+}
+
+func NewInteractiveLocalCache(redis InteractiveCache, inv *Invalidator, size int, ttl time.Duration) *InteractiveLocalCache {
+	local := localcache.New[domain.Interactive](interactiveLocalCacheName, size, ttl).StartCleanup(ttl)
+	inv.Register(interactiveLocalCacheName, local.Delete)
+	return &InteractiveLocalCache{
+		redis: redis,
+		local: local,
+		inv:   inv,
+	}
+}
+
+// Close 停止清理过期的 key
+func (cache *InteractiveLocalCache) Close() error {
+	return cache.local.Close()
+}
+
+func (cache *InteractiveLocalCache) IncrReadCntIfPresent(ctx context.Context, biz string, bizId int64) error {
+	defer cache.invalidate(ctx, cache.key(biz, bizId))
+	return cache.redis.IncrReadCntIfPresent(ctx, biz, bizId)
+}
+
+func (cache *InteractiveLocalCache) BatchIncrReadCntIfPresent(ctx context.Context, bizs []string, bizIds []int64) error {
+	keys := make([]string, len(bizs))
+	for i := range bizs {
+		keys[i] = cache.key(bizs[i], bizIds[i])
+	}
+	defer cache.invalidate(ctx, keys...)
+	return cache.redis.BatchIncrReadCntIfPresent(ctx, bizs, bizIds)
+}
+
+func (cache *InteractiveLocalCache) IncrLikeCntIfPresent(ctx context.Context, biz string, bizId int64) error {
+	defer cache.invalidate(ctx, cache.key(biz, bizId))
+	return cache.redis.IncrLikeCntIfPresent(ctx, biz, bizId)
+}
+
+func (cache *InteractiveLocalCache) IncrCollectCntIfPresent(ctx context.Context, biz string, bizId int64) error {
+	defer cache.invalidate(ctx, cache.key(biz, bizId))
+	return cache.redis.IncrCollectCntIfPresent(ctx, biz, bizId)
+}
+
+func (cache *InteractiveLocalCache) DecrLikeCntIfPresent(ctx context.Context, biz string, bizId int64) error {
+	defer cache.invalidate(ctx, cache.key(biz, bizId))
+	return cache.redis.DecrLikeCntIfPresent(ctx, biz, bizId)
+}
+
+func (cache *InteractiveLocalCache) DecrCollectCntIfPresent(ctx context.Context, biz string, bizId int64) error {
+	defer cache.invalidate(ctx, cache.key(biz, bizId))
+	return cache.redis.DecrCollectCntIfPresent(ctx, biz, bizId)
+}
+
+func (cache *InteractiveLocalCache) SetInteractiveInfo(ctx context.Context, biz string, bizId int64, info domain.Interactive) error {
+	key := cache.key(biz, bizId)
+	err := cache.redis.SetInteractiveInfo(ctx, biz, bizId, info)
+	if err != nil {
+		cache.local.Delete(key)
+		return err
+	}
+	cache.local.Set(key, info)
+	cache.inv.Publish(ctx, interactiveLocalCacheName, key)
+	return nil
+}
+
+func (cache *InteractiveLocalCache) GetInteractiveInfo(ctx context.Context, biz string, bizId int64) (domain.Interactive, error) {
+	key := cache.key(biz, bizId)
+	if info, ok := cache.local.Get(key); ok {
+		return info, nil
+	}
+	info, err := cache.redis.GetInteractiveInfo(ctx, biz, bizId)
+	if err != nil {
+		return domain.Interactive{}, err
+	}
+	cache.local.Set(key, info)
+	return info, nil
+}
+
+func (cache *InteractiveLocalCache) DelInteractiveInfo(ctx context.Context, biz string, bizId int64) error {
+	defer cache.invalidate(ctx, cache.key(biz, bizId))
+	return cache.redis.DelInteractiveInfo(ctx, biz, bizId)
+}
+
+// invalidate 在 redis 修改之后删除本机和其他实例的本地缓存
+func (cache *InteractiveLocalCache) invalidate(ctx context.Context, keys ...string) {
+	cache.local.Delete(keys...)
+	cache.inv.Publish(ctx, interactiveLocalCacheName, keys...)
+}
+
+func (cache *InteractiveLocalCache) key(biz string, bizId int64) string {
+	return fmt.Sprintf("%s:%d", biz, bizId)
+}
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"sync"
+)
+
+// Invalidator 通过 redis 的发布订阅通知其他实例删除本地缓存.
+// 断线期间的通知会丢失, 所以本地缓存的过期时间不能太长
+type Invalidator struct {
+	client  redis.UniversalClient
+	channel string
+	// node 区分自己发出的通知, 自己的本地缓存在写的时候已经更新了
+	node     string
+	mu       sync.RWMutex
+	handlers map[string]func(keys ...string)
+}
+
+type invalidateMsg struct {
+	Node  string   `json:"node"`
+	Cache string   `json:"cache"`
+	Keys  []string `json:"keys"`
+}
+
+func NewInvalidator(client redis.UniversalClient, channel string) *Invalidator {
+	return &Invalidator{
+		client:   client,
+		channel:  channel,
+		node:     uuid.New().String(),
+		handlers: make(map[string]func(keys ...string)),
+	}
+}
+
+// Register 收到缓存 name 的通知时调用 fn 删除本地的 key
+func (inv *Invalidator) Register(name string, fn func(keys ...string)) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	inv.handlers[name] = fn
+}
+
+// Publish 通知其他实例删除缓存 name 的 keys, 失败只记日志, 其他实例等本地缓存过期
+func (inv *Invalidator) Publish(ctx context.Context, name string, keys ...string) {
+	val, err := json.Marshal(invalidateMsg{
+		Node:  inv.node,
+		Cache: name,
+		Keys:  keys,
+	})
+	if err == nil {
+		err = inv.client.Publish(ctx, inv.channel, val).Err()
+	}
+	if err != nil {
+		zap.L().Error("发送本地缓存失效通知失败", zap.String("cache", name), zap.Error(err))
+	}
+}
+
+// Run 订阅失效通知, 直到 ctx 被取消. 断线后 go-redis 会自动重新订阅
+func (inv *Invalidator) Run(ctx context.Context) error {
+	pubsub := inv.client.Subscribe(ctx, inv.channel)
+	defer pubsub.Close()
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			inv.handle(msg.Payload)
+		}
+	}
+}
+
+func (inv *Invalidator) handle(payload string) {
+	var msg invalidateMsg
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		zap.L().Error("解析本地缓存失效通知失败", zap.Error(err))
+		return
+	}
+	if msg.Node == inv.node {
+		return
+	}
+	inv.mu.RLock()
+	fn, ok := inv.handlers[msg.Cache]
+	inv.mu.RUnlock()
+	if ok {
+		fn(msg.Keys...)
+	}
+}
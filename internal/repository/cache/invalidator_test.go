@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestUserLocalCache_Invalidate(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 两个实例共用一个 redis
+	caches := make([]*UserLocalCache, 2)
+	for i := range caches {
+		inv := NewInvalidator(client, "test:invalidate")
+		go func() {
+			_ = inv.Run(ctx)
+		}()
+		caches[i] = NewUserLocalCache(NewUserRedisCache(client), inv, 10, time.Minute)
+	}
+	// 等待订阅生效
+	require.Eventually(t, func() bool {
+		return mr.PubSubNumSub("test:invalidate")["test:invalidate"] == 2
+	}, time.Second, time.Millisecond*10)
+
+	require.NoError(t, caches[0].Set(ctx, domain.User{Id: 1, Email: "old@qq.com"}))
+	u, err := caches[1].Get(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "old@qq.com", u.Email)
+
+	// 实例 0 修改之后, 实例 1 的本地缓存被删除, 重新从 redis 读到新的值
+	require.NoError(t, caches[0].Set(ctx, domain.User{Id: 1, Email: "new@qq.com"}))
+	assert.Eventually(t, func() bool {
+		u, err := caches[1].Get(ctx, 1)
+		return err == nil && u.Email == "new@qq.com"
+	}, time.Second, time.Millisecond*10)
+	u, err = caches[0].Get(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "new@qq.com", u.Email)
+}
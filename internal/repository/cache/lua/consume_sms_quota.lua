@@ -0,0 +1,31 @@
+-- 本月用量 sms:token_usage:{1}:202401
+local quotaKey = KEYS[1]
+-- 这一分钟的请求次数 sms:token_usage:{1}:rate:28401234
+local rateKey = KEYS[2]
+-- 这次发送的号码数
+local cnt = tonumber(ARGV[1])
+-- 0 表示不限制
+local quota = tonumber(ARGV[2])
+local rate = tonumber(ARGV[3])
+local quotaTTL = tonumber(ARGV[4])
+
+if rate > 0 then
+    local used = tonumber(redis.call("GET", rateKey) or "0")
+    if used >= rate then
+        -- 太频繁
+        return -1
+    end
+end
+if quota > 0 then
+    local used = tonumber(redis.call("GET", quotaKey) or "0")
+    if used + cnt > quota then
+        -- 额度不够
+        return -2
+    end
+end
+-- 两个都检查通过之后才增加, 被拒绝的请求不占用额度
+redis.call("INCR", rateKey)
+redis.call("EXPIRE", rateKey, 120)
+redis.call("INCRBY", quotaKey, cnt)
+redis.call("EXPIRE", quotaKey, quotaTTL)
+return 0
@@ -0,0 +1,30 @@
+package cache
+
+import (
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+// cacheCounter 统计缓存命中情况, result 为 hit, miss, error
+var cacheCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "redbook",
+	Name:      "cache_requests_total",
+	Help:      "缓存查询次数",
+}, []string{"cache", "result"})
+
+func init() {
+	prometheus.MustRegister(cacheCounter)
+}
+
+func observeGet(name string, err error) {
+	result := "hit"
+	switch {
+	case err == nil:
+	case errors.Is(err, redis.Nil), errors.Is(err, ErrNotExistKey):
+		result = "miss"
+	default:
+		result = "error"
+	}
+	cacheCounter.WithLabelValues(name, result).Inc()
+}
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/lutcoding/redbook/common/globalkey"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/redis/go-redis/v9"
+	"sync"
+	"time"
+)
+
+var ErrLocalRankingExpired = errors.New("本地热榜缓存不存在或已过期")
+
+type RankingCache interface {
+	Set(ctx context.Context, arts []domain.Article) error
+	Get(ctx context.Context) ([]domain.Article, error)
+}
+
+// RankingRedisCache 热榜只存摘要, 不存内容. 传进来的文章已经把内容换成了摘要
+type RankingRedisCache struct {
+	client     redis.Cmdable
+	expiration time.Duration
+}
+
+func NewRankingRedisCache(client redis.Cmdable) *RankingRedisCache {
+	return &RankingRedisCache{
+		client: client,
+		// 比计算周期长, 计算失败时还能用旧的榜单
+		expiration: time.Minute * 10,
+	}
+}
+
+func (cache *RankingRedisCache) Set(ctx context.Context, arts []domain.Article) error {
+	// 同一个切片也放进了本地缓存, 正在被读取, 不能修改
+	val, err := json.Marshal(arts)
+	if err != nil {
+		return err
+	}
+	return cache.client.Set(ctx, globalkey.HotArticleCacheKey, val, cache.expiration).Err()
+}
+
+func (cache *RankingRedisCache) Get(ctx context.Context) ([]domain.Article, error) {
+	val, err := cache.client.Get(ctx, globalkey.HotArticleCacheKey).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	var res []domain.Article
+	err = json.Unmarshal(val, &res)
+	return res, err
+}
+
+// RankingLocalCache 进程内的热榜, Redis 不可用时兜底
+type RankingLocalCache struct {
+	mu         sync.RWMutex
+	arts       []domain.Article
+	ddl        time.Time
+	expiration time.Duration
+}
+
+func NewRankingLocalCache() *RankingLocalCache {
+	return &RankingLocalCache{
+		expiration: time.Minute * 3,
+	}
+}
+
+func (cache *RankingLocalCache) Set(ctx context.Context, arts []domain.Article) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.arts = arts
+	cache.ddl = time.Now().Add(cache.expiration)
+	return nil
+}
+
+func (cache *RankingLocalCache) Get(ctx context.Context) ([]domain.Article, error) {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+	if len(cache.arts) == 0 || time.Now().After(cache.ddl) {
+		return nil, ErrLocalRankingExpired
+	}
+	return cache.arts, nil
+}
+
+// ForceGet 忽略过期时间, 只要有数据就返回
+func (cache *RankingLocalCache) ForceGet(ctx context.Context) ([]domain.Article, error) {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+	if len(cache.arts) == 0 {
+		return nil, ErrLocalRankingExpired
+	}
+	return cache.arts, nil
+}
@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lutcoding/redbook/common/globalkey"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrSmsRateLimited    = errors.New("短信发送太频繁, 超过了每分钟的次数")
+	ErrSmsQuotaExhausted = errors.New("本月的短信额度已经用完")
+)
+
+// smsUsageExpiration 本月用量保留到下个月, 方便对账
+const smsUsageExpiration = time.Hour * 24 * 62
+
+//go:embed "lua/consume_sms_quota.lua"
+var luaConsumeSmsQuota string
+
+type SmsTokenCache interface {
+	Get(ctx context.Context, id int64) (domain.SmsToken, error)
+	Set(ctx context.Context, t domain.SmsToken) error
+	Del(ctx context.Context, id int64) error
+	// Consume 原子地检查并增加本月用量和这一分钟的请求次数, 超过限制时都不增加
+	Consume(ctx context.Context, t domain.SmsToken, cnt int64, now time.Time) error
+	// Refund 发送失败时退回本月用量, 请求次数不退
+	Refund(ctx context.Context, id int64, cnt int64, now time.Time) error
+	// Usages 本月用量, 和 ids 一一对应
+	Usages(ctx context.Context, ids []int64, now time.Time) ([]int64, error)
+}
+
+type SmsTokenRedisCache struct {
+	client     redis.Cmdable
+	expiration time.Duration
+}
+
+func NewSmsTokenRedisCache(client redis.Cmdable) *SmsTokenRedisCache {
+	return &SmsTokenRedisCache{
+		client:     client,
+		expiration: time.Minute * 10,
+	}
+}
+
+func (cache *SmsTokenRedisCache) Get(ctx context.Context, id int64) (domain.SmsToken, error) {
+	val, err := cache.client.Get(ctx, cache.key(id)).Bytes()
+	observeGet("sms_token", err)
+	if err != nil {
+		return domain.SmsToken{}, err
+	}
+	var t domain.SmsToken
+	err = json.Unmarshal(val, &t)
+	return t, err
+}
+
+func (cache *SmsTokenRedisCache) Set(ctx context.Context, t domain.SmsToken) error {
+	val, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return cache.client.Set(ctx, cache.key(t.Id), val, cache.expiration).Err()
+}
+
+func (cache *SmsTokenRedisCache) Del(ctx context.Context, id int64) error {
+	return cache.client.Del(ctx, cache.key(id)).Err()
+}
+
+func (cache *SmsTokenRedisCache) Consume(ctx context.Context, t domain.SmsToken, cnt int64, now time.Time) error {
+	keys := []string{cache.usageKey(t.Id, now), cache.rateKey(t.Id, now)}
+	res, err := cache.client.Eval(ctx, luaConsumeSmsQuota, keys,
+		cnt, t.MonthlyQuota, t.RatePerMinute, int64(smsUsageExpiration.Seconds())).Int()
+	if err != nil {
+		return err
+	}
+	switch res {
+	case 0:
+		return nil
+	case -1:
+		return ErrSmsRateLimited
+	case -2:
+		return ErrSmsQuotaExhausted
+	default:
+		return ErrUnknown
+	}
+}
+
+func (cache *SmsTokenRedisCache) Refund(ctx context.Context, id int64, cnt int64, now time.Time) error {
+	return cache.client.DecrBy(ctx, cache.usageKey(id, now), cnt).Err()
+}
+
+func (cache *SmsTokenRedisCache) Usages(ctx context.Context, ids []int64, now time.Time) ([]int64, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = cache.usageKey(id, now)
+	}
+	vals, err := cache.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]int64, len(ids))
+	for i, val := range vals {
+		// 本月还没有用过时是 nil
+		str, ok := val.(string)
+		if !ok {
+			continue
+		}
+		res[i], err = strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (cache *SmsTokenRedisCache) key(id int64) string {
+	return globalkey.SmsTokenCachePrefix + strconv.FormatInt(id, 10)
+}
+
+// usageKey 按自然月统计
+func (cache *SmsTokenRedisCache) usageKey(id int64, now time.Time) string {
+	return fmt.Sprintf("%s{%d}:%s", globalkey.SmsTokenUsagePrefix, id, now.Format("200601"))
+}
+
+func (cache *SmsTokenRedisCache) rateKey(id int64, now time.Time) string {
+	return fmt.Sprintf("%s{%d}:rate:%d", globalkey.SmsTokenUsagePrefix, id, now.Unix()/60)
+}
@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestSmsTokenRedisCache_Consume(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cache := NewSmsTokenRedisCache(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	ctx := context.Background()
+	token := domain.SmsToken{Id: 1, MonthlyQuota: 10, RatePerMinute: 3}
+	now := time.Date(2024, 1, 31, 23, 58, 0, 0, time.Local)
+
+	require.NoError(t, cache.Consume(ctx, token, 4, now))
+	// 额度不够时不增加用量和次数
+	assert.Equal(t, ErrSmsQuotaExhausted, cache.Consume(ctx, token, 7, now))
+	require.NoError(t, cache.Consume(ctx, token, 6, now))
+	assert.Equal(t, ErrSmsQuotaExhausted, cache.Consume(ctx, token, 1, now))
+
+	// 失败退回之后可以继续发送
+	require.NoError(t, cache.Refund(ctx, 1, 2, now))
+	require.NoError(t, cache.Consume(ctx, token, 1, now))
+	// 这一分钟已经请求了 3 次
+	assert.Equal(t, ErrSmsRateLimited, cache.Consume(ctx, token, 1, now))
+
+	// 下一分钟恢复, 下个月重新计算额度
+	require.NoError(t, cache.Consume(ctx, token, 1, now.Add(time.Minute)))
+	require.NoError(t, cache.Consume(ctx, token, 10, now.Add(time.Minute*3)))
+
+	usages, err := cache.Usages(ctx, []int64{1, 2}, now)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{10, 0}, usages)
+	usages, err = cache.Usages(ctx, []int64{1}, now.Add(time.Minute*3))
+	require.NoError(t, err)
+	assert.Equal(t, []int64{10}, usages)
+
+	// 不限制
+	unlimited := domain.SmsToken{Id: 3}
+	for i := 0; i < 10; i++ {
+		require.NoError(t, cache.Consume(ctx, unlimited, 1000, now))
+	}
+}
@@ -34,6 +34,7 @@ func NewUserRedisCache(client redis.Cmdable) *UserRedisCache {
 func (cache *UserRedisCache) Get(ctx context.Context, id int64) (domain.User, error) {
 	key := cache.key(id)
 	val, err := cache.client.Get(ctx, key).Bytes()
+	observeGet("user", err)
 	if err != nil {
 		return domain.User{}, err
 	}
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"context"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/pkg/localcache"
+	"strconv"
+	"time"
+)
+
+const userLocalCacheName = "user"
+
+// UserLocalCache 在 UserCache 前面加一层本地缓存, 写的时候通知其他实例删除本地缓存
+type UserLocalCache struct {
+	redis UserCache
+	local *localcache.Cache[domain.User]
+	inv   *Invalidator
+}
+
+func NewUserLocalCache(redis UserCache, inv *Invalidator, size int, ttl time.Duration) *UserLocalCache {
+	// 每个 ttl 清理一次过期的 key, 它们最多多占一个 ttl 的内存
+	local := localcache.New[domain.User](userLocalCacheName, size, ttl).StartCleanup(ttl)
+	inv.Register(userLocalCacheName, local.Delete)
+	return &UserLocalCache{
+		redis: redis,
+		local: local,
+		inv:   inv,
+	}
+}
+
+// Close 停止清理过期的 key
+func (cache *UserLocalCache) Close() error {
+	return cache.local.Close()
+}
+
+func (cache *UserLocalCache) Get(ctx context.Context, id int64) (domain.User, error) {
+	key := strconv.FormatInt(id, 10)
+	if u, ok := cache.local.Get(key); ok {
+		return u, nil
+	}
+	u, err := cache.redis.Get(ctx, id)
+	if err != nil {
+		return domain.User{}, err
+	}
+	cache.local.Set(key, u)
+	return u, nil
+}
+
+func (cache *UserLocalCache) Set(ctx context.Context, u domain.User) error {
+	key := strconv.FormatInt(u.Id, 10)
+	err := cache.redis.Set(ctx, u)
+	if err != nil {
+		cache.local.Delete(key)
+		return err
+	}
+	cache.local.Set(key, u)
+	cache.inv.Publish(ctx, userLocalCacheName, key)
+	return nil
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/internal/repository/dao"
+)
+
+var (
+	ErrDuplicateCollection = dao.ErrDuplicateCollection
+	ErrCollectionNotFound  = dao.ErrCollectionNotFound
+)
+
+type CollectionRepository interface {
+	Create(ctx context.Context, c domain.Collection) (int64, error)
+	List(ctx context.Context, uid int64) ([]domain.Collection, error)
+	ListItems(ctx context.Context, uid, cid int64, limit, offset int) ([]domain.CollectItem, error)
+	MoveItem(ctx context.Context, uid int64, biz string, bizId int64, cid int64) error
+}
+
+type CollectionCacheRepository struct {
+	dao dao.CollectionDAO
+}
+
+func NewCollectionCacheRepository(dao dao.CollectionDAO) *CollectionCacheRepository {
+	return &CollectionCacheRepository{
+		dao: dao,
+	}
+}
+
+func (repo *CollectionCacheRepository) Create(ctx context.Context, c domain.Collection) (int64, error) {
+	return repo.dao.Insert(ctx, dao.Collection{
+		Uid:  c.Uid,
+		Name: c.Name,
+	})
+}
+
+func (repo *CollectionCacheRepository) List(ctx context.Context, uid int64) ([]domain.Collection, error) {
+	cs, err := repo.dao.ListByUid(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Collection, len(cs))
+	for i, c := range cs {
+		res[i] = domain.Collection{
+			Id:   c.Id,
+			Uid:  c.Uid,
+			Name: c.Name,
+		}
+	}
+	return res, nil
+}
+
+func (repo *CollectionCacheRepository) ListItems(ctx context.Context, uid, cid int64, limit, offset int) ([]domain.CollectItem, error) {
+	infos, err := repo.dao.ListCollectInfo(ctx, uid, cid, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.CollectItem, len(infos))
+	for i, info := range infos {
+		res[i] = domain.CollectItem{
+			Cid:   info.Cid,
+			Biz:   info.Biz,
+			BizId: info.BizId,
+			Ctime: info.CreateTime,
+		}
+	}
+	return res, nil
+}
+
+func (repo *CollectionCacheRepository) MoveItem(ctx context.Context, uid int64, biz string, bizId int64, cid int64) error {
+	return repo.dao.MoveCollectInfo(ctx, uid, biz, bizId, cid)
+}
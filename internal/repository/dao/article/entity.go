@@ -1,25 +1,85 @@
 package article
 
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
 type Article struct {
-	Id      int64  `gorm:"primaryKey, autoIncrement" bson:"id,omitempty"`
-	Tittle  string `gorm:"type=varchar(1024)" bson:"tittle,omitempty"`
-	Content string `gorm:"type=BLOB" bson:"content,omitempty"`
+	Id      int64       `gorm:"primaryKey, autoIncrement" bson:"id,omitempty"`
+	Tittle  string      `gorm:"type=varchar(1024)" bson:"tittle,omitempty"`
+	Content string      `gorm:"type=BLOB" bson:"content,omitempty"`
+	Cover   string      `gorm:"type:varchar(256)" bson:"cover,omitempty"`
+	Images  StringSlice `gorm:"type:varchar(4096)" bson:"images,omitempty"`
 
 	Status   uint8 `bson:"status,omitempty"`
 	AuthorId int64 `gorm:"index" bson:"author_id,omitempty"`
+	// PublishAt 定时发表的时间, 毫秒
+	PublishAt int64 `gorm:"index" bson:"publish_at,omitempty"`
+	// PrevStatus 放进回收站之前的状态, 恢复时使用
+	PrevStatus uint8 `bson:"prev_status,omitempty"`
+	// DeleteTime 放进回收站的时间, 毫秒
+	DeleteTime int64 `gorm:"index" bson:"delete_time,omitempty"`
 
 	CreateTime int64 `bson:"create_time,omitempty"`
 	UpdateTime int64 `bson:"update_time,omitempty"`
 }
 
 type PublishArticle struct {
-	Id      int64  `gorm:"primaryKey, autoIncrement" bson:"id,omitempty"`
-	Tittle  string `gorm:"type=varchar(1024)" bson:"tittle,omitempty"`
-	Content string `gorm:"type=BLOB" bson:"content,omitempty"`
+	Id      int64       `gorm:"primaryKey, autoIncrement" bson:"id,omitempty"`
+	Tittle  string      `gorm:"type=varchar(1024)" bson:"tittle,omitempty"`
+	Content string      `gorm:"type=BLOB" bson:"content,omitempty"`
+	Cover   string      `gorm:"type:varchar(256)" bson:"cover,omitempty"`
+	Images  StringSlice `gorm:"type:varchar(4096)" bson:"images,omitempty"`
 
 	Status   uint8 `bson:"status,omitempty"`
 	AuthorId int64 `gorm:"index" bson:"author_id,omitempty"`
+	// PrevStatus 和制作库一样, 放进回收站之前的状态
+	PrevStatus uint8 `bson:"prev_status,omitempty"`
 
 	CreateTime int64 `bson:"create_time,omitempty"`
 	UpdateTime int64 `bson:"update_time,omitempty"`
 }
+
+// newPublishArticle 发表时把制作库的文章复制到线上库
+func newPublishArticle(art Article) PublishArticle {
+	return PublishArticle{
+		Id:         art.Id,
+		Tittle:     art.Tittle,
+		Content:    art.Content,
+		Cover:      art.Cover,
+		Images:     art.Images,
+		Status:     art.Status,
+		AuthorId:   art.AuthorId,
+		CreateTime: art.CreateTime,
+		UpdateTime: art.UpdateTime,
+	}
+}
+
+// StringSlice 在 mysql 里以 JSON 数组保存, mongo 里直接保存为数组
+type StringSlice []string
+
+func (s StringSlice) Value() (driver.Value, error) {
+	if s == nil {
+		return "[]", nil
+	}
+	val, err := json.Marshal(s)
+	return string(val), err
+}
+
+func (s *StringSlice) Scan(src any) error {
+	var val []byte
+	switch v := src.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case []byte:
+		val = v
+	case string:
+		val = []byte(v)
+	default:
+		return errors.New("StringSlice 不支持的类型")
+	}
+	return json.Unmarshal(val, s)
+}
@@ -10,6 +10,10 @@ import (
 	"time"
 )
 
+// removedStatuses 在回收站里或者正在彻底删除, 作者不能再修改.
+// 不能用 []uint8, gorm 会把它当成 []byte
+var removedStatuses = []int{int(domain.ArticleStatusTrashed), int(domain.ArticleStatusPurging)}
+
 type GORMArticleDao struct {
 	db *gorm.DB
 }
@@ -30,11 +34,14 @@ func (dao *GORMArticleDao) Insert(ctx context.Context, art Article) (int64, erro
 func (dao *GORMArticleDao) Update(ctx context.Context, art Article) error {
 	now := time.Now().UnixMilli()
 	res := dao.db.Model(&art).WithContext(ctx).
-		Where("id = ? AND author_id = ?", art.Id, art.AuthorId).
+		Where("id = ? AND author_id = ? AND status NOT IN ?", art.Id, art.AuthorId, removedStatuses).
 		Updates(map[string]any{
 			"tittle":      art.Tittle,
 			"content":     art.Content,
+			"cover":       art.Cover,
+			"images":      art.Images,
 			"status":      art.Status,
+			"publish_at":  art.PublishAt,
 			"update_time": now,
 		})
 	if res.Error != nil {
@@ -53,6 +60,8 @@ func (dao *GORMArticleDao) Upsert(ctx context.Context, art PublishArticle) error
 		DoUpdates: clause.Assignments(map[string]interface{}{
 			"tittle":      art.Tittle,
 			"content":     art.Content,
+			"cover":       art.Cover,
+			"images":      art.Images,
 			"status":      art.Status,
 			"update_time": now,
 		}),
@@ -75,16 +84,7 @@ func (dao *GORMArticleDao) Sync(ctx context.Context, art Article) (int64, error)
 			return err
 		}
 		art.Id = id
-		err = txDAO.Upsert(ctx, PublishArticle{
-			Id:         art.Id,
-			Tittle:     art.Tittle,
-			Content:    art.Content,
-			Status:     art.Status,
-			AuthorId:   art.AuthorId,
-			CreateTime: art.CreateTime,
-			UpdateTime: art.UpdateTime,
-		})
-		return err
+		return txDAO.Upsert(ctx, newPublishArticle(art))
 	})
 	return art.Id, err
 }
@@ -93,7 +93,7 @@ func (dao *GORMArticleDao) SyncStatus(ctx context.Context, id int64, authorId in
 	now := time.Now().UnixMilli()
 	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		res := tx.Model(&Article{}).
-			Where("id = ? AND author_id = ?", id, authorId).
+			Where("id = ? AND author_id = ? AND status NOT IN ?", id, authorId, removedStatuses).
 			Updates(map[string]any{
 				"status":      status,
 				"update_time": now,
@@ -118,7 +118,7 @@ func (dao *GORMArticleDao) SyncStatus(ctx context.Context, id int64, authorId in
 func (dao *GORMArticleDao) GetDraftPageByAuthor(ctx context.Context, uid int64, limit, offset int) ([]Article, error) {
 	var arts []Article
 	err := dao.db.WithContext(ctx).
-		Where("author_id = ?", uid).
+		Where("author_id = ? AND status NOT IN ?", uid, removedStatuses).
 		Limit(limit).Offset(offset).
 		Clauses(clause.OrderBy{Columns: []clause.OrderByColumn{
 			{Column: clause.Column{Name: "update_time"}, Desc: true},
@@ -160,6 +160,172 @@ func (dao *GORMArticleDao) GetPubPageByTime(ctx context.Context, start time.Time
 	const ArticleStatusPublished = 2
 	err := dao.db.WithContext(ctx).
 		Where("update_time < ? AND status = ?", start.UnixMilli(), ArticleStatusPublished).
-		Limit(limit).Offset(offset).Find(&arts).Error
+		Limit(limit).Offset(offset).
+		Clauses(clause.OrderBy{Columns: []clause.OrderByColumn{
+			{Column: clause.Column{Name: "update_time"}, Desc: true},
+		}}).
+		Find(&arts).Error
+	return arts, err
+}
+
+func (dao *GORMArticleDao) CancelSchedule(ctx context.Context, id int64, authorId int64) error {
+	res := dao.db.WithContext(ctx).Model(&Article{}).
+		Where("id = ? AND author_id = ? AND status = ?", id, authorId, domain.ArticleStatusScheduled.ToUint8()).
+		Updates(map[string]any{
+			"status":      domain.ArticleStatusUnPublished.ToUint8(),
+			"publish_at":  0,
+			"update_time": time.Now().UnixMilli(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotScheduled
+	}
+	return nil
+}
+
+func (dao *GORMArticleDao) ListScheduled(ctx context.Context, before int64, afterId int64, limit int) ([]Article, error) {
+	var arts []Article
+	err := dao.db.WithContext(ctx).
+		Where("status = ? AND publish_at <= ? AND id > ?", domain.ArticleStatusScheduled.ToUint8(), before, afterId).
+		Order("id").Limit(limit).
+		Find(&arts).Error
+	return arts, err
+}
+
+func (dao *GORMArticleDao) PublishScheduled(ctx context.Context, id int64, now int64) (bool, error) {
+	return dao.publishScheduled(ctx, id, now, func(tx *gorm.DB, art Article) error {
+		return NewGORMArticleDao(tx).Upsert(ctx, newPublishArticle(art))
+	})
+}
+
+// publishScheduled 和 Sync 一样在一个事务里修改制作库和线上库, publish 负责写线上库.
+// 先用状态和时间作为条件修改制作库, 修改成功的实例才会写线上库, 所以不会重复发表
+func (dao *GORMArticleDao) publishScheduled(ctx context.Context, id int64, now int64,
+	publish func(tx *gorm.DB, art Article) error) (bool, error) {
+	published := false
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&Article{}).
+			Where("id = ? AND status = ? AND publish_at <= ?", id, domain.ArticleStatusScheduled.ToUint8(), now).
+			Updates(map[string]any{
+				"status":      domain.ArticleStatusPublished.ToUint8(),
+				"publish_at":  0,
+				"update_time": now,
+			})
+		if res.Error != nil || res.RowsAffected == 0 {
+			// 已经被其他实例发表, 或者作者取消了
+			return res.Error
+		}
+		var art Article
+		if err := tx.Where("id = ?", id).First(&art).Error; err != nil {
+			return err
+		}
+		if err := publish(tx, art); err != nil {
+			return err
+		}
+		published = true
+		return nil
+	})
+	return published, err
+}
+
+func (dao *GORMArticleDao) Delete(ctx context.Context, id int64, authorId int64, now int64) error {
+	trashed := domain.ArticleStatusTrashed.ToUint8()
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// gorm 按照字段名排序生成 SET, prev_status 在 status 之前, 所以记录的是修改之前的状态
+		res := tx.Model(&Article{}).
+			Where("id = ? AND author_id = ? AND status NOT IN ?", id, authorId, removedStatuses).
+			Updates(map[string]any{
+				"prev_status": gorm.Expr("status"),
+				"status":      trashed,
+				"delete_time": now,
+				"update_time": now,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrRecordNotFound
+		}
+		// 没有发表过的文章线上库没有记录
+		return tx.Model(&PublishArticle{}).
+			Where("id = ? AND status <> ?", id, trashed).
+			Updates(map[string]any{
+				"prev_status": gorm.Expr("status"),
+				"status":      trashed,
+				"update_time": now,
+			}).Error
+	})
+}
+
+func (dao *GORMArticleDao) Restore(ctx context.Context, id int64, authorId int64) error {
+	trashed := domain.ArticleStatusTrashed.ToUint8()
+	now := time.Now().UnixMilli()
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&Article{}).
+			Where("id = ? AND author_id = ? AND status = ?", id, authorId, trashed).
+			Updates(map[string]any{
+				"status":      gorm.Expr("prev_status"),
+				"delete_time": 0,
+				"update_time": now,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrNotTrashed
+		}
+		return tx.Model(&PublishArticle{}).
+			Where("id = ? AND status = ?", id, trashed).
+			Updates(map[string]any{
+				"status":      gorm.Expr("prev_status"),
+				"update_time": now,
+			}).Error
+	})
+}
+
+func (dao *GORMArticleDao) GetTrashPageByAuthor(ctx context.Context, uid int64, limit, offset int) ([]Article, error) {
+	var arts []Article
+	err := dao.db.WithContext(ctx).
+		Where("author_id = ? AND status = ?", uid, domain.ArticleStatusTrashed.ToUint8()).
+		Limit(limit).Offset(offset).
+		Clauses(clause.OrderBy{Columns: []clause.OrderByColumn{
+			{Column: clause.Column{Name: "delete_time"}, Desc: true},
+		}}).
+		Find(&arts).Error
+	return arts, err
+}
+
+func (dao *GORMArticleDao) ListTrashedBefore(ctx context.Context, before int64, afterId int64, limit int) ([]Article, error) {
+	var arts []Article
+	err := dao.db.WithContext(ctx).
+		Where("((status = ? AND delete_time <= ?) OR status = ?) AND id > ?",
+			domain.ArticleStatusTrashed.ToUint8(), before, domain.ArticleStatusPurging.ToUint8(), afterId).
+		Order("id").Limit(limit).
+		Find(&arts).Error
 	return arts, err
 }
+
+func (dao *GORMArticleDao) MarkPurging(ctx context.Context, id int64, before int64) (bool, error) {
+	// 和恢复并发时, 只有还在回收站里才标记
+	res := dao.db.WithContext(ctx).Model(&Article{}).
+		Where("id = ? AND status = ? AND delete_time <= ?", id, domain.ArticleStatusTrashed.ToUint8(), before).
+		Updates(map[string]any{
+			"status":      domain.ArticleStatusPurging.ToUint8(),
+			"update_time": time.Now().UnixMilli(),
+		})
+	return res.RowsAffected > 0, res.Error
+}
+
+func (dao *GORMArticleDao) Purge(ctx context.Context, id int64) error {
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("id = ? AND status = ?", id, domain.ArticleStatusPurging.ToUint8()).
+			Delete(&Article{})
+		if res.Error != nil || res.RowsAffected == 0 {
+			// 和线上库在同一个事务里, 制作库没有了说明已经删除了
+			return res.Error
+		}
+		return tx.Where("id = ?", id).Delete(&PublishArticle{}).Error
+	})
+}
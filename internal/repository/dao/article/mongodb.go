@@ -2,14 +2,19 @@ package article
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/bwmarrin/snowflake"
+	"github.com/lutcoding/redbook/internal/domain"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"time"
 )
 
+// removedStatusesBson 在回收站里或者正在彻底删除, 作者不能再修改
+var removedStatusesBson = bson.A{domain.ArticleStatusTrashed.ToUint8(), domain.ArticleStatusPurging.ToUint8()}
+
 type MongoArticleDao struct {
 	client *mongo.Client
 	col    *mongo.Collection
@@ -18,7 +23,7 @@ type MongoArticleDao struct {
 	node    *snowflake.Node
 }
 
-func NewMangoArticleDao(db *mongo.Database, node *snowflake.Node) *MongoArticleDao {
+func NewMongoArticleDao(db *mongo.Database, node *snowflake.Node) *MongoArticleDao {
 	return &MongoArticleDao{
 		col:     db.Collection("articles"),
 		liveCol: db.Collection("published_articles"),
@@ -35,12 +40,19 @@ func (dao *MongoArticleDao) Insert(ctx context.Context, art Article) (int64, err
 }
 
 func (dao *MongoArticleDao) Update(ctx context.Context, art Article) error {
-	filter := bson.M{"id": art.Id, "author_id": art.AuthorId}
+	filter := bson.M{
+		"id":        art.Id,
+		"author_id": art.AuthorId,
+		"status":    bson.M{"$nin": removedStatusesBson},
+	}
 	update := bson.D{bson.E{Key: "$set", Value: bson.M{
-		"title":       art.Tittle,
+		"tittle":      art.Tittle,
 		"content":     art.Content,
+		"cover":       art.Cover,
+		"images":      art.Images,
 		"update_time": time.Now().UnixMilli(),
 		"status":      art.Status,
+		"publish_at":  art.PublishAt,
 	}}}
 	res, err := dao.col.UpdateOne(ctx, filter, update)
 	if err != nil {
@@ -48,24 +60,323 @@ func (dao *MongoArticleDao) Update(ctx context.Context, art Article) error {
 	}
 
 	// 这边就是校验了 author_id 是不是正确的 ID
-	if res.ModifiedCount == 0 {
+	// 内容没有变化时 ModifiedCount 也是 0, 所以只看有没有匹配上
+	if res.MatchedCount == 0 {
 		return fmt.Errorf("更新数据失败")
 	}
 	return nil
 }
 
+// Sync 先保存制作库, 再同步到线上库.
+// mongo 的事务需要副本集, 这里不使用事务, 线上库写失败时重新发表即可.
 func (dao *MongoArticleDao) Sync(ctx context.Context, art Article) (int64, error) {
-	panic("")
+	var (
+		id  = art.Id
+		err error
+	)
+	if id == 0 {
+		id, err = dao.Insert(ctx, art)
+	} else {
+		err = dao.Update(ctx, art)
+	}
+	if err != nil {
+		return 0, err
+	}
+	art.Id = id
+	return id, dao.Upsert(ctx, newPublishArticle(art))
 }
 
 func (dao *MongoArticleDao) Upsert(ctx context.Context, art PublishArticle) error {
-	//TODO implement me
-	panic("implement me")
+	now := time.Now().UnixMilli()
+	filter := bson.M{"id": art.Id}
+	update := bson.D{
+		bson.E{Key: "$set", Value: bson.M{
+			"tittle":      art.Tittle,
+			"content":     art.Content,
+			"cover":       art.Cover,
+			"images":      art.Images,
+			"status":      art.Status,
+			"author_id":   art.AuthorId,
+			"update_time": now,
+		}},
+		bson.E{Key: "$setOnInsert", Value: bson.M{
+			"create_time": now,
+		}},
+	}
+	_, err := dao.liveCol.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
 }
 
 func (dao *MongoArticleDao) SyncStatus(ctx context.Context, id int64, authorId int64, status uint8) error {
-	//TODO implement me
-	panic("implement me")
+	now := time.Now().UnixMilli()
+	update := bson.D{bson.E{Key: "$set", Value: bson.M{
+		"status":      status,
+		"update_time": now,
+	}}}
+	res, err := dao.col.UpdateOne(ctx, bson.M{
+		"id":        id,
+		"author_id": authorId,
+		"status":    bson.M{"$nin": removedStatusesBson},
+	}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		// 1.没有这篇文章
+		// 有人攻击网站，试图改写他人文章
+		return fmt.Errorf("文章不存在or不是本人文章,无法修改")
+	}
+	_, err = dao.liveCol.UpdateOne(ctx, bson.M{"id": id}, update)
+	return err
+}
+
+func (dao *MongoArticleDao) GetDraftPageByAuthor(ctx context.Context, uid int64, limit, offset int) ([]Article, error) {
+	var arts []Article
+	err := dao.find(ctx, dao.col, bson.M{
+		"author_id": uid,
+		"status":    bson.M{"$nin": removedStatusesBson},
+	}, limit, offset, &arts)
+	return arts, err
+}
+
+func (dao *MongoArticleDao) GetPubPageByAuthor(ctx context.Context, uid int64, limit, offset int) ([]PublishArticle, error) {
+	var arts []PublishArticle
+	err := dao.find(ctx, dao.liveCol, bson.M{
+		"author_id": uid,
+		"status":    domain.ArticleStatusPublished.ToUint8(),
+	}, limit, offset, &arts)
+	return arts, err
+}
+
+func (dao *MongoArticleDao) GetDraftById(ctx context.Context, id int64) (Article, error) {
+	var art Article
+	err := dao.col.FindOne(ctx, bson.M{"id": id}).Decode(&art)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		err = ErrRecordNotFound
+	}
+	return art, err
+}
+
+func (dao *MongoArticleDao) GetPubById(ctx context.Context, id int64) (PublishArticle, error) {
+	var art PublishArticle
+	err := dao.liveCol.FindOne(ctx, bson.M{
+		"id":     id,
+		"status": domain.ArticleStatusPublished.ToUint8(),
+	}).Decode(&art)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		err = ErrRecordNotFound
+	}
+	return art, err
+}
+
+func (dao *MongoArticleDao) GetPubPageByTime(ctx context.Context, start time.Time, limit, offset int) ([]PublishArticle, error) {
+	var arts []PublishArticle
+	err := dao.find(ctx, dao.liveCol, bson.M{
+		"update_time": bson.M{"$lt": start.UnixMilli()},
+		"status":      domain.ArticleStatusPublished.ToUint8(),
+	}, limit, offset, &arts)
+	return arts, err
+}
+
+func (dao *MongoArticleDao) CancelSchedule(ctx context.Context, id int64, authorId int64) error {
+	filter := bson.M{"id": id, "author_id": authorId, "status": domain.ArticleStatusScheduled.ToUint8()}
+	update := bson.D{bson.E{Key: "$set", Value: bson.M{
+		"status":      domain.ArticleStatusUnPublished.ToUint8(),
+		"publish_at":  0,
+		"update_time": time.Now().UnixMilli(),
+	}}}
+	res, err := dao.col.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotScheduled
+	}
+	return nil
+}
+
+func (dao *MongoArticleDao) ListScheduled(ctx context.Context, before int64, afterId int64, limit int) ([]Article, error) {
+	filter := bson.M{
+		"status":     domain.ArticleStatusScheduled.ToUint8(),
+		"publish_at": bson.M{"$lte": before},
+		"id":         bson.M{"$gt": afterId},
+	}
+	opts := options.Find().
+		SetSort(bson.D{bson.E{Key: "id", Value: 1}}).
+		SetLimit(int64(limit))
+	cursor, err := dao.col.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var arts []Article
+	err = cursor.All(ctx, &arts)
+	return arts, err
+}
+
+// PublishScheduled 先用状态和时间作为条件修改制作库, 修改成功的实例才会写线上库, 所以不会重复发表.
+// 写线上库失败时制作库已经是发表状态, 和 Sync 一样需要作者重新发表
+func (dao *MongoArticleDao) PublishScheduled(ctx context.Context, id int64, now int64) (bool, error) {
+	filter := bson.M{
+		"id":         id,
+		"status":     domain.ArticleStatusScheduled.ToUint8(),
+		"publish_at": bson.M{"$lte": now},
+	}
+	update := bson.D{bson.E{Key: "$set", Value: bson.M{
+		"status":      domain.ArticleStatusPublished.ToUint8(),
+		"publish_at":  0,
+		"update_time": now,
+	}}}
+	var art Article
+	err := dao.col.FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&art)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, dao.Upsert(ctx, newPublishArticle(art))
+}
+
+// Delete 用聚合管道更新, 才能把 status 复制到 prev_status.
+// 和 Sync 一样不使用事务, 线上库修改失败时再删除一次即可
+func (dao *MongoArticleDao) Delete(ctx context.Context, id int64, authorId int64, now int64) error {
+	trashed := domain.ArticleStatusTrashed.ToUint8()
+	res, err := dao.col.UpdateOne(ctx, bson.M{
+		"id":        id,
+		"author_id": authorId,
+		"status":    bson.M{"$nin": removedStatusesBson},
+	}, mongo.Pipeline{bson.D{bson.E{Key: "$set", Value: bson.M{
+		"prev_status": "$status",
+		"status":      trashed,
+		"delete_time": now,
+		"update_time": now,
+	}}}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrRecordNotFound
+	}
+	_, err = dao.liveCol.UpdateOne(ctx, bson.M{
+		"id":     id,
+		"status": bson.M{"$ne": trashed},
+	}, mongo.Pipeline{bson.D{bson.E{Key: "$set", Value: bson.M{
+		"prev_status": "$status",
+		"status":      trashed,
+		"update_time": now,
+	}}}})
+	return err
+}
+
+func (dao *MongoArticleDao) Restore(ctx context.Context, id int64, authorId int64) error {
+	trashed := domain.ArticleStatusTrashed.ToUint8()
+	now := time.Now().UnixMilli()
+	res, err := dao.col.UpdateOne(ctx, bson.M{
+		"id":        id,
+		"author_id": authorId,
+		"status":    trashed,
+	}, mongo.Pipeline{bson.D{bson.E{Key: "$set", Value: bson.M{
+		"status":      "$prev_status",
+		"delete_time": 0,
+		"update_time": now,
+	}}}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotTrashed
+	}
+	_, err = dao.liveCol.UpdateOne(ctx, bson.M{
+		"id":     id,
+		"status": trashed,
+	}, mongo.Pipeline{bson.D{bson.E{Key: "$set", Value: bson.M{
+		"status":      "$prev_status",
+		"update_time": now,
+	}}}})
+	return err
+}
+
+func (dao *MongoArticleDao) GetTrashPageByAuthor(ctx context.Context, uid int64, limit, offset int) ([]Article, error) {
+	opts := options.Find().
+		SetSort(bson.D{bson.E{Key: "delete_time", Value: -1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit))
+	cursor, err := dao.col.Find(ctx, bson.M{
+		"author_id": uid,
+		"status":    domain.ArticleStatusTrashed.ToUint8(),
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var arts []Article
+	err = cursor.All(ctx, &arts)
+	return arts, err
+}
+
+func (dao *MongoArticleDao) ListTrashedBefore(ctx context.Context, before int64, afterId int64, limit int) ([]Article, error) {
+	filter := bson.M{
+		"$or": bson.A{
+			bson.M{"status": domain.ArticleStatusTrashed.ToUint8(), "delete_time": bson.M{"$lte": before}},
+			bson.M{"status": domain.ArticleStatusPurging.ToUint8()},
+		},
+		"id": bson.M{"$gt": afterId},
+	}
+	opts := options.Find().
+		SetSort(bson.D{bson.E{Key: "id", Value: 1}}).
+		SetLimit(int64(limit))
+	cursor, err := dao.col.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var arts []Article
+	err = cursor.All(ctx, &arts)
+	return arts, err
+}
+
+func (dao *MongoArticleDao) MarkPurging(ctx context.Context, id int64, before int64) (bool, error) {
+	res, err := dao.col.UpdateOne(ctx, bson.M{
+		"id":          id,
+		"status":      domain.ArticleStatusTrashed.ToUint8(),
+		"delete_time": bson.M{"$lte": before},
+	}, bson.D{bson.E{Key: "$set", Value: bson.M{
+		"status":      domain.ArticleStatusPurging.ToUint8(),
+		"update_time": time.Now().UnixMilli(),
+	}}})
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount > 0, nil
+}
+
+// Purge 不使用事务, 先删除线上库再删除制作库, 中途失败时制作库还在, 下一次继续删除
+func (dao *MongoArticleDao) Purge(ctx context.Context, id int64) error {
+	res := dao.col.FindOne(ctx, bson.M{"id": id, "status": domain.ArticleStatusPurging.ToUint8()})
+	if errors.Is(res.Err(), mongo.ErrNoDocuments) {
+		return nil
+	}
+	if res.Err() != nil {
+		return res.Err()
+	}
+	if _, err := dao.liveCol.DeleteOne(ctx, bson.M{"id": id}); err != nil {
+		return err
+	}
+	_, err := dao.col.DeleteOne(ctx, bson.M{"id": id, "status": domain.ArticleStatusPurging.ToUint8()})
+	return err
+}
+
+// find 按照 update_time 倒序分页查询
+func (dao *MongoArticleDao) find(ctx context.Context, col *mongo.Collection, filter bson.M,
+	limit, offset int, res any) error {
+	opts := options.Find().
+		SetSort(bson.D{bson.E{Key: "update_time", Value: -1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit))
+	cursor, err := col.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, res)
 }
 
 func InitCollections(db *mongo.Database) error {
@@ -78,10 +389,15 @@ func InitCollections(db *mongo.Database) error {
 		},
 		{
 			Keys: bson.D{bson.E{Key: "author_id", Value: 1},
-				bson.E{Key: "create_time", Value: 1},
+				bson.E{Key: "update_time", Value: -1},
 			},
 			Options: options.Index(),
 		},
+		{
+			// 按时间分页查询线上库
+			Keys:    bson.D{bson.E{Key: "update_time", Value: -1}},
+			Options: options.Index(),
+		},
 	}
 	_, err := db.Collection("articles").Indexes().
 		CreateMany(ctx, index)
@@ -0,0 +1,54 @@
+package article
+
+import (
+	"context"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"testing"
+	"time"
+)
+
+func TestMongoArticleDao_PublishScheduled(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+	now := time.Now().UnixMilli()
+
+	mt.Run("到期发表", func(mt *mtest.T) {
+		dao := NewMongoArticleDao(mt.DB, nil)
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "value", Value: bson.M{
+				"id": 1, "tittle": "hello", "status": domain.ArticleStatusPublished.ToUint8(), "author_id": 2,
+			}}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+		)
+		ok, err := dao.PublishScheduled(context.Background(), 1, now)
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		// 用状态和时间作为条件修改制作库, 并发时只有一个实例能修改成功
+		evt := mt.GetStartedEvent()
+		require.Equal(t, "findAndModify", evt.CommandName)
+		var query bson.M
+		require.NoError(t, bson.Unmarshal(evt.Command.Lookup("query").Document(), &query))
+		assert.Equal(t, int32(domain.ArticleStatusScheduled.ToUint8()), query["status"])
+		assert.Equal(t, bson.M{"$lte": now}, query["publish_at"])
+
+		evt = mt.GetStartedEvent()
+		require.Equal(t, "update", evt.CommandName)
+		assert.Equal(t, "published_articles", evt.Command.Lookup("update").StringValue())
+	})
+
+	mt.Run("已经被其他实例发表", func(mt *mtest.T) {
+		dao := NewMongoArticleDao(mt.DB, nil)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}))
+		ok, err := dao.PublishScheduled(context.Background(), 1, now)
+		require.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, "findAndModify", mt.GetStartedEvent().CommandName)
+		// 没有修改成功就不能写线上库
+		assert.Nil(t, mt.GetStartedEvent())
+	})
+}
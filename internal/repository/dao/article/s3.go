@@ -1,27 +1,28 @@
 package article
 
 import (
-	"bytes"
 	"context"
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"errors"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/pkg/objstore"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 	"gorm.io/gorm"
 	"strconv"
 )
 
+// S3DAO 线上库的正文保存在对象存储, 其余字段和制作库仍然保存在 mysql
 type S3DAO struct {
-	oss *s3.Client
+	store objstore.ObjectStore
 	*GORMArticleDao
-	bucket *string
 }
 
-func NewS3DAO(oss *s3.Client, db *gorm.DB) *S3DAO {
+func NewS3DAO(store objstore.ObjectStore, db *gorm.DB) *S3DAO {
 	return &S3DAO{
-		oss: oss,
+		store: store,
 		GORMArticleDao: &GORMArticleDao{
 			db: db,
 		},
-		bucket: aws.String("bucket"),
 	}
 }
 
@@ -45,6 +46,8 @@ func (dao *S3DAO) Sync(ctx context.Context, art Article) (int64, error) {
 			Id:         art.Id,
 			Tittle:     art.Tittle,
 			Content:    "",
+			Cover:      art.Cover,
+			Images:     art.Images,
 			Status:     art.Status,
 			AuthorId:   art.AuthorId,
 			CreateTime: art.CreateTime,
@@ -54,13 +57,88 @@ func (dao *S3DAO) Sync(ctx context.Context, art Article) (int64, error) {
 			return err
 		}
 		// content保存到oss
-		_, err = dao.oss.PutObject(ctx, &s3.PutObjectInput{
-			Bucket:      dao.bucket,
-			Key:         aws.String(strconv.FormatInt(art.Id, 10)),
-			Body:        bytes.NewReader([]byte(art.Content)),
-			ContentType: aws.String("text/plain;charset=utf-8"),
-		})
-		return err
+		return dao.store.Put(ctx, contentKey(art.Id), []byte(art.Content), "text/plain;charset=utf-8")
 	})
 	return art.Id, err
 }
+
+// SyncStatus 撤回文章时同时删除对象存储里的正文, 重新发表时会再写入
+func (dao *S3DAO) SyncStatus(ctx context.Context, id int64, authorId int64, status uint8) error {
+	err := dao.GORMArticleDao.SyncStatus(ctx, id, authorId, status)
+	if err != nil {
+		return err
+	}
+	if status != domain.ArticleStatusPrivate.ToUint8() {
+		return nil
+	}
+	return dao.store.Delete(ctx, contentKey(id))
+}
+
+func (dao *S3DAO) PublishScheduled(ctx context.Context, id int64, now int64) (bool, error) {
+	return dao.publishScheduled(ctx, id, now, func(tx *gorm.DB, art Article) error {
+		pub := newPublishArticle(art)
+		pub.Content = ""
+		err := NewGORMArticleDao(tx).Upsert(ctx, pub)
+		if err != nil {
+			return err
+		}
+		return dao.store.Put(ctx, contentKey(art.Id), []byte(art.Content), "text/plain;charset=utf-8")
+	})
+}
+
+// Purge 彻底删除时同时删除对象存储里的正文, 放进回收站时保留, 恢复后还能读到.
+// 先删除正文, 失败时文章还在, 下一次继续删除
+func (dao *S3DAO) Purge(ctx context.Context, id int64) error {
+	if err := dao.store.Delete(ctx, contentKey(id)); err != nil {
+		return err
+	}
+	return dao.GORMArticleDao.Purge(ctx, id)
+}
+
+func (dao *S3DAO) GetPubById(ctx context.Context, id int64) (PublishArticle, error) {
+	art, err := dao.GORMArticleDao.GetPubById(ctx, id)
+	if err != nil {
+		return PublishArticle{}, err
+	}
+	err = dao.fillContent(ctx, &art)
+	if errors.Is(err, objstore.ErrObjectNotFound) {
+		// 对象被误删, 当作文章不存在
+		return PublishArticle{}, ErrRecordNotFound
+	}
+	return art, err
+}
+
+func (dao *S3DAO) GetPubPageByAuthor(ctx context.Context, uid int64, limit, offset int) ([]PublishArticle, error) {
+	arts, err := dao.GORMArticleDao.GetPubPageByAuthor(ctx, uid, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	var eg errgroup.Group
+	for i := range arts {
+		art := &arts[i]
+		eg.Go(func() error {
+			err := dao.fillContent(ctx, art)
+			if errors.Is(err, objstore.ErrObjectNotFound) {
+				// 一篇文章的正文丢失不影响整页
+				zap.L().Error("文章正文不存在", zap.Int64("id", art.Id))
+				return nil
+			}
+			return err
+		})
+	}
+	return arts, eg.Wait()
+}
+
+func (dao *S3DAO) fillContent(ctx context.Context, art *PublishArticle) error {
+	content, err := dao.store.Get(ctx, contentKey(art.Id))
+	if err != nil {
+		return err
+	}
+	art.Content = string(content)
+	return nil
+}
+
+// contentKey 正文在对象存储里的 key, 直接用文章 id
+func contentKey(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
@@ -2,9 +2,18 @@ package article
 
 import (
 	"context"
+	"errors"
+	"gorm.io/gorm"
 	"time"
 )
 
+var (
+	// ErrRecordNotFound 文章不存在, 不同的存储实现都返回这个错误
+	ErrRecordNotFound = gorm.ErrRecordNotFound
+	ErrNotScheduled   = errors.New("文章不存在或不是定时发表状态")
+	ErrNotTrashed     = errors.New("文章不存在或不在回收站")
+)
+
 type ArticleDAO interface {
 	Insert(ctx context.Context, article Article) (int64, error)
 	Update(ctx context.Context, article Article) error
@@ -16,4 +25,23 @@ type ArticleDAO interface {
 	GetDraftById(ctx context.Context, id int64) (Article, error)
 	GetPubById(ctx context.Context, id int64) (PublishArticle, error)
 	GetPubPageByTime(ctx context.Context, start time.Time, limit, offset int) ([]PublishArticle, error)
+	// CancelSchedule 取消定时发表, 文章回到未发表状态
+	CancelSchedule(ctx context.Context, id int64, authorId int64) error
+	// ListScheduled 定时发表时间不晚于 before 的文章, 按 id 升序, 从 afterId 之后开始
+	ListScheduled(ctx context.Context, before int64, afterId int64, limit int) ([]Article, error)
+	// PublishScheduled 发表到期的定时文章, 返回是否发表了.
+	// 只有文章仍然是定时发表状态并且已经到期才会发表, 多个实例同时执行也只会发表一次
+	PublishScheduled(ctx context.Context, id int64, now int64) (bool, error)
+	// Delete 把制作库和线上库的文章都放进回收站
+	Delete(ctx context.Context, id int64, authorId int64, now int64) error
+	// Restore 从回收站恢复, 制作库和线上库都回到放进回收站之前的状态
+	Restore(ctx context.Context, id int64, authorId int64) error
+	GetTrashPageByAuthor(ctx context.Context, uid int64, limit, offset int) ([]Article, error)
+	// ListTrashedBefore 在 before 之前放进回收站的文章, 以及正在彻底删除的文章, 按 id 升序, 从 afterId 之后开始
+	ListTrashedBefore(ctx context.Context, before int64, afterId int64, limit int) ([]Article, error)
+	// MarkPurging 把在 before 之前放进回收站的文章标记为正在彻底删除, 返回是否标记了.
+	// 文章已经被恢复的话不会标记, 标记之后不能再恢复
+	MarkPurging(ctx context.Context, id int64, before int64) (bool, error)
+	// Purge 删除正在彻底删除的文章, 文章已经不存在时返回 nil, 可以重复执行
+	Purge(ctx context.Context, id int64) error
 }
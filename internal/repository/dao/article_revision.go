@@ -0,0 +1,121 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"github.com/lutcoding/redbook/internal/repository/dao/article"
+	"gorm.io/gorm"
+	"time"
+)
+
+var ErrRevisionNotFound = gorm.ErrRecordNotFound
+
+// ArticleRevisionDAO 文章的历史版本, 不管文章本身存在哪里, 历史版本都存在 mysql
+type ArticleRevisionDAO interface {
+	Insert(ctx context.Context, r ArticleRevision) (int64, error)
+	List(ctx context.Context, articleId int64, limit, offset int) ([]ArticleRevision, error)
+	FindById(ctx context.Context, id int64) (ArticleRevision, error)
+	// ListArticleIdsBefore 有早于 before 的版本的文章, 按 id 升序, 从 afterArticleId 之后开始
+	ListArticleIdsBefore(ctx context.Context, before int64, afterArticleId int64, limit int) ([]int64, error)
+	// DeleteBefore 删除文章 articleId 早于 before 的版本, 但保留最新的 keep 个, 返回删除的数量
+	DeleteBefore(ctx context.Context, articleId int64, before int64, keep int) (int64, error)
+	// DeleteByArticle 删除文章的所有版本
+	DeleteByArticle(ctx context.Context, articleId int64) error
+	// ListMedia 文章所有版本的封面和图片, 只查询这两列
+	ListMedia(ctx context.Context, articleId int64) ([]ArticleRevision, error)
+}
+
+type GORMArticleRevisionDAO struct {
+	db *gorm.DB
+}
+
+func NewGORMArticleRevisionDAO(db *gorm.DB) *GORMArticleRevisionDAO {
+	return &GORMArticleRevisionDAO{
+		db: db,
+	}
+}
+
+func (dao *GORMArticleRevisionDAO) Insert(ctx context.Context, r ArticleRevision) (int64, error) {
+	r.CreateTime = time.Now().UnixMilli()
+	err := dao.db.WithContext(ctx).Create(&r).Error
+	return r.Id, err
+}
+
+func (dao *GORMArticleRevisionDAO) List(ctx context.Context, articleId int64, limit, offset int) ([]ArticleRevision, error) {
+	var res []ArticleRevision
+	// 列表不需要正文
+	err := dao.db.WithContext(ctx).
+		Select("id", "article_id", "operator", "kind", "tittle", "cover", "create_time").
+		Where("article_id = ?", articleId).
+		Order("id DESC").
+		Limit(limit).Offset(offset).
+		Find(&res).Error
+	return res, err
+}
+
+func (dao *GORMArticleRevisionDAO) FindById(ctx context.Context, id int64) (ArticleRevision, error) {
+	var res ArticleRevision
+	err := dao.db.WithContext(ctx).Where("id = ?", id).First(&res).Error
+	return res, err
+}
+
+func (dao *GORMArticleRevisionDAO) ListArticleIdsBefore(ctx context.Context, before int64, afterArticleId int64, limit int) ([]int64, error) {
+	var res []int64
+	err := dao.db.WithContext(ctx).Model(&ArticleRevision{}).
+		Distinct("article_id").
+		Where("create_time < ? AND article_id > ?", before, afterArticleId).
+		Order("article_id").
+		Limit(limit).
+		Pluck("article_id", &res).Error
+	return res, err
+}
+
+func (dao *GORMArticleRevisionDAO) DeleteBefore(ctx context.Context, articleId int64, before int64, keep int) (int64, error) {
+	db := dao.db.WithContext(ctx)
+	query := db.Where("article_id = ? AND create_time < ?", articleId, before)
+	if keep > 0 {
+		// 第 keep 新的版本, 比它旧的才可以删除
+		var r ArticleRevision
+		err := db.Select("id").
+			Where("article_id = ?", articleId).
+			Order("id DESC").
+			Offset(keep - 1).
+			First(&r).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 总数不超过 keep
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		query = query.Where("id < ?", r.Id)
+	}
+	res := query.Delete(&ArticleRevision{})
+	return res.RowsAffected, res.Error
+}
+
+func (dao *GORMArticleRevisionDAO) DeleteByArticle(ctx context.Context, articleId int64) error {
+	return dao.db.WithContext(ctx).Where("article_id = ?", articleId).Delete(&ArticleRevision{}).Error
+}
+
+func (dao *GORMArticleRevisionDAO) ListMedia(ctx context.Context, articleId int64) ([]ArticleRevision, error) {
+	var res []ArticleRevision
+	err := dao.db.WithContext(ctx).
+		Select("cover", "images").
+		Where("article_id = ?", articleId).
+		Find(&res).Error
+	return res, err
+}
+
+type ArticleRevision struct {
+	Id        int64 `gorm:"primaryKey,autoIncrement"`
+	ArticleId int64 `gorm:"index:article_id_ctime"`
+	Operator  int64
+	Kind      uint8
+	Tittle    string              `gorm:"type:varchar(1024)"`
+	Content   string              `gorm:"type:BLOB"`
+	Cover     string              `gorm:"type:varchar(256)"`
+	Images    article.StringSlice `gorm:"type:varchar(4096)"`
+
+	CreateTime int64 `gorm:"index:article_id_ctime"`
+}
@@ -12,15 +12,42 @@ const (
 	statusWait = iota
 	statusSuccess
 	statusFail
+	statusSending
 )
 
-var ErrWaitingSMSNotFound = gorm.ErrRecordNotFound
+var ErrAsyncSmsNotFound = gorm.ErrRecordNotFound
 
 type AsyncSmsDAO interface {
+	// Insert NextRetryAt 为 0 时立刻发送, 否则是定时发送的时间
 	Insert(ctx context.Context, sms AsyncSms) error
-	GetWaitingSms(ctx context.Context) (AsyncSms, error)
-	MarkSuccess(ctx context.Context, id int64) error
-	MarkFailed(ctx context.Context, id int64) error
+	// InsertBatch 一条语句插入多条, 要么都成功要么都失败
+	InsertBatch(ctx context.Context, smses []AsyncSms) error
+	// ClaimWaitingSms 抢占最多 limit 条到了发送时间的短信, 在 leaseUntil 之前其他实例不会再抢占.
+	// 发送中的短信超过 leaseUntil 还没有结果, 说明发送的实例挂了, 可以重新抢占
+	ClaimWaitingSms(ctx context.Context, now, leaseUntil int64, limit int) ([]AsyncSms, error)
+	MarkSuccess(ctx context.Context, attempt AsyncSmsAttempt) error
+	// MarkFailed 重试次数用完时标记为失败, 否则在 nextRetryAt 之后重试
+	MarkFailed(ctx context.Context, attempt AsyncSmsAttempt, nextRetryAt int64) error
+	// Postpone 把第 attempt 次抢占的短信改回等待发送, 在 nextRetryAt 之后重新抢占, 不计入发送次数
+	Postpone(ctx context.Context, id, attempt int64, nextRetryAt int64) error
+
+	FindById(ctx context.Context, id int64) (AsyncSms, error)
+	// List 按 id 倒序
+	List(ctx context.Context, filter AsyncSmsFilter, limit, offset int) ([]AsyncSms, error)
+	Count(ctx context.Context, filter AsyncSmsFilter) (int64, error)
+	// ListAttempts 短信的发送记录, 按发送顺序
+	ListAttempts(ctx context.Context, smsId int64) ([]AsyncSmsAttempt, error)
+	// Resend 把发送成功或者失败的短信改回等待发送, 重新计算次数. 短信不是这两个状态时返回 false
+	Resend(ctx context.Context, id int64, now int64) (bool, error)
+}
+
+// AsyncSmsFilter 零值表示不过滤
+type AsyncSmsFilter struct {
+	Status *int
+	Number string
+	// 创建时间在 [StartTime, EndTime) 之间
+	StartTime int64
+	EndTime   int64
 }
 
 type AsyncSmsGormDAO struct {
@@ -36,48 +63,181 @@ func NewAsyncSmsGormDAO(db *gorm.DB) *AsyncSmsGormDAO {
 func (dao *AsyncSmsGormDAO) Insert(ctx context.Context, sms AsyncSms) error {
 	now := time.Now().UnixMilli()
 	sms.CreateTime, sms.UpdateTime, sms.Status = now, now, statusWait
+	if sms.NextRetryAt == 0 {
+		sms.NextRetryAt = now
+	}
 	return dao.db.WithContext(ctx).Create(&sms).Error
 }
 
-func (dao *AsyncSmsGormDAO) GetWaitingSms(ctx context.Context) (AsyncSms, error) {
-	var sms AsyncSms
-	err := dao.db.Transaction(func(tx *gorm.DB) error {
-		now := time.Now().UnixMilli()
-		endTime := now - time.Minute.Milliseconds()
-		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-			Where("update_time < ? AND status = ?", endTime, statusWait).
-			First(&sms).Error
-		if err != nil {
-			return err
+func (dao *AsyncSmsGormDAO) InsertBatch(ctx context.Context, smses []AsyncSms) error {
+	if len(smses) == 0 {
+		return nil
+	}
+	now := time.Now().UnixMilli()
+	for i := range smses {
+		smses[i].CreateTime, smses[i].UpdateTime, smses[i].Status = now, now, statusWait
+		if smses[i].NextRetryAt == 0 {
+			smses[i].NextRetryAt = now
 		}
+	}
+	return dao.db.WithContext(ctx).Create(&smses).Error
+}
 
-		err = tx.Model(&AsyncSms{}).
-			Where("id = ?", sms.Id).
+func (dao *AsyncSmsGormDAO) ClaimWaitingSms(ctx context.Context, now, leaseUntil int64, limit int) ([]AsyncSms, error) {
+	var res []AsyncSms
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var smses []AsyncSms
+		// SKIP LOCKED 跳过其他实例正在抢占的行, 多个实例可以同时抢占不同的短信
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status IN ? AND next_retry_at <= ?", []int{statusWait, statusSending}, now).
+			Order("next_retry_at").
+			Limit(limit).
+			Find(&smses).Error
+		if err != nil || len(smses) == 0 {
+			return err
+		}
+		var ids, exhausted []int64
+		for _, sms := range smses {
+			// 只有租约过期的发送中的短信会用完次数, 不知道最后一次有没有发出去, 不再重试
+			if sms.RetryCnt >= sms.RetryMax {
+				exhausted = append(exhausted, sms.Id)
+				continue
+			}
+			sms.RetryCnt++
+			sms.Status, sms.NextRetryAt, sms.UpdateTime = statusSending, leaseUntil, now
+			ids = append(ids, sms.Id)
+			res = append(res, sms)
+		}
+		if len(exhausted) > 0 {
+			err = tx.Model(&AsyncSms{}).
+				Where("id IN ?", exhausted).
+				Updates(map[string]any{
+					"status":      statusFail,
+					"last_err":    "发送超时, 重试次数用完",
+					"update_time": now,
+				}).Error
+			if err != nil {
+				return err
+			}
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		return tx.Model(&AsyncSms{}).
+			Where("id IN ?", ids).
 			Updates(map[string]any{
-				"retry_cnt":   gorm.Expr("retry_cnt + ?", 1),
-				"update_time": now,
+				"retry_cnt":     gorm.Expr("retry_cnt + ?", 1),
+				"status":        statusSending,
+				"next_retry_at": leaseUntil,
+				"update_time":   now,
 			}).Error
-		return err
 	})
-	return sms, err
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
 }
 
-func (dao *AsyncSmsGormDAO) MarkSuccess(ctx context.Context, id int64) error {
+func (dao *AsyncSmsGormDAO) MarkSuccess(ctx context.Context, attempt AsyncSmsAttempt) error {
+	return dao.report(ctx, attempt, map[string]any{
+		"status":   statusSuccess,
+		"last_err": "",
+	})
+}
+
+func (dao *AsyncSmsGormDAO) MarkFailed(ctx context.Context, attempt AsyncSmsAttempt, nextRetryAt int64) error {
+	return dao.report(ctx, attempt, map[string]any{
+		"status":        gorm.Expr("CASE WHEN retry_cnt >= retry_max THEN ? ELSE ? END", statusFail, statusWait),
+		"last_err":      attempt.Err,
+		"next_retry_at": nextRetryAt,
+	})
+}
+
+func (dao *AsyncSmsGormDAO) Postpone(ctx context.Context, id, attempt int64, nextRetryAt int64) error {
 	return dao.db.WithContext(ctx).Model(&AsyncSms{}).
-		Where("id = ?", id).
+		Where("id = ? AND status = ? AND retry_cnt = ?", id, statusSending, attempt).
 		Updates(map[string]any{
-			"status":      statusSuccess,
-			"update_time": time.Now().UnixMilli(),
+			"retry_cnt":     gorm.Expr("retry_cnt - ?", 1),
+			"status":        statusWait,
+			"next_retry_at": nextRetryAt,
+			"update_time":   time.Now().UnixMilli(),
 		}).Error
 }
 
-func (dao *AsyncSmsGormDAO) MarkFailed(ctx context.Context, id int64) error {
-	return dao.db.WithContext(ctx).Model(&AsyncSms{}).
-		Where("id = ? AND `retry_cnt`>=`retry_max`", id).
+func (dao *AsyncSmsGormDAO) FindById(ctx context.Context, id int64) (AsyncSms, error) {
+	var sms AsyncSms
+	err := dao.db.WithContext(ctx).Where("id = ?", id).First(&sms).Error
+	return sms, err
+}
+
+func (dao *AsyncSmsGormDAO) List(ctx context.Context, filter AsyncSmsFilter, limit, offset int) ([]AsyncSms, error) {
+	var res []AsyncSms
+	err := dao.filter(ctx, filter).
+		Order("id DESC").
+		Limit(limit).Offset(offset).
+		Find(&res).Error
+	return res, err
+}
+
+func (dao *AsyncSmsGormDAO) Count(ctx context.Context, filter AsyncSmsFilter) (int64, error) {
+	var cnt int64
+	err := dao.filter(ctx, filter).Count(&cnt).Error
+	return cnt, err
+}
+
+func (dao *AsyncSmsGormDAO) filter(ctx context.Context, filter AsyncSmsFilter) *gorm.DB {
+	query := dao.db.WithContext(ctx).Model(&AsyncSms{})
+	if filter.Status != nil {
+		query = query.Where("status = ?", *filter.Status)
+	}
+	if filter.Number != "" {
+		// 手机号保存在 json 里面, 数据量大的时候需要加多值索引
+		query = query.Where("JSON_CONTAINS(async_sms_config, JSON_QUOTE(?), '$.Numbers')", filter.Number)
+	}
+	if filter.StartTime > 0 {
+		query = query.Where("create_time >= ?", filter.StartTime)
+	}
+	if filter.EndTime > 0 {
+		query = query.Where("create_time < ?", filter.EndTime)
+	}
+	return query
+}
+
+func (dao *AsyncSmsGormDAO) ListAttempts(ctx context.Context, smsId int64) ([]AsyncSmsAttempt, error) {
+	var res []AsyncSmsAttempt
+	err := dao.db.WithContext(ctx).
+		Where("sms_id = ?", smsId).
+		Order("id").
+		Find(&res).Error
+	return res, err
+}
+
+func (dao *AsyncSmsGormDAO) Resend(ctx context.Context, id int64, now int64) (bool, error) {
+	res := dao.db.WithContext(ctx).Model(&AsyncSms{}).
+		Where("id = ? AND status IN ?", id, []int{statusSuccess, statusFail}).
 		Updates(map[string]any{
-			"status":      statusFail,
-			"update_time": time.Now().UnixMilli(),
-		}).Error
+			"retry_cnt":     0,
+			"status":        statusWait,
+			"next_retry_at": now,
+			"update_time":   now,
+		})
+	return res.RowsAffected > 0, res.Error
+}
+
+// report 保存发送记录并更新短信状态. 租约过期被其他实例重新抢占之后, 只保存发送记录
+func (dao *AsyncSmsGormDAO) report(ctx context.Context, attempt AsyncSmsAttempt, updates map[string]any) error {
+	now := time.Now().UnixMilli()
+	attempt.CreateTime = now
+	updates["update_time"] = now
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(&attempt).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&AsyncSms{}).
+			Where("id = ? AND status = ? AND retry_cnt = ?", attempt.SmsId, statusSending, attempt.Attempt).
+			Updates(updates).Error
+	})
 }
 
 type AsyncSms struct {
@@ -85,7 +245,21 @@ type AsyncSms struct {
 	RetryCnt       int64
 	RetryMax       int64
 	AsyncSmsConfig datatypes.JSON
-	Status         int
+	Status         int    `gorm:"index:idx_status_next_retry_at,priority:1"`
+	NextRetryAt    int64  `gorm:"index:idx_status_next_retry_at,priority:2"`
+	LastErr        string `gorm:"type:varchar(1024)"`
 	CreateTime     int64
 	UpdateTime     int64
 }
+
+// AsyncSmsAttempt 异步短信每一次发送的结果
+type AsyncSmsAttempt struct {
+	Id      int64 `gorm:"primaryKey, autoIncrement"`
+	SmsId   int64 `gorm:"index"`
+	Attempt int64
+	// Err 为空表示发送成功
+	Err string `gorm:"type:varchar(1024)"`
+	// Latency 发送耗时, 毫秒
+	Latency    int64
+	CreateTime int64
+}
@@ -0,0 +1,97 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+var ErrDuplicateCollection = errors.New("收藏夹名称重复")
+
+// CollectionDAO 收藏夹
+type CollectionDAO interface {
+	Insert(ctx context.Context, c Collection) (int64, error)
+	ListByUid(ctx context.Context, uid int64) ([]Collection, error)
+	ListCollectInfo(ctx context.Context, uid, cid int64, limit, offset int) ([]CollectInfo, error)
+	MoveCollectInfo(ctx context.Context, uid int64, biz string, bizId int64, cid int64) error
+}
+
+type GORMCollectionDAO struct {
+	db *gorm.DB
+}
+
+func NewGORMCollectionDAO(db *gorm.DB) *GORMCollectionDAO {
+	return &GORMCollectionDAO{
+		db: db,
+	}
+}
+
+func (dao *GORMCollectionDAO) Insert(ctx context.Context, c Collection) (int64, error) {
+	now := time.Now().UnixMilli()
+	c.CreateTime, c.UpdateTime = now, now
+	err := dao.db.WithContext(ctx).Create(&c).Error
+	if isUniqueConflict(err) {
+		return 0, ErrDuplicateCollection
+	}
+	return c.Id, err
+}
+
+func (dao *GORMCollectionDAO) ListByUid(ctx context.Context, uid int64) ([]Collection, error) {
+	var res []Collection
+	err := dao.db.WithContext(ctx).
+		Where("uid = ?", uid).
+		Order("create_time").
+		Find(&res).Error
+	return res, err
+}
+
+func (dao *GORMCollectionDAO) ListCollectInfo(ctx context.Context, uid, cid int64, limit, offset int) ([]CollectInfo, error) {
+	var res []CollectInfo
+	err := dao.db.WithContext(ctx).
+		Where("uid = ? AND cid = ?", uid, cid).
+		Limit(limit).Offset(offset).
+		Clauses(clause.OrderBy{Columns: []clause.OrderByColumn{
+			{Column: clause.Column{Name: "update_time"}, Desc: true},
+		}}).
+		Find(&res).Error
+	return res, err
+}
+
+// MoveCollectInfo 把已收藏的资源移动到另一个收藏夹, cid 为 0 代表默认收藏夹
+func (dao *GORMCollectionDAO) MoveCollectInfo(ctx context.Context, uid int64, biz string, bizId int64, cid int64) error {
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if cid != 0 {
+			err := tx.Where("id = ? AND uid = ?", cid, uid).First(&Collection{}).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCollectionNotFound
+			}
+			if err != nil {
+				return err
+			}
+		}
+		res := tx.Model(&CollectInfo{}).
+			Where("uid = ? AND biz_id = ? AND biz = ?", uid, bizId, biz).
+			Updates(map[string]any{
+				"cid":         cid,
+				"update_time": time.Now().UnixMilli(),
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected != 1 {
+			return ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// Collection 用户创建的收藏夹
+type Collection struct {
+	Id         int64  `gorm:"primaryKey,autoIncrement"`
+	Uid        int64  `gorm:"uniqueIndex:uid_name"`
+	Name       string `gorm:"type:varchar(128);uniqueIndex:uid_name"`
+	CreateTime int64
+	UpdateTime int64
+}
@@ -6,6 +6,7 @@ import (
 )
 
 func InitTables(db *gorm.DB) error {
-	return db.AutoMigrate(&User{}, &AsyncSms{},
-		&article.Article{}, &article.PublishArticle{}, &Interactive{}, &LikeInfo{})
+	return db.AutoMigrate(&User{}, &AsyncSms{}, &AsyncSmsAttempt{}, &SmsToken{},
+		&article.Article{}, &article.PublishArticle{}, &Interactive{}, &LikeInfo{},
+		&CollectInfo{}, &Collection{}, &Media{}, &ArticleRevision{})
 }
@@ -9,21 +9,31 @@ import (
 )
 
 var (
-	ErrRecordNotFound = gorm.ErrRecordNotFound
-	ErrDuplicateLike  = errors.New("重复点赞")
+	ErrRecordNotFound     = gorm.ErrRecordNotFound
+	ErrDuplicateLike      = errors.New("重复点赞")
+	ErrDuplicateCollect   = errors.New("重复收藏")
+	ErrCollectionNotFound = errors.New("收藏夹不存在")
 )
 
 type InteractiveDAO interface {
 	IncrReadCnt(ctx context.Context, biz string, bizId int64) error
+	BatchIncrReadCnt(ctx context.Context, bizs []string, bizIds []int64) error
 	IncrCollectCnt(ctx context.Context, biz string, bizId int64) error
 	InsertLikeInfo(ctx context.Context, uid int64, biz string, bizId int64) error
 	DelLikeInfo(ctx context.Context, uid int64, biz string, bizId int64) error
 	GetLikeInfo(ctx context.Context, uid int64, biz string, bizId int64) (LikeInfo, error)
 	GetInteractiveInfo(ctx context.Context, biz string, bizId int64) (Interactive, error)
+	GetByIds(ctx context.Context, biz string, bizIds []int64) ([]Interactive, error)
+	InsertCollectInfo(ctx context.Context, info CollectInfo) error
+	DelCollectInfo(ctx context.Context, uid int64, biz string, bizId int64) error
+	GetCollectInfo(ctx context.Context, uid int64, biz string, bizId int64) (CollectInfo, error)
+	// Delete 删除资源的计数, 点赞和收藏记录, 资源被彻底删除时使用
+	Delete(ctx context.Context, biz string, bizId int64) error
 }
 
 var (
-	ErrDislikeNoRow = errors.New("无法取消点赞")
+	ErrDislikeNoRow       = errors.New("无法取消点赞")
+	ErrCancelCollectNoRow = errors.New("无法取消收藏")
 )
 
 type GORMInteractiveDAO struct {
@@ -36,6 +46,12 @@ func (dao *GORMInteractiveDAO) GetInteractiveInfo(ctx context.Context, biz strin
 	return res, err
 }
 
+func (dao *GORMInteractiveDAO) GetByIds(ctx context.Context, biz string, bizIds []int64) ([]Interactive, error) {
+	var res []Interactive
+	err := dao.db.WithContext(ctx).Where("biz = ? AND biz_id IN ?", biz, bizIds).Find(&res).Error
+	return res, err
+}
+
 func (dao *GORMInteractiveDAO) GetLikeInfo(ctx context.Context, uid int64, biz string, bizId int64) (LikeInfo, error) {
 	var info LikeInfo
 	err := dao.db.WithContext(ctx).
@@ -105,6 +121,60 @@ func (dao *GORMInteractiveDAO) InsertLikeInfo(ctx context.Context, uid int64, bi
 	})
 }
 
+func (dao *GORMInteractiveDAO) GetCollectInfo(ctx context.Context, uid int64, biz string, bizId int64) (CollectInfo, error) {
+	var info CollectInfo
+	err := dao.db.WithContext(ctx).
+		Where("uid = ? AND biz_id = ? AND biz = ?", uid, bizId, biz).
+		First(&info).Error
+	return info, err
+}
+
+// InsertCollectInfo 收藏到指定收藏夹, cid 为 0 代表默认收藏夹
+func (dao *GORMInteractiveDAO) InsertCollectInfo(ctx context.Context, info CollectInfo) error {
+	now := time.Now().UnixMilli()
+	info.CreateTime, info.UpdateTime = now, now
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if info.Cid != 0 {
+			// 只能收藏到自己的收藏夹
+			err := tx.Where("id = ? AND uid = ?", info.Cid, info.Uid).First(&Collection{}).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCollectionNotFound
+			}
+			if err != nil {
+				return err
+			}
+		}
+		err := tx.Create(&info).Error
+		if err != nil {
+			if isUniqueConflict(err) {
+				return ErrDuplicateCollect
+			}
+			return err
+		}
+		return NewGORMInteractiveDAO(tx).IncrCollectCnt(ctx, info.Biz, info.BizId)
+	})
+}
+
+func (dao *GORMInteractiveDAO) DelCollectInfo(ctx context.Context, uid int64, biz string, bizId int64) error {
+	now := time.Now().UnixMilli()
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("uid = ? AND biz_id = ? AND biz = ?", uid, bizId, biz).
+			Delete(&CollectInfo{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected != 1 {
+			return ErrCancelCollectNoRow
+		}
+		return tx.Model(&Interactive{}).
+			Where("biz_id = ? AND biz = ?", bizId, biz).
+			Updates(map[string]any{
+				"collect_cnt": gorm.Expr("collect_cnt - 1"),
+				"update_time": now,
+			}).Error
+	})
+}
+
 func (dao *GORMInteractiveDAO) IncrCollectCnt(ctx context.Context, biz string, bizId int64) error {
 	now := time.Now().UnixMilli()
 	return dao.db.WithContext(ctx).
@@ -139,12 +209,40 @@ func (dao *GORMInteractiveDAO) IncrReadCnt(ctx context.Context, biz string, bizI
 	}).Error
 }
 
+// BatchIncrReadCnt bizs 和 bizIds 长度必须一致, 在一个事务里批量更新阅读数
+func (dao *GORMInteractiveDAO) BatchIncrReadCnt(ctx context.Context, bizs []string, bizIds []int64) error {
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		txDAO := NewGORMInteractiveDAO(tx)
+		for i := range bizs {
+			err := txDAO.IncrReadCnt(ctx, bizs[i], bizIds[i])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 func NewGORMInteractiveDAO(db *gorm.DB) *GORMInteractiveDAO {
 	return &GORMInteractiveDAO{
 		db: db,
 	}
 }
 
+func (dao *GORMInteractiveDAO) Delete(ctx context.Context, biz string, bizId int64) error {
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("biz_id = ? AND biz = ?", bizId, biz).Delete(&LikeInfo{}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Where("biz_id = ? AND biz = ?", bizId, biz).Delete(&CollectInfo{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("biz_id = ? AND biz = ?", bizId, biz).Delete(&Interactive{}).Error
+	})
+}
+
 type Interactive struct {
 	Id int64 `gorm:"primaryKey,autoIncrement"`
 	// <bizid, biz>
@@ -168,3 +266,15 @@ type LikeInfo struct {
 	CreateTime int64
 	UpdateTime int64
 }
+
+// CollectInfo 用户收藏了哪些资源, 同一个资源只能收藏到一个收藏夹
+type CollectInfo struct {
+	Id    int64  `gorm:"primaryKey,autoIncrement"`
+	Uid   int64  `gorm:"uniqueIndex:uid_biz_type_id"`
+	BizId int64  `gorm:"uniqueIndex:uid_biz_type_id"`
+	Biz   string `gorm:"type:varchar(128);uniqueIndex:uid_biz_type_id"`
+	// 收藏夹 id, 0 代表默认收藏夹
+	Cid        int64 `gorm:"index"`
+	CreateTime int64
+	UpdateTime int64
+}
@@ -0,0 +1,101 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"time"
+)
+
+// MediaDAO 用户上传的图片
+type MediaDAO interface {
+	Insert(ctx context.Context, m Media) (int64, error)
+	FindByKeys(ctx context.Context, keys []string) ([]Media, error)
+	// Bind 文章 articleId 现在引用的图片是 keys, 不再引用的图片解除绑定
+	Bind(ctx context.Context, articleId int64, keys []string) error
+	// ListUnbound 在 before 之前就没有被引用的图片
+	ListUnbound(ctx context.Context, before int64, limit int) ([]Media, error)
+	// DeleteUnbound 删除没有被引用的图片, 返回是否删除了, 期间被重新引用的不会删除
+	DeleteUnbound(ctx context.Context, id int64, before int64) (bool, error)
+}
+
+type GORMMediaDAO struct {
+	db *gorm.DB
+}
+
+func NewGORMMediaDAO(db *gorm.DB) *GORMMediaDAO {
+	return &GORMMediaDAO{
+		db: db,
+	}
+}
+
+func (dao *GORMMediaDAO) Insert(ctx context.Context, m Media) (int64, error) {
+	now := time.Now().UnixMilli()
+	m.CreateTime, m.UpdateTime = now, now
+	err := dao.db.WithContext(ctx).Create(&m).Error
+	return m.Id, err
+}
+
+func (dao *GORMMediaDAO) FindByKeys(ctx context.Context, keys []string) ([]Media, error) {
+	var res []Media
+	if len(keys) == 0 {
+		return res, nil
+	}
+	err := dao.db.WithContext(ctx).Where("object_key IN ?", keys).Find(&res).Error
+	return res, err
+}
+
+func (dao *GORMMediaDAO) Bind(ctx context.Context, articleId int64, keys []string) error {
+	now := time.Now().UnixMilli()
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		unbind := tx.Model(&Media{}).Where("article_id = ?", articleId)
+		if len(keys) > 0 {
+			unbind = unbind.Where("object_key NOT IN ?", keys)
+		}
+		// 解除绑定时更新 update_time, 宽限期从这里开始算
+		err := unbind.Updates(map[string]any{
+			"article_id":  0,
+			"update_time": now,
+		}).Error
+		if err != nil || len(keys) == 0 {
+			return err
+		}
+		return tx.Model(&Media{}).
+			Where("object_key IN ? AND article_id IN ?", keys, []int64{0, articleId}).
+			Updates(map[string]any{
+				"article_id":  articleId,
+				"update_time": now,
+			}).Error
+	})
+}
+
+func (dao *GORMMediaDAO) ListUnbound(ctx context.Context, before int64, limit int) ([]Media, error) {
+	var res []Media
+	err := dao.db.WithContext(ctx).
+		Where("article_id = ? AND update_time < ?", 0, before).
+		Order("id").Limit(limit).
+		Find(&res).Error
+	return res, err
+}
+
+func (dao *GORMMediaDAO) DeleteUnbound(ctx context.Context, id int64, before int64) (bool, error) {
+	res := dao.db.WithContext(ctx).
+		Where("id = ? AND article_id = ? AND update_time < ?", id, 0, before).
+		Delete(&Media{})
+	return res.RowsAffected > 0, res.Error
+}
+
+type Media struct {
+	Id  int64 `gorm:"primaryKey,autoIncrement"`
+	Uid int64
+	// key 是 mysql 的关键字
+	Key       string `gorm:"column:object_key;type:varchar(256);uniqueIndex"`
+	ThumbKey  string `gorm:"type:varchar(256)"`
+	MimeType  string `gorm:"type:varchar(64)"`
+	Size      int64
+	Width     int
+	Height    int
+	ArticleId int64 `gorm:"index:article_id_utime"`
+
+	CreateTime int64
+	UpdateTime int64 `gorm:"index:article_id_utime"`
+}
@@ -0,0 +1,80 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"time"
+)
+
+var ErrSmsTokenNotFound = gorm.ErrRecordNotFound
+
+type SmsTokenDAO interface {
+	Insert(ctx context.Context, t SmsToken) (int64, error)
+	FindById(ctx context.Context, id int64) (SmsToken, error)
+	// List 按 id 倒序
+	List(ctx context.Context, limit, offset int) ([]SmsToken, error)
+	Revoke(ctx context.Context, id int64) error
+}
+
+type GORMSmsTokenDAO struct {
+	db *gorm.DB
+}
+
+func NewGORMSmsTokenDAO(db *gorm.DB) *GORMSmsTokenDAO {
+	return &GORMSmsTokenDAO{
+		db: db,
+	}
+}
+
+func (dao *GORMSmsTokenDAO) Insert(ctx context.Context, t SmsToken) (int64, error) {
+	now := time.Now().UnixMilli()
+	t.CreateTime, t.UpdateTime = now, now
+	err := dao.db.WithContext(ctx).Create(&t).Error
+	return t.Id, err
+}
+
+func (dao *GORMSmsTokenDAO) FindById(ctx context.Context, id int64) (SmsToken, error) {
+	var t SmsToken
+	err := dao.db.WithContext(ctx).Where("id = ?", id).First(&t).Error
+	return t, err
+}
+
+func (dao *GORMSmsTokenDAO) List(ctx context.Context, limit, offset int) ([]SmsToken, error) {
+	var res []SmsToken
+	err := dao.db.WithContext(ctx).
+		Order("id DESC").
+		Limit(limit).Offset(offset).
+		Find(&res).Error
+	return res, err
+}
+
+func (dao *GORMSmsTokenDAO) Revoke(ctx context.Context, id int64) error {
+	res := dao.db.WithContext(ctx).Model(&SmsToken{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"revoked":     true,
+			"update_time": time.Now().UnixMilli(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrSmsTokenNotFound
+	}
+	return nil
+}
+
+type SmsToken struct {
+	Id            int64  `gorm:"primaryKey, autoIncrement"`
+	Biz           string `gorm:"type:varchar(64);index"`
+	Tpls          datatypes.JSON
+	MonthlyQuota  int64
+	RatePerMinute int64
+	Revoked       bool
+	Creator       int64
+	// ExpireTime 0 表示不过期
+	ExpireTime int64
+	CreateTime int64
+	UpdateTime int64
+}
@@ -37,19 +37,15 @@ func (dao *UserGormDAO) Insert(ctx context.Context, u User) error {
 	now := time.Now().UnixMilli()
 	u.CreateTime, u.UpdateTime = now, now
 	err := dao.db.WithContext(ctx).Create(&u).Error
-	if mysqlErr, ok := err.(*mysql.MySQLError); ok {
-		// mysql唯一索引错误码
-		const uniqueConflictsErrNo = 1062
-		if mysqlErr.Number == uniqueConflictsErrNo {
-			return ErrUserDuplicate
-		}
+	if isUniqueConflict(err) {
+		return ErrUserDuplicate
 	}
 	return err
 }
 
 func (dao *UserGormDAO) Update(ctx context.Context, u User) error {
 	u.UpdateTime = time.Now().UnixMilli()
-	return dao.db.Model(&u).Updates(u).Error
+	return dao.db.WithContext(ctx).Model(&u).Updates(u).Error
 }
 
 func (dao *UserGormDAO) FindByEmail(ctx context.Context, email string) (u User, err error) {
@@ -72,6 +68,16 @@ func (dao *UserGormDAO) FindByWeChat(ctx context.Context, openID string) (u User
 	return
 }
 
+// isUniqueConflict 是否违反了唯一索引
+func isUniqueConflict(err error) bool {
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok {
+		// mysql唯一索引错误码
+		const uniqueConflictsErrNo = 1062
+		return mysqlErr.Number == uniqueConflictsErrNo
+	}
+	return false
+}
+
 // User model
 type User struct {
 	Id       int64          `gorm:"primaryKey, autoIncrement"`
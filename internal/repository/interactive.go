@@ -11,13 +11,17 @@ import (
 
 type InteractiveRepository interface {
 	IncrReadCnt(ctx context.Context, biz string, bizId int64) error
+	BatchIncrReadCnt(ctx context.Context, bizs []string, bizIds []int64) error
 	IncrLikeCnt(ctx context.Context, uid int64, biz string, bizId int64) error
-	IncrCollectCnt(ctx context.Context, biz string, bizId int64) error
+	IncrCollectCnt(ctx context.Context, uid, cid int64, biz string, bizId int64) error
 	DecrLikeCnt(ctx context.Context, uid int64, biz string, bizId int64) error
-	DecrCollectCnt(ctx context.Context, biz string, bizId int64) error
+	DecrCollectCnt(ctx context.Context, uid int64, biz string, bizId int64) error
 	GetInteractiveInfo(ctx context.Context, biz string, bizId int64) (domain.Interactive, error)
+	// GetByIds 批量查询, 没有记录的 bizId 不会出现在结果中
+	GetByIds(ctx context.Context, biz string, bizIds []int64) (map[int64]domain.Interactive, error)
 	Liked(ctx context.Context, uid int64, biz string, bizId int64) (bool, error)
 	Collected(ctx context.Context, uid int64, biz string, bizId int64) (bool, error)
+	Delete(ctx context.Context, biz string, bizId int64) error
 }
 
 type InteractiveCacheRepository struct {
@@ -45,6 +49,18 @@ func (repo *InteractiveCacheRepository) GetInteractiveInfo(ctx context.Context,
 	return res, nil
 }
 
+func (repo *InteractiveCacheRepository) GetByIds(ctx context.Context, biz string, bizIds []int64) (map[int64]domain.Interactive, error) {
+	infos, err := repo.dao.GetByIds(ctx, biz, bizIds)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[int64]domain.Interactive, len(infos))
+	for _, info := range infos {
+		res[info.BizId] = repo.entityToDomain(info)
+	}
+	return res, nil
+}
+
 func (repo *InteractiveCacheRepository) Liked(ctx context.Context, uid int64, biz string, bizId int64) (bool, error) {
 	_, err := repo.dao.GetLikeInfo(ctx, uid, biz, bizId)
 	switch err {
@@ -58,7 +74,15 @@ func (repo *InteractiveCacheRepository) Liked(ctx context.Context, uid int64, bi
 }
 
 func (repo *InteractiveCacheRepository) Collected(ctx context.Context, uid int64, biz string, bizId int64) (bool, error) {
-	return false, nil
+	_, err := repo.dao.GetCollectInfo(ctx, uid, biz, bizId)
+	switch err {
+	case nil:
+		return true, nil
+	case dao.ErrRecordNotFound:
+		return false, nil
+	default:
+		return false, err
+	}
 }
 
 func (repo *InteractiveCacheRepository) IncrLikeCnt(ctx context.Context, uid int64, biz string, bizId int64) error {
@@ -87,14 +111,35 @@ func (repo *InteractiveCacheRepository) DecrLikeCnt(ctx context.Context, uid int
 	return repo.cache.DecrLikeCntIfPresent(ctx, biz, bizId)
 }
 
-func (repo *InteractiveCacheRepository) IncrCollectCnt(ctx context.Context, biz string, bizId int64) error {
-	//TODO implement me
-	panic("implement me")
+func (repo *InteractiveCacheRepository) IncrCollectCnt(ctx context.Context, uid, cid int64, biz string, bizId int64) error {
+	err := repo.dao.InsertCollectInfo(ctx, dao.CollectInfo{
+		Uid:   uid,
+		Cid:   cid,
+		Biz:   biz,
+		BizId: bizId,
+	})
+	if err != nil {
+		if errors.Is(err, dao.ErrDuplicateCollect) {
+			zap.L().Info("用户重复收藏",
+				zap.Int64("uid", uid),
+				zap.Int64("biz_id", bizId))
+			return nil
+		}
+		return err
+	}
+	return repo.cache.IncrCollectCntIfPresent(ctx, biz, bizId)
 }
 
-func (repo *InteractiveCacheRepository) DecrCollectCnt(ctx context.Context, biz string, bizId int64) error {
-	//TODO implement me
-	panic("implement me")
+func (repo *InteractiveCacheRepository) DecrCollectCnt(ctx context.Context, uid int64, biz string, bizId int64) error {
+	err := repo.dao.DelCollectInfo(ctx, uid, biz, bizId)
+	if err != nil {
+		if errors.Is(err, dao.ErrCancelCollectNoRow) {
+			zap.L().Info("用户取消一篇文章收藏", zap.Int64("uid", uid))
+			return nil
+		}
+		return err
+	}
+	return repo.cache.DecrCollectCntIfPresent(ctx, biz, bizId)
 }
 
 func (repo *InteractiveCacheRepository) IncrReadCnt(ctx context.Context, biz string, bizId int64) error {
@@ -105,6 +150,22 @@ func (repo *InteractiveCacheRepository) IncrReadCnt(ctx context.Context, biz str
 	return repo.cache.IncrReadCntIfPresent(ctx, biz, bizId)
 }
 
+func (repo *InteractiveCacheRepository) BatchIncrReadCnt(ctx context.Context, bizs []string, bizIds []int64) error {
+	err := repo.dao.BatchIncrReadCnt(ctx, bizs, bizIds)
+	if err != nil {
+		return err
+	}
+	return repo.cache.BatchIncrReadCntIfPresent(ctx, bizs, bizIds)
+}
+
+func (repo *InteractiveCacheRepository) Delete(ctx context.Context, biz string, bizId int64) error {
+	err := repo.dao.Delete(ctx, biz, bizId)
+	if err != nil {
+		return err
+	}
+	return repo.cache.DelInteractiveInfo(ctx, biz, bizId)
+}
+
 func (repo *InteractiveCacheRepository) entityToDomain(info dao.Interactive) domain.Interactive {
 	return domain.Interactive{
 		ReadCnt:    info.ReadCnt,
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/internal/repository/dao"
+	"time"
+)
+
+type MediaRepository interface {
+	Create(ctx context.Context, m domain.Media) (int64, error)
+	FindByKeys(ctx context.Context, keys []string) ([]domain.Media, error)
+	Bind(ctx context.Context, articleId int64, keys []string) error
+	ListUnbound(ctx context.Context, before time.Time, limit int) ([]domain.Media, error)
+	DeleteUnbound(ctx context.Context, id int64, before time.Time) (bool, error)
+}
+
+type MediaCacheRepository struct {
+	dao dao.MediaDAO
+}
+
+func NewMediaCacheRepository(dao dao.MediaDAO) *MediaCacheRepository {
+	return &MediaCacheRepository{
+		dao: dao,
+	}
+}
+
+func (repo *MediaCacheRepository) Create(ctx context.Context, m domain.Media) (int64, error) {
+	return repo.dao.Insert(ctx, dao.Media{
+		Uid:      m.Uid,
+		Key:      m.Key,
+		ThumbKey: m.ThumbKey,
+		MimeType: m.MimeType,
+		Size:     m.Size,
+		Width:    m.Width,
+		Height:   m.Height,
+	})
+}
+
+func (repo *MediaCacheRepository) FindByKeys(ctx context.Context, keys []string) ([]domain.Media, error) {
+	ms, err := repo.dao.FindByKeys(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	return repo.toDomains(ms), nil
+}
+
+func (repo *MediaCacheRepository) Bind(ctx context.Context, articleId int64, keys []string) error {
+	return repo.dao.Bind(ctx, articleId, keys)
+}
+
+func (repo *MediaCacheRepository) ListUnbound(ctx context.Context, before time.Time, limit int) ([]domain.Media, error) {
+	ms, err := repo.dao.ListUnbound(ctx, before.UnixMilli(), limit)
+	if err != nil {
+		return nil, err
+	}
+	return repo.toDomains(ms), nil
+}
+
+func (repo *MediaCacheRepository) DeleteUnbound(ctx context.Context, id int64, before time.Time) (bool, error) {
+	return repo.dao.DeleteUnbound(ctx, id, before.UnixMilli())
+}
+
+func (repo *MediaCacheRepository) toDomains(ms []dao.Media) []domain.Media {
+	res := make([]domain.Media, 0, len(ms))
+	for _, m := range ms {
+		res = append(res, domain.Media{
+			Id:        m.Id,
+			Uid:       m.Uid,
+			Key:       m.Key,
+			ThumbKey:  m.ThumbKey,
+			MimeType:  m.MimeType,
+			Size:      m.Size,
+			Width:     m.Width,
+			Height:    m.Height,
+			ArticleId: m.ArticleId,
+			Ctime:     time.UnixMilli(m.CreateTime),
+			Utime:     time.UnixMilli(m.UpdateTime),
+		})
+	}
+	return res
+}
@@ -0,0 +1,202 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/repository/async_sms.go
+//
+// Generated by this command:
+//
+//	mockgen -source=internal/repository/async_sms.go -package=mock_repository -destination=internal/repository/mocks/async_sms.mock.go
+//
+
+// Package mock_repository is a generated GoMock package.
+package mock_repository
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	domain "github.com/lutcoding/redbook/internal/domain"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockAsyncSmsRepository is a mock of AsyncSmsRepository interface.
+type MockAsyncSmsRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockAsyncSmsRepositoryMockRecorder
+}
+
+// MockAsyncSmsRepositoryMockRecorder is the mock recorder for MockAsyncSmsRepository.
+type MockAsyncSmsRepositoryMockRecorder struct {
+	mock *MockAsyncSmsRepository
+}
+
+// NewMockAsyncSmsRepository creates a new mock instance.
+func NewMockAsyncSmsRepository(ctrl *gomock.Controller) *MockAsyncSmsRepository {
+	mock := &MockAsyncSmsRepository{ctrl: ctrl}
+	mock.recorder = &MockAsyncSmsRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAsyncSmsRepository) EXPECT() *MockAsyncSmsRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Add mocks base method.
+func (m *MockAsyncSmsRepository) Add(ctx context.Context, sms domain.AsyncSms) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Add", ctx, sms)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Add indicates an expected call of Add.
+func (mr *MockAsyncSmsRepositoryMockRecorder) Add(ctx, sms any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Add", reflect.TypeOf((*MockAsyncSmsRepository)(nil).Add), ctx, sms)
+}
+
+// AddBatch mocks base method.
+func (m *MockAsyncSmsRepository) AddBatch(ctx context.Context, smses []domain.AsyncSms) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddBatch", ctx, smses)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddBatch indicates an expected call of AddBatch.
+func (mr *MockAsyncSmsRepositoryMockRecorder) AddBatch(ctx, smses any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddBatch", reflect.TypeOf((*MockAsyncSmsRepository)(nil).AddBatch), ctx, smses)
+}
+
+// ClaimWaitingSms mocks base method.
+func (m *MockAsyncSmsRepository) ClaimWaitingSms(ctx context.Context, limit int, lease time.Duration) ([]domain.AsyncSms, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimWaitingSms", ctx, limit, lease)
+	ret0, _ := ret[0].([]domain.AsyncSms)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimWaitingSms indicates an expected call of ClaimWaitingSms.
+func (mr *MockAsyncSmsRepositoryMockRecorder) ClaimWaitingSms(ctx, limit, lease any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimWaitingSms", reflect.TypeOf((*MockAsyncSmsRepository)(nil).ClaimWaitingSms), ctx, limit, lease)
+}
+
+// Count mocks base method.
+func (m *MockAsyncSmsRepository) Count(ctx context.Context, query domain.AsyncSmsQuery) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Count", ctx, query)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Count indicates an expected call of Count.
+func (mr *MockAsyncSmsRepositoryMockRecorder) Count(ctx, query any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Count", reflect.TypeOf((*MockAsyncSmsRepository)(nil).Count), ctx, query)
+}
+
+// FindById mocks base method.
+func (m *MockAsyncSmsRepository) FindById(ctx context.Context, id int64) (domain.AsyncSms, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindById", ctx, id)
+	ret0, _ := ret[0].(domain.AsyncSms)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindById indicates an expected call of FindById.
+func (mr *MockAsyncSmsRepositoryMockRecorder) FindById(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindById", reflect.TypeOf((*MockAsyncSmsRepository)(nil).FindById), ctx, id)
+}
+
+// List mocks base method.
+func (m *MockAsyncSmsRepository) List(ctx context.Context, query domain.AsyncSmsQuery, limit, offset int) ([]domain.AsyncSms, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, query, limit, offset)
+	ret0, _ := ret[0].([]domain.AsyncSms)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockAsyncSmsRepositoryMockRecorder) List(ctx, query, limit, offset any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockAsyncSmsRepository)(nil).List), ctx, query, limit, offset)
+}
+
+// ListAttempts mocks base method.
+func (m *MockAsyncSmsRepository) ListAttempts(ctx context.Context, smsId int64) ([]domain.AsyncSmsAttempt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAttempts", ctx, smsId)
+	ret0, _ := ret[0].([]domain.AsyncSmsAttempt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAttempts indicates an expected call of ListAttempts.
+func (mr *MockAsyncSmsRepositoryMockRecorder) ListAttempts(ctx, smsId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAttempts", reflect.TypeOf((*MockAsyncSmsRepository)(nil).ListAttempts), ctx, smsId)
+}
+
+// MarkFailed mocks base method.
+func (m *MockAsyncSmsRepository) MarkFailed(ctx context.Context, attempt domain.AsyncSmsAttempt, nextRetryAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkFailed", ctx, attempt, nextRetryAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkFailed indicates an expected call of MarkFailed.
+func (mr *MockAsyncSmsRepositoryMockRecorder) MarkFailed(ctx, attempt, nextRetryAt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkFailed", reflect.TypeOf((*MockAsyncSmsRepository)(nil).MarkFailed), ctx, attempt, nextRetryAt)
+}
+
+// MarkSuccess mocks base method.
+func (m *MockAsyncSmsRepository) MarkSuccess(ctx context.Context, attempt domain.AsyncSmsAttempt) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkSuccess", ctx, attempt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkSuccess indicates an expected call of MarkSuccess.
+func (mr *MockAsyncSmsRepositoryMockRecorder) MarkSuccess(ctx, attempt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkSuccess", reflect.TypeOf((*MockAsyncSmsRepository)(nil).MarkSuccess), ctx, attempt)
+}
+
+// Postpone mocks base method.
+func (m *MockAsyncSmsRepository) Postpone(ctx context.Context, sms domain.AsyncSms, nextRetryAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Postpone", ctx, sms, nextRetryAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Postpone indicates an expected call of Postpone.
+func (mr *MockAsyncSmsRepositoryMockRecorder) Postpone(ctx, sms, nextRetryAt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Postpone", reflect.TypeOf((*MockAsyncSmsRepository)(nil).Postpone), ctx, sms, nextRetryAt)
+}
+
+// Resend mocks base method.
+func (m *MockAsyncSmsRepository) Resend(ctx context.Context, id int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Resend", ctx, id)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Resend indicates an expected call of Resend.
+func (mr *MockAsyncSmsRepositoryMockRecorder) Resend(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Resend", reflect.TypeOf((*MockAsyncSmsRepository)(nil).Resend), ctx, id)
+}
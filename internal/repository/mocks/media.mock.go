@@ -0,0 +1,116 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/repository/media.go
+//
+// Generated by this command:
+//
+//	mockgen -source=internal/repository/media.go -package=mock_repository -destination=internal/repository/mocks/media.mock.go
+//
+
+// Package mock_repository is a generated GoMock package.
+package mock_repository
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	domain "github.com/lutcoding/redbook/internal/domain"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockMediaRepository is a mock of MediaRepository interface.
+type MockMediaRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockMediaRepositoryMockRecorder
+}
+
+// MockMediaRepositoryMockRecorder is the mock recorder for MockMediaRepository.
+type MockMediaRepositoryMockRecorder struct {
+	mock *MockMediaRepository
+}
+
+// NewMockMediaRepository creates a new mock instance.
+func NewMockMediaRepository(ctrl *gomock.Controller) *MockMediaRepository {
+	mock := &MockMediaRepository{ctrl: ctrl}
+	mock.recorder = &MockMediaRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMediaRepository) EXPECT() *MockMediaRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Bind mocks base method.
+func (m *MockMediaRepository) Bind(ctx context.Context, articleId int64, keys []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Bind", ctx, articleId, keys)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Bind indicates an expected call of Bind.
+func (mr *MockMediaRepositoryMockRecorder) Bind(ctx, articleId, keys any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Bind", reflect.TypeOf((*MockMediaRepository)(nil).Bind), ctx, articleId, keys)
+}
+
+// Create mocks base method.
+func (m_2 *MockMediaRepository) Create(ctx context.Context, m domain.Media) (int64, error) {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "Create", ctx, m)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockMediaRepositoryMockRecorder) Create(ctx, m any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockMediaRepository)(nil).Create), ctx, m)
+}
+
+// DeleteUnbound mocks base method.
+func (m *MockMediaRepository) DeleteUnbound(ctx context.Context, id int64, before time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUnbound", ctx, id, before)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteUnbound indicates an expected call of DeleteUnbound.
+func (mr *MockMediaRepositoryMockRecorder) DeleteUnbound(ctx, id, before any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUnbound", reflect.TypeOf((*MockMediaRepository)(nil).DeleteUnbound), ctx, id, before)
+}
+
+// FindByKeys mocks base method.
+func (m *MockMediaRepository) FindByKeys(ctx context.Context, keys []string) ([]domain.Media, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByKeys", ctx, keys)
+	ret0, _ := ret[0].([]domain.Media)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByKeys indicates an expected call of FindByKeys.
+func (mr *MockMediaRepositoryMockRecorder) FindByKeys(ctx, keys any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByKeys", reflect.TypeOf((*MockMediaRepository)(nil).FindByKeys), ctx, keys)
+}
+
+// ListUnbound mocks base method.
+func (m *MockMediaRepository) ListUnbound(ctx context.Context, before time.Time, limit int) ([]domain.Media, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUnbound", ctx, before, limit)
+	ret0, _ := ret[0].([]domain.Media)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUnbound indicates an expected call of ListUnbound.
+func (mr *MockMediaRepositoryMockRecorder) ListUnbound(ctx, before, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUnbound", reflect.TypeOf((*MockMediaRepository)(nil).ListUnbound), ctx, before, limit)
+}
@@ -0,0 +1,128 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/repository/sms_token.go
+//
+// Generated by this command:
+//
+//	mockgen -source=internal/repository/sms_token.go -package=mock_repository -destination=internal/repository/mocks/sms_token.mock.go
+//
+
+// Package mock_repository is a generated GoMock package.
+package mock_repository
+
+import (
+	context "context"
+	reflect "reflect"
+
+	domain "github.com/lutcoding/redbook/internal/domain"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockSmsTokenRepository is a mock of SmsTokenRepository interface.
+type MockSmsTokenRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockSmsTokenRepositoryMockRecorder
+}
+
+// MockSmsTokenRepositoryMockRecorder is the mock recorder for MockSmsTokenRepository.
+type MockSmsTokenRepositoryMockRecorder struct {
+	mock *MockSmsTokenRepository
+}
+
+// NewMockSmsTokenRepository creates a new mock instance.
+func NewMockSmsTokenRepository(ctrl *gomock.Controller) *MockSmsTokenRepository {
+	mock := &MockSmsTokenRepository{ctrl: ctrl}
+	mock.recorder = &MockSmsTokenRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSmsTokenRepository) EXPECT() *MockSmsTokenRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Consume mocks base method.
+func (m *MockSmsTokenRepository) Consume(ctx context.Context, t domain.SmsToken, cnt int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Consume", ctx, t, cnt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Consume indicates an expected call of Consume.
+func (mr *MockSmsTokenRepositoryMockRecorder) Consume(ctx, t, cnt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Consume", reflect.TypeOf((*MockSmsTokenRepository)(nil).Consume), ctx, t, cnt)
+}
+
+// Create mocks base method.
+func (m *MockSmsTokenRepository) Create(ctx context.Context, t domain.SmsToken) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, t)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockSmsTokenRepositoryMockRecorder) Create(ctx, t any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockSmsTokenRepository)(nil).Create), ctx, t)
+}
+
+// FindById mocks base method.
+func (m *MockSmsTokenRepository) FindById(ctx context.Context, id int64) (domain.SmsToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindById", ctx, id)
+	ret0, _ := ret[0].(domain.SmsToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindById indicates an expected call of FindById.
+func (mr *MockSmsTokenRepositoryMockRecorder) FindById(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindById", reflect.TypeOf((*MockSmsTokenRepository)(nil).FindById), ctx, id)
+}
+
+// List mocks base method.
+func (m *MockSmsTokenRepository) List(ctx context.Context, limit, offset int) ([]domain.SmsToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, limit, offset)
+	ret0, _ := ret[0].([]domain.SmsToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockSmsTokenRepositoryMockRecorder) List(ctx, limit, offset any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockSmsTokenRepository)(nil).List), ctx, limit, offset)
+}
+
+// Refund mocks base method.
+func (m *MockSmsTokenRepository) Refund(ctx context.Context, id, cnt int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Refund", ctx, id, cnt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Refund indicates an expected call of Refund.
+func (mr *MockSmsTokenRepositoryMockRecorder) Refund(ctx, id, cnt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Refund", reflect.TypeOf((*MockSmsTokenRepository)(nil).Refund), ctx, id, cnt)
+}
+
+// Revoke mocks base method.
+func (m *MockSmsTokenRepository) Revoke(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revoke", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Revoke indicates an expected call of Revoke.
+func (mr *MockSmsTokenRepositoryMockRecorder) Revoke(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revoke", reflect.TypeOf((*MockSmsTokenRepository)(nil).Revoke), ctx, id)
+}
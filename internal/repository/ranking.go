@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/internal/repository/cache"
+	"go.uber.org/zap"
+)
+
+type RankingRepository interface {
+	ReplaceTopN(ctx context.Context, arts []domain.Article) error
+	GetTopN(ctx context.Context) ([]domain.Article, error)
+}
+
+// RankingCacheRepository 先查本地缓存, 再查 Redis, Redis 出错时强制使用本地缓存
+type RankingCacheRepository struct {
+	redis cache.RankingCache
+	local *cache.RankingLocalCache
+}
+
+func NewRankingCacheRepository(redis cache.RankingCache, local *cache.RankingLocalCache) *RankingCacheRepository {
+	return &RankingCacheRepository{
+		redis: redis,
+		local: local,
+	}
+}
+
+func (repo *RankingCacheRepository) ReplaceTopN(ctx context.Context, arts []domain.Article) error {
+	// 本地缓存不会出错
+	_ = repo.local.Set(ctx, arts)
+	return repo.redis.Set(ctx, arts)
+}
+
+func (repo *RankingCacheRepository) GetTopN(ctx context.Context) ([]domain.Article, error) {
+	arts, err := repo.local.Get(ctx)
+	if err == nil {
+		return arts, nil
+	}
+	arts, err = repo.redis.Get(ctx)
+	if errors.Is(err, cache.ErrKeyNotExist) {
+		// 还没有计算过热榜, 或者很久没有计算成功了
+		if arts, err = repo.local.ForceGet(ctx); err != nil {
+			return []domain.Article{}, nil
+		}
+		return arts, nil
+	}
+	if err != nil {
+		zap.L().Warn("查询 Redis 热榜失败, 使用本地缓存", zap.Error(err))
+		return repo.local.ForceGet(ctx)
+	}
+	_ = repo.local.Set(ctx, arts)
+	return arts, nil
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/internal/repository/cache"
+	"github.com/lutcoding/redbook/internal/repository/dao"
+	"go.uber.org/zap"
+	"time"
+)
+
+var (
+	ErrSmsTokenNotFound  = dao.ErrSmsTokenNotFound
+	ErrSmsRateLimited    = cache.ErrSmsRateLimited
+	ErrSmsQuotaExhausted = cache.ErrSmsQuotaExhausted
+)
+
+type SmsTokenRepository interface {
+	Create(ctx context.Context, t domain.SmsToken) (int64, error)
+	FindById(ctx context.Context, id int64) (domain.SmsToken, error)
+	// List 带上本月用量
+	List(ctx context.Context, limit, offset int) ([]domain.SmsToken, error)
+	Revoke(ctx context.Context, id int64) error
+	// Consume 扣减额度, 超过限制时返回 ErrSmsRateLimited 或者 ErrSmsQuotaExhausted
+	Consume(ctx context.Context, t domain.SmsToken, cnt int64) error
+	Refund(ctx context.Context, id int64, cnt int64) error
+}
+
+type SmsTokenCacheRepository struct {
+	dao   dao.SmsTokenDAO
+	cache cache.SmsTokenCache
+}
+
+func NewSmsTokenCacheRepository(dao dao.SmsTokenDAO, cache cache.SmsTokenCache) *SmsTokenCacheRepository {
+	return &SmsTokenCacheRepository{
+		dao:   dao,
+		cache: cache,
+	}
+}
+
+func (repo *SmsTokenCacheRepository) Create(ctx context.Context, t domain.SmsToken) (int64, error) {
+	return repo.dao.Insert(ctx, repo.domainToEntity(t))
+}
+
+// FindById 每次发送短信都要查, 先查缓存. 吊销的时候会删除缓存
+func (repo *SmsTokenCacheRepository) FindById(ctx context.Context, id int64) (domain.SmsToken, error) {
+	t, err := repo.cache.Get(ctx, id)
+	if err == nil {
+		return t, nil
+	}
+	if err != cache.ErrKeyNotExist {
+		zap.L().Error("查询短信凭证缓存失败", zap.Int64("id", id), zap.Error(err))
+	}
+	entity, err := repo.dao.FindById(ctx, id)
+	if err != nil {
+		return domain.SmsToken{}, err
+	}
+	t = repo.entityToDomain(entity)
+	if er := repo.cache.Set(ctx, t); er != nil {
+		zap.L().Error("缓存短信凭证失败", zap.Int64("id", id), zap.Error(er))
+	}
+	return t, nil
+}
+
+func (repo *SmsTokenCacheRepository) List(ctx context.Context, limit, offset int) ([]domain.SmsToken, error) {
+	entities, err := repo.dao.List(ctx, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.SmsToken, 0, len(entities))
+	ids := make([]int64, 0, len(entities))
+	for _, entity := range entities {
+		res = append(res, repo.entityToDomain(entity))
+		ids = append(ids, entity.Id)
+	}
+	usages, err := repo.cache.Usages(ctx, ids, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	for i := range res {
+		res[i].Used = usages[i]
+	}
+	return res, nil
+}
+
+func (repo *SmsTokenCacheRepository) Revoke(ctx context.Context, id int64) error {
+	err := repo.dao.Revoke(ctx, id)
+	if err != nil {
+		return err
+	}
+	return repo.cache.Del(ctx, id)
+}
+
+func (repo *SmsTokenCacheRepository) Consume(ctx context.Context, t domain.SmsToken, cnt int64) error {
+	return repo.cache.Consume(ctx, t, cnt, time.Now())
+}
+
+func (repo *SmsTokenCacheRepository) Refund(ctx context.Context, id int64, cnt int64) error {
+	return repo.cache.Refund(ctx, id, cnt, time.Now())
+}
+
+func (repo *SmsTokenCacheRepository) domainToEntity(t domain.SmsToken) dao.SmsToken {
+	tpls, _ := json.Marshal(t.Tpls)
+	res := dao.SmsToken{
+		Id:            t.Id,
+		Biz:           t.Biz,
+		Tpls:          tpls,
+		MonthlyQuota:  t.MonthlyQuota,
+		RatePerMinute: t.RatePerMinute,
+		Revoked:       t.Revoked,
+		Creator:       t.Creator,
+	}
+	if !t.ExpireAt.IsZero() {
+		res.ExpireTime = t.ExpireAt.UnixMilli()
+	}
+	return res
+}
+
+func (repo *SmsTokenCacheRepository) entityToDomain(t dao.SmsToken) domain.SmsToken {
+	var tpls []string
+	_ = json.Unmarshal(t.Tpls, &tpls)
+	res := domain.SmsToken{
+		Id:            t.Id,
+		Biz:           t.Biz,
+		Tpls:          tpls,
+		MonthlyQuota:  t.MonthlyQuota,
+		RatePerMinute: t.RatePerMinute,
+		Revoked:       t.Revoked,
+		Creator:       t.Creator,
+		Ctime:         time.UnixMilli(t.CreateTime),
+		Utime:         time.UnixMilli(t.UpdateTime),
+	}
+	if t.ExpireTime > 0 {
+		res.ExpireAt = time.UnixMilli(t.ExpireTime)
+	}
+	return res
+}
@@ -2,10 +2,17 @@ package internal
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/IBM/sarama"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/bwmarrin/snowflake"
+	"github.com/lutcoding/redbook/common/globalkey"
 	"github.com/lutcoding/redbook/internal/config"
 	"github.com/lutcoding/redbook/internal/events"
 	articleMsgQueue "github.com/lutcoding/redbook/internal/events/article"
+	"github.com/lutcoding/redbook/internal/job"
 	"github.com/lutcoding/redbook/internal/repository"
 	"github.com/lutcoding/redbook/internal/repository/cache"
 	articleDao "github.com/lutcoding/redbook/internal/repository/dao/article"
@@ -13,16 +20,31 @@ import (
 	articleService "github.com/lutcoding/redbook/internal/service/article"
 	"github.com/lutcoding/redbook/internal/service/oauth/dingtalk"
 	"github.com/lutcoding/redbook/internal/service/oauth/wechat"
+	"github.com/lutcoding/redbook/internal/service/sms"
+	"github.com/lutcoding/redbook/internal/service/sms/aliyun"
+	"github.com/lutcoding/redbook/internal/service/sms/async"
+	"github.com/lutcoding/redbook/internal/service/sms/auth"
+	"github.com/lutcoding/redbook/internal/service/sms/failover"
 	"github.com/lutcoding/redbook/internal/service/sms/memory"
+	"github.com/lutcoding/redbook/internal/service/sms/template"
+	"github.com/lutcoding/redbook/internal/service/sms/tencent"
 	"github.com/lutcoding/redbook/internal/web/article"
 	"github.com/lutcoding/redbook/internal/web/jwt"
+	"github.com/lutcoding/redbook/internal/web/media"
 	"github.com/lutcoding/redbook/internal/web/oauth"
+	smsHdl "github.com/lutcoding/redbook/internal/web/sms"
 	"github.com/spf13/viper"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
+	tencentSms "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/sms/v20210111"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"go.uber.org/zap"
 	"net/http"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -33,27 +55,52 @@ import (
 	smsratelimit "github.com/lutcoding/redbook/internal/service/sms/ratelimit"
 	"github.com/lutcoding/redbook/internal/web/middleware"
 	"github.com/lutcoding/redbook/internal/web/user"
+	"github.com/lutcoding/redbook/pkg/ginx/middlewares/metrics"
 	"github.com/lutcoding/redbook/pkg/ginx/middlewares/ratelimit"
+	"github.com/lutcoding/redbook/pkg/gormx"
+	"github.com/lutcoding/redbook/pkg/objstore"
+	"github.com/lutcoding/redbook/pkg/redisx"
+	"github.com/lutcoding/redbook/pkg/saramax"
+	"github.com/lutcoding/redbook/pkg/scheduler"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
 
 type Server struct {
-	route       *gin.Engine
-	srv         *http.Server
-	db          *gorm.DB
-	redis       redis.Cmdable
-	cfg         config.Config
-	mongo       *mongo.Client
-	msgConsumer []events.Consumer
-	kafkaClient sarama.Client
+	route         *gin.Engine
+	srv           *http.Server
+	db            *gorm.DB
+	redis         redis.UniversalClient
+	invalidator   *cache.Invalidator
+	cfg           config.Config
+	mongo         *mongo.Client
+	objStore      objstore.ObjectStore
+	localObjStore *objstore.LocalStore
+	msgConsumer   []events.Consumer
+	kafkaClient   sarama.Client
 
 	jwtHandler            *jwt.Handler
 	userHandler           *user.Handler
 	oauth2WeChatHandler   *oauth.OAuth2WeChatHandler
 	oAuth2DingTalkHandler *oauth.OAuth2DingTalkHandler
 	articleHandler        *article.Handler
+	mediaHandler          *media.Handler
+	smsHandler            *smsHdl.Handler
+	smsTokenHandler       *smsHdl.TokenHandler
+	smsTemplateHandler    *smsHdl.TemplateHandler
+
+	asyncSmsSvc *async.Service
+
+	rankingJob *job.RankingJob
+	mediaGCJob *job.MediaGCJob
+	pruneJob   *job.RevisionPruneJob
+	publishJob *job.ScheduledPublishJob
+	purgeJob   *job.TrashPurgeJob
+	scheduler  *scheduler.Scheduler
+
+	closers []closer
 }
 
 func NewServer() (*Server, error) {
@@ -71,73 +118,193 @@ func NewServer() (*Server, error) {
 }
 
 func (s *Server) Serve(addr string) (err error) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	defer func() {
+		// 启动失败时也要释放已经初始化的资源
+		if er := s.shutdown(); er != nil && err == nil {
+			err = er
+		}
+	}()
 	err = s.initLog()
 	if err != nil {
 		return
 	}
-	//err = s.initMongo()
-	//if err != nil {
-	//	return err
-	//}
+	if err = s.initTrace(); err != nil {
+		return
+	}
+	s.startMetrics()
 	err = s.initSarama()
 	if err != nil {
 		return err
 	}
+	if err = s.initDB(); err != nil {
+		return
+	}
+	if s.cfg.Article.Storage == articleStorageMongo {
+		if err = s.initMongo(); err != nil {
+			return
+		}
+	}
+	s.initRedis()
 	s.msgConsumer, err = s.initMsgConsumer()
 	if err != nil {
 		return err
 	}
-	if s.db, err = gorm.Open(mysql.Open(s.cfg.DB.Mysql.DSN)); err != nil {
+	if err = dao.InitTables(s.db); err != nil {
 		return
 	}
-	s.redis = redis.NewClient(&redis.Options{
-		Addr: s.cfg.Redis.Addr,
-	})
-	if err = dao.InitTables(s.db); err != nil {
+	if err = s.initObjectStore(); err != nil {
 		return
 	}
 	if err = s.initHandlers(); err != nil {
 		return
 	}
-	err = s.startConsumer()
+	s.startInvalidator(ctx)
+	s.startAsyncSms(ctx)
+	err = s.startConsumer(ctx)
 	if err != nil {
 		return err
 	}
+	if err = s.startScheduler(); err != nil {
+		return
+	}
 	s.route = s.newRouter()
 	s.srv = &http.Server{
 		Addr:              addr,
 		Handler:           s.route,
 		ReadHeaderTimeout: time.Second * 15,
 	}
-	err = s.srv.ListenAndServe()
-	if err != nil && err != http.ErrServerClosed {
-		return
+	s.onShutdown("http", s.srv.Shutdown)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.srv.ListenAndServe()
+	}()
+	select {
+	case err = <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			err = nil
+		}
+	case <-ctx.Done():
+		zap.L().Info("收到退出信号, 开始优雅退出")
+	}
+	return err
+}
+
+func (s *Server) initDB() error {
+	db, err := gorm.Open(mysql.Open(s.cfg.DB.Mysql.DSN))
+	if err != nil {
+		return err
 	}
+	if err = db.Use(gormx.NewMetricsPlugin("redbook", "")); err != nil {
+		return err
+	}
+	if err = db.Use(gormx.NewTracingPlugin()); err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	s.db = db
+	s.onShutdown("mysql", func(ctx context.Context) error {
+		return sqlDB.Close()
+	})
 	return nil
 }
 
+func (s *Server) initRedis() {
+	client := redis.NewClient(&redis.Options{
+		Addr: s.cfg.Redis.Addr,
+	})
+	client.AddHook(redisx.NewMetricsHook("redbook", ""))
+	client.AddHook(redisx.NewTracingHook())
+	s.redis = client
+	s.onShutdown("redis", func(ctx context.Context) error {
+		return client.Close()
+	})
+}
+
 func (s *Server) initSarama() error {
 	var err error
 	cfg := sarama.NewConfig()
 	cfg.Producer.Return.Successes = true
 	s.kafkaClient, err = sarama.NewClient(s.cfg.Kafka.Addrs, cfg)
-	return err
+	if err != nil {
+		return err
+	}
+	s.onShutdown("kafka", func(ctx context.Context) error {
+		return s.kafkaClient.Close()
+	})
+	return nil
 }
 
 func (s *Server) initMsgConsumer() ([]events.Consumer, error) {
-	return nil, nil
+	interactiveRepo := repository.NewInteractiveCacheRepository(
+		dao.NewGORMInteractiveDAO(s.db), cache.NewInteractiveRedisCache(s.redis))
+	return []events.Consumer{
+		articleMsgQueue.NewInteractiveReadEventConsumer(s.kafkaClient, interactiveRepo),
+	}, nil
 }
 
-func (s *Server) startConsumer() error {
+func (s *Server) startConsumer(ctx context.Context) error {
 	for _, consumer := range s.msgConsumer {
-		err := consumer.Start()
+		consumer := consumer
+		err := consumer.Start(ctx)
 		if err != nil {
 			return err
 		}
+		s.onShutdown("consumer", func(ctx context.Context) error {
+			return consumer.Close()
+		})
 	}
 	return nil
 }
 
+// startScheduler 注册并启动定时任务, 多实例部署时每个任务只会在一个实例上执行
+func (s *Server) startScheduler() error {
+	s.scheduler = scheduler.NewScheduler(s.redis)
+	if err := s.scheduler.AddJob("@every 1m", s.rankingJob); err != nil {
+		return err
+	}
+	if err := s.scheduler.AddJob("@every 1h", s.mediaGCJob); err != nil {
+		return err
+	}
+	if err := s.scheduler.AddJob("@daily", s.pruneJob); err != nil {
+		return err
+	}
+	if err := s.scheduler.AddJob("@every 30s", s.publishJob); err != nil {
+		return err
+	}
+	if err := s.scheduler.AddJob("@every 1h", s.purgeJob); err != nil {
+		return err
+	}
+	s.scheduler.Start()
+	s.onShutdown("scheduler", s.scheduler.Stop)
+	return nil
+}
+
+// startMetrics 单独监听一个端口给 prometheus 拉取指标, 不经过业务的中间件
+func (s *Server) startMetrics() {
+	addr := s.cfg.Metrics.Addr
+	if addr == "" {
+		addr = ":8081"
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: time.Second * 15,
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			zap.L().Error("metrics 服务退出", zap.Error(err))
+		}
+	}()
+	s.onShutdown("metrics", srv.Shutdown)
+}
+
 func (s *Server) initLog() (err error) {
 	logger, err := zap.NewDevelopment()
 	if err != nil {
@@ -161,49 +328,449 @@ func (s *Server) initMongo() error {
 		return err
 	}
 	s.mongo = client
+	s.onShutdown("mongo", client.Disconnect)
+	return nil
+}
+
+const (
+	articleStorageGORM  = "gorm"
+	articleStorageMongo = "mongo"
+	articleStorageS3    = "s3"
+)
+
+const codeCacheLocal = "local"
+
+const smsFailoverTimeout = "timeout"
+
+// newSmsTemplateRegistry 没有配置模板时返回 nil
+func (s *Server) newSmsTemplateRegistry() (*template.Registry, error) {
+	cfg := s.cfg.SMS
+	if len(cfg.Templates) == 0 {
+		return nil, nil
+	}
+	templates := make([]template.Template, 0, len(cfg.Templates))
+	for _, tpl := range cfg.Templates {
+		params := make([]template.Param, 0, len(tpl.Params))
+		for _, p := range tpl.Params {
+			params = append(params, template.Param{
+				Name:   p.Name,
+				MinLen: p.MinLen,
+				MaxLen: p.MaxLen,
+				Digits: p.Digits,
+			})
+		}
+		locales := make(map[string]template.Locale, len(tpl.Locales))
+		for name, l := range tpl.Locales {
+			providers := make(map[string]template.ProviderTemplate, len(l.Providers))
+			for provider, pt := range l.Providers {
+				providers[provider] = template.ProviderTemplate{Id: pt.Id, Params: pt.Params}
+			}
+			locales[name] = template.Locale{Content: l.Content, Providers: providers}
+		}
+		templates = append(templates, template.Template{Name: tpl.Name, Params: params, Locales: locales})
+	}
+	locale := cfg.DefaultLocale
+	if locale == "" {
+		locale = "zh-CN"
+	}
+	return template.NewRegistry(templates, locale)
+}
+
+// newSmsService 按配置创建短信服务商, 多个服务商时加上 failover.
+// 配置了模板时每个服务商把模板名字转换成自己的模板 id
+func (s *Server) newSmsService(registry *template.Registry) (sms.Service, error) {
+	cfg := s.cfg.SMS
+	var providers []failover.Provider
+	if cfg.Tencent.SecretId != "" {
+		client, err := tencentSms.NewClient(common.NewCredential(cfg.Tencent.SecretId, cfg.Tencent.SecretKey),
+			cfg.Tencent.Region, profile.NewClientProfile())
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, failover.Provider{
+			Name:    "tencent",
+			Service: tencent.NewService(client, cfg.Tencent.AppId, cfg.Tencent.SignName),
+		})
+	}
+	if cfg.Aliyun.AccessKeyId != "" {
+		client := aliyun.NewClient(cfg.Aliyun.AccessKeyId, cfg.Aliyun.AccessKeySecret)
+		if cfg.Aliyun.Endpoint != "" {
+			client.SetEndpoint(cfg.Aliyun.Endpoint)
+		}
+		svc := aliyun.NewService(client, cfg.Aliyun.SignName)
+		if registry != nil {
+			svc.SetParamNames(func(tplId string) []string {
+				return registry.ParamNames("aliyun", tplId)
+			})
+		}
+		providers = append(providers, failover.Provider{
+			Name:    "aliyun",
+			Service: svc,
+		})
+	}
+	if registry != nil {
+		for i := range providers {
+			providers[i].Service = template.NewProviderService(providers[i].Service, registry, providers[i].Name)
+		}
+	}
+	switch len(providers) {
+	case 0:
+		return memory.NewService(), nil
+	case 1:
+		return providers[0].Service, nil
+	}
+	if cfg.Failover == smsFailoverTimeout {
+		threshold := cfg.TimeoutThreshold
+		if threshold <= 0 {
+			threshold = 3
+		}
+		return failover.NewTimeoutFailoverService(providers, threshold), nil
+	}
+	return failover.NewService(providers), nil
+}
+
+// newAsyncSmsService 在限流外面再包一层, 被限流也算作出错, 会转为异步发送
+// newAsyncSmsService svc 是同步发送用的, 已经限流; provider 是没有限流的服务商,
+// 后台发送异步短信单独限流, 群发不会占用验证码的额度
+func (s *Server) newAsyncSmsService(svc, provider sms.Service, repo repository.AsyncSmsRepository) *async.Service {
+	cfg := s.cfg.SMS.Async
+	if cfg.RetryMax <= 0 {
+		cfg.RetryMax = 3
+	}
+	if cfg.RateLimit <= 0 {
+		cfg.RateLimit = 600
+	}
+	asyncSvc := async.NewService(svc, repo, cfg.RetryMax).
+		SetWorkerService(smsratelimit.NewService(provider,
+			ratelimit.NewRedisSlidingWindowLimiter(s.redis, time.Minute, cfg.RateLimit)).
+			SetKey("sms_async_ratelimit"))
+	if cfg.Window > 0 {
+		asyncSvc.SetWindow(cfg.Window)
+	}
+	if cfg.LatencyThreshold > 0 {
+		asyncSvc.SetLatencyThreshold(cfg.LatencyThreshold)
+	}
+	if cfg.ErrRateThreshold > 0 {
+		asyncSvc.SetErrRateThreshold(cfg.ErrRateThreshold)
+	}
+	if cfg.MinRequests > 0 {
+		asyncSvc.SetMinRequests(cfg.MinRequests)
+	}
+	if cfg.CoolDown > 0 {
+		asyncSvc.SetCoolDown(cfg.CoolDown)
+	}
+	if cfg.ProbeRatio > 0 {
+		asyncSvc.SetProbeRatio(cfg.ProbeRatio)
+	}
+	if cfg.MinProbes > 0 {
+		asyncSvc.SetMinProbes(cfg.MinProbes)
+	}
+	if cfg.Workers > 0 {
+		asyncSvc.SetWorkers(cfg.Workers)
+	}
+	if cfg.BatchSize > 0 {
+		asyncSvc.SetBatchSize(cfg.BatchSize)
+	}
+	return asyncSvc
+}
+
+// startAsyncSms 在后台发送异步短信, 包括转为异步的验证码和管理后台的群发, 定时短信
+func (s *Server) startAsyncSms(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := s.asyncSmsSvc.Run(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			zap.L().Error("异步短信发送退出", zap.Error(err))
+		}
+	}()
+	s.onShutdown("async_sms", func(ctx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// newArticleDAO 根据 cfg.Article.Storage 选择文章的存储
+func (s *Server) newArticleDAO() (articleDao.ArticleDAO, error) {
+	switch s.cfg.Article.Storage {
+	case "", articleStorageGORM:
+		return articleDao.NewGORMArticleDao(s.db), nil
+	case articleStorageMongo:
+		name := s.cfg.DB.Mongo.Database
+		if name == "" {
+			name = "redbook"
+		}
+		db := s.mongo.Database(name)
+		if err := articleDao.InitCollections(db); err != nil {
+			return nil, err
+		}
+		node, err := snowflake.NewNode(s.cfg.DB.Mongo.NodeId)
+		if err != nil {
+			return nil, err
+		}
+		return articleDao.NewMongoArticleDao(db, node), nil
+	case articleStorageS3:
+		return articleDao.NewS3DAO(s.objStore, s.db), nil
+	default:
+		return nil, fmt.Errorf("未知的文章存储: %s", s.cfg.Article.Storage)
+	}
+}
+
+// initObjectStore 根据 cfg.OSS.Provider 初始化对象存储
+func (s *Server) initObjectStore() error {
+	cfg := s.cfg.OSS
+	switch cfg.Provider {
+	case "", "s3":
+		s.objStore = objstore.NewS3Store(s.newS3Client(), cfg.Bucket)
+	case "local":
+		store, err := objstore.NewLocalStore(cfg.Dir, cfg.BaseURL, []byte(cfg.Secret))
+		if err != nil {
+			return err
+		}
+		s.objStore, s.localObjStore = store, store
+	default:
+		return fmt.Errorf("未知的对象存储: %s", cfg.Provider)
+	}
 	return nil
 }
 
+func (s *Server) newS3Client() *s3.Client {
+	cfg := s.cfg.OSS
+	opts := s3.Options{
+		Region: cfg.Region,
+		Credentials: aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{
+				AccessKeyID:     cfg.AccessKeyId,
+				SecretAccessKey: cfg.SecretAccessKey,
+			}, nil
+		}),
+		// 兼容 minio 之类的 S3 实现
+		UsePathStyle: true,
+	}
+	if cfg.Endpoint != "" {
+		opts.BaseEndpoint = aws.String(cfg.Endpoint)
+	}
+	return s3.New(opts)
+}
+
 func (s *Server) initHandlers() error {
 	artReadProducer, err := sarama.NewSyncProducerFromClient(s.kafkaClient)
 	if err != nil {
 		return err
 	}
-	articleReadProducer := articleMsgQueue.NewKafkaProducer(artReadProducer, "article_read")
+	s.onShutdown("article_read_producer", func(ctx context.Context) error {
+		return artReadProducer.Close()
+	})
+	articleReadProducer := articleMsgQueue.NewKafkaProducer(saramax.NewMetricsSyncProducer(artReadProducer),
+		articleMsgQueue.TopicReadEvent)
 
 	userDAO := dao.NewUserGormDAO(s.db)
-	articleDAO := articleDao.NewGORMArticleDao(s.db)
+	articleDAO, err := s.newArticleDAO()
+	if err != nil {
+		return err
+	}
 	interactiveDAO := dao.NewGORMInteractiveDAO(s.db)
+	collectionDAO := dao.NewGORMCollectionDAO(s.db)
+	mediaDAO := dao.NewGORMMediaDAO(s.db)
+	revisionDAO := dao.NewGORMArticleRevisionDAO(s.db)
 
-	userCache := cache.NewUserRedisCache(s.redis)
-	codeCache := cache.NewCodeRedisCache(s.redis)
-	articleCache := cache.NewArticleRedisCache(s.redis)
-	interactiveCache := cache.NewInteractiveRedisCache(s.redis)
+	userCache, articleCache, interactiveCache := s.newCaches()
+	codeCache := s.newCodeCache()
 
 	userRepo := repository.NewUserCacheRepository(userDAO, userCache)
 	codeRepo := repository.NewCodeCacheRepository(codeCache)
 	articleRepo := repository.NewArticleCacheRepository(articleDAO, articleCache)
 	interactiveRepo := repository.NewInteractiveCacheRepository(interactiveDAO, interactiveCache)
+	collectionRepo := repository.NewCollectionCacheRepository(collectionDAO)
+	mediaRepo := repository.NewMediaCacheRepository(mediaDAO)
+	revisionRepo := repository.NewArticleRevisionCacheRepository(revisionDAO)
+	rankingRepo := repository.NewRankingCacheRepository(cache.NewRankingRedisCache(s.redis), cache.NewRankingLocalCache())
 
 	userSvc := service.NewUserService(userRepo)
-	smsRateLimitSvc := smsratelimit.NewService(memory.NewService(),
+	smsRegistry, err := s.newSmsTemplateRegistry()
+	if err != nil {
+		return err
+	}
+	providerSvc, err := s.newSmsService(smsRegistry)
+	if err != nil {
+		return err
+	}
+	var smsSvc sms.Service = smsratelimit.NewService(providerSvc,
 		ratelimit.NewRedisSlidingWindowLimiter(s.redis, time.Minute, 10))
-	codeSvc := service.NewCodeService(codeRepo, smsRateLimitSvc, "1")
+	asyncSmsRepo := repository.NewAsyncSmsCacheRepository(dao.NewAsyncSmsGormDAO(s.db))
+	s.asyncSmsSvc = s.newAsyncSmsService(smsSvc, providerSvc, asyncSmsRepo)
+	if s.cfg.SMS.Async.Enabled {
+		smsSvc = s.asyncSmsSvc
+	}
+	codeTpl := s.cfg.Code.Template
+	if smsRegistry != nil {
+		smsSvc = template.NewService(smsSvc, smsRegistry)
+		s.smsTemplateHandler = smsHdl.NewTemplateHandler(smsRegistry)
+		if codeTpl == "" {
+			codeTpl = "login_code"
+		}
+	}
+	if codeTpl == "" {
+		codeTpl = "1"
+	}
+	codeSvc := service.NewCodeService(codeRepo, smsSvc, codeTpl)
 	wechatSvc := wechat.NewService(s.cfg.Wechat.AppID, s.cfg.Wechat.AppSecret)
 	dingTalkSvc := dingtalk.NewService(s.cfg.Ding.AppKey, s.cfg.Ding.AppSecret)
-	articleSvc := articleService.NewService(articleRepo, articleReadProducer)
+	mediaSvc := service.NewMediaService(mediaRepo, s.objStore)
+	if s.cfg.Media.MaxSize > 0 {
+		mediaSvc.SetMaxSize(s.cfg.Media.MaxSize)
+	}
+	articleSvc := articleService.NewService(articleRepo, revisionRepo, interactiveRepo, mediaSvc, articleReadProducer)
 	interactiveSvc := service.NewInteractiveService(interactiveRepo)
+	collectionSvc := service.NewCollectionService(collectionRepo)
+	rankingSvc := service.NewRankingService(articleRepo, interactiveRepo, rankingRepo)
 
 	s.jwtHandler = jwt.NewHandler()
-	s.userHandler = user.New(userSvc, codeSvc, s.jwtHandler)
+	s.userHandler = user.New(userSvc, codeSvc, collectionSvc, s.jwtHandler)
 	s.oauth2WeChatHandler = oauth.NewOAuth2WeChatHandler(wechatSvc, userSvc)
 	s.oAuth2DingTalkHandler = oauth.NewOAuth2DingTalkHandler(dingTalkSvc, userSvc)
-	s.articleHandler = article.NewHandler(articleSvc, interactiveSvc)
+	s.articleHandler = article.NewHandler(articleSvc, interactiveSvc, rankingSvc)
+	s.mediaHandler = media.NewHandler(mediaSvc)
+	smsAdminSvc := service.NewSmsAdminService(asyncSmsRepo, smsSvc).SetRegistry(smsRegistry)
+	if s.cfg.SMS.Async.RetryMax > 0 {
+		smsAdminSvc.SetRetryMax(s.cfg.SMS.Async.RetryMax)
+	}
+	s.smsHandler = smsHdl.NewHandler(smsAdminSvc)
+	if s.cfg.SMS.Auth.Key != "" {
+		key := []byte(s.cfg.SMS.Auth.Key)
+		tokenRepo := repository.NewSmsTokenCacheRepository(dao.NewGORMSmsTokenDAO(s.db),
+			cache.NewSmsTokenRedisCache(s.redis))
+		s.smsTokenHandler = smsHdl.NewTokenHandler(auth.NewTokenService(tokenRepo, key),
+			auth.NewService(smsSvc, tokenRepo, key))
+	}
+	s.rankingJob = job.NewRankingJob(rankingSvc, time.Second*30)
+	gcGrace := s.cfg.Media.GCGrace
+	if gcGrace <= 0 {
+		gcGrace = time.Hour * 24
+	}
+	s.mediaGCJob = job.NewMediaGCJob(mediaSvc, gcGrace, time.Minute*10)
+	revisionCfg := s.cfg.Article.Revision
+	if revisionCfg.MaxAge <= 0 {
+		revisionCfg.MaxAge = time.Hour * 24 * 30
+	}
+	if revisionCfg.Keep <= 0 {
+		revisionCfg.Keep = 20
+	}
+	s.pruneJob = job.NewRevisionPruneJob(articleSvc, revisionCfg.MaxAge, revisionCfg.Keep, time.Minute*10)
+	s.publishJob = job.NewScheduledPublishJob(articleSvc, time.Second*30)
+	retention := s.cfg.Article.Trash.Retention
+	if retention <= 0 {
+		retention = time.Hour * 24 * 30
+	}
+	s.purgeJob = job.NewTrashPurgeJob(articleSvc, retention, time.Minute*10)
 	return nil
 }
 
+func (s *Server) newCodeCache() cache.CodeCache {
+	if s.cfg.Code.Cache != codeCacheLocal {
+		return cache.NewCodeRedisCache(s.redis)
+	}
+	size := s.cfg.Code.LocalSize
+	if size <= 0 {
+		size = 100000
+	}
+	c := cache.NewCodeLocalCache(size)
+	s.onShutdown("code_local_cache", func(ctx context.Context) error {
+		return c.Close()
+	})
+	return c
+}
+
+// newCaches 按配置在 redis 缓存前面加一层本地缓存
+func (s *Server) newCaches() (cache.UserCache, cache.ArticleCache, cache.InteractiveCache) {
+	var (
+		userCache        cache.UserCache        = cache.NewUserRedisCache(s.redis)
+		articleCache     cache.ArticleCache     = cache.NewArticleRedisCache(s.redis)
+		interactiveCache cache.InteractiveCache = cache.NewInteractiveRedisCache(s.redis)
+	)
+	cfg := s.cfg.LocalCache
+	if !cfg.User.Enabled && !cfg.Article.Enabled && !cfg.Interactive.Enabled {
+		return userCache, articleCache, interactiveCache
+	}
+	channel := cfg.Channel
+	if channel == "" {
+		channel = globalkey.CacheInvalidateChannel
+	}
+	s.invalidator = cache.NewInvalidator(s.redis, channel)
+	if cfg.User.Enabled {
+		size, ttl := localCacheOptions(cfg.User)
+		c := cache.NewUserLocalCache(userCache, s.invalidator, size, ttl)
+		s.onShutdown("user_local_cache", func(ctx context.Context) error {
+			return c.Close()
+		})
+		userCache = c
+	}
+	if cfg.Article.Enabled {
+		size, ttl := localCacheOptions(cfg.Article)
+		c := cache.NewArticleLocalCache(articleCache, s.invalidator, size, ttl)
+		s.onShutdown("article_local_cache", func(ctx context.Context) error {
+			return c.Close()
+		})
+		articleCache = c
+	}
+	if cfg.Interactive.Enabled {
+		size, ttl := localCacheOptions(cfg.Interactive)
+		c := cache.NewInteractiveLocalCache(interactiveCache, s.invalidator, size, ttl)
+		s.onShutdown("interactive_local_cache", func(ctx context.Context) error {
+			return c.Close()
+		})
+		interactiveCache = c
+	}
+	return userCache, articleCache, interactiveCache
+}
+
+func localCacheOptions(cfg config.LocalCacheEntry) (int, time.Duration) {
+	size, ttl := cfg.Size, cfg.TTL
+	if size <= 0 {
+		size = 10000
+	}
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+	return size, ttl
+}
+
+// startInvalidator 订阅其他实例的本地缓存失效通知
+func (s *Server) startInvalidator(ctx context.Context) {
+	if s.invalidator == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := s.invalidator.Run(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			zap.L().Error("本地缓存失效通知订阅退出", zap.Error(err))
+		}
+	}()
+	s.onShutdown("cache_invalidator", func(ctx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
 func (s *Server) newRouter() *gin.Engine {
 	engine := gin.Default()
+	// handler 直接把 *gin.Context 当作 context.Context 往下传, 需要能取到 otelgin 放进 Request 的 span
+	engine.ContextWithFallback = true
+	engine.Use(otelgin.Middleware(serviceName))
 	store, _ := sr.NewStore(10, "tcp", s.cfg.Redis.Addr, "", []byte("secret"))
 	engine.Use(sessions.Sessions("SESSION", store))
 	// 允许跨域
@@ -226,6 +793,7 @@ func (s *Server) newRouter() *gin.Engine {
 		MaxAge: 12 * time.Hour,
 	}))
 
+	engine.Use(metrics.NewBuilder("redbook", "").Build())
 	engine.Use(ratelimit.NewBuilder(ratelimit.NewRedisSlidingWindowLimiter(s.redis, time.Minute, 100)).Build())
 	// 注册handler
 	root := engine.Group("/")
@@ -236,6 +804,14 @@ func (s *Server) newRouter() *gin.Engine {
 		unauthorized.POST("/users/login_sms/code/send", s.userHandler.SendLoginSmsCode)
 		unauthorized.POST("/users/login_sms", s.userHandler.LoginSmsCode)
 		unauthorized.GET("/users/refresh", s.userHandler.Refresh)
+		if s.smsTokenHandler != nil {
+			// 业务方用管理后台签发的凭证发送短信
+			unauthorized.POST("/sms/send", s.smsTokenHandler.Send)
+		}
+		if s.localObjStore != nil {
+			// 下载链接自带签名, 不需要登录
+			unauthorized.GET("/objects/*key", gin.WrapH(http.StripPrefix("/objects", s.localObjStore)))
+		}
 		oauth2 := unauthorized.Group("/oauth2")
 		{
 			wg := oauth2.Group("/wechat")
@@ -258,16 +834,36 @@ func (s *Server) newRouter() *gin.Engine {
 			ug.POST("/edit", s.userHandler.Edit)
 			ug.GET("/profile", s.userHandler.Profile)
 			ug.POST("/logout", s.userHandler.Logout)
+			cg := ug.Group("/collections")
+			{
+				cg.POST("/create", s.userHandler.CreateCollection)
+				cg.GET("/list", s.userHandler.ListCollections)
+				cg.POST("/items", s.userHandler.ListCollectItems)
+				cg.POST("/move", s.userHandler.MoveCollectItem)
+			}
+		}
+
+		mg := authorized.Group("/media")
+		{
+			mg.POST("/upload", s.mediaHandler.Upload)
+			mg.GET("/url", s.mediaHandler.URL)
 		}
 
 		ag := authorized.Group("/articles")
 		{
+			ag.GET("/hot", s.articleHandler.Hot)
 			draft := ag.Group("/draft")
 			{
 				draft.POST("/create", s.articleHandler.Create)
 				draft.POST("/list", s.articleHandler.ListDraft)
 				draft.POST("/update", s.articleHandler.Edit)
 				draft.POST("/get", s.articleHandler.GetDraft)
+				draft.POST("/delete", s.articleHandler.Delete)
+			}
+			trash := ag.Group("/trash")
+			{
+				trash.POST("/list", s.articleHandler.ListTrash)
+				trash.POST("/restore", s.articleHandler.Restore)
 			}
 			published := ag.Group("/published")
 			{
@@ -277,6 +873,44 @@ func (s *Server) newRouter() *gin.Engine {
 				published.POST("/list", s.articleHandler.ListPub)
 				published.GET("/get/:id", s.articleHandler.GetPub)
 				published.POST("/like", s.articleHandler.Like)
+				published.POST("/collect", s.articleHandler.Collect)
+				published.POST("/schedule", s.articleHandler.Schedule)
+				published.POST("/schedule/cancel", s.articleHandler.CancelSchedule)
+			}
+			revisions := ag.Group("/revisions")
+			{
+				revisions.POST("/list", s.articleHandler.ListRevisions)
+				revisions.POST("/diff", s.articleHandler.DiffRevisions)
+				revisions.POST("/restore", s.articleHandler.RestoreRevision)
+			}
+		}
+
+		admin := authorized.Group("/admin", middleware.NewAdminMiddlewareBuilder(s.cfg.Admin.Uids).Build())
+		{
+			sg := admin.Group("/sms")
+			{
+				sg.POST("/list", s.smsHandler.List)
+				sg.POST("/detail", s.smsHandler.Detail)
+				sg.POST("/export", s.smsHandler.Export)
+				sg.POST("/resend", s.smsHandler.Resend)
+				sg.POST("/send", s.smsHandler.Send)
+				sg.POST("/bulk_send", s.smsHandler.BulkSend)
+				sg.POST("/schedule", s.smsHandler.Schedule)
+				if s.smsTemplateHandler != nil {
+					tplg := sg.Group("/templates")
+					{
+						tplg.POST("/list", s.smsTemplateHandler.List)
+						tplg.POST("/preview", s.smsTemplateHandler.Preview)
+					}
+				}
+				if s.smsTokenHandler != nil {
+					tg := sg.Group("/tokens")
+					{
+						tg.POST("/create", s.smsTokenHandler.Create)
+						tg.POST("/list", s.smsTokenHandler.List)
+						tg.POST("/revoke", s.smsTokenHandler.Revoke)
+					}
+				}
 			}
 		}
 	}
@@ -0,0 +1,156 @@
+package article
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/internal/repository"
+	"github.com/lutcoding/redbook/pkg/textdiff"
+	"go.uber.org/zap"
+	"time"
+)
+
+var (
+	ErrArticleNotFound  = errors.New("文章不存在")
+	ErrRevisionNotFound = errors.New("版本不存在")
+)
+
+const pruneBatchSize = 100
+
+// appendRevision 保存或发表成功之后记录一个版本.
+// 文章可能不在 mysql, 没办法和文章放在一个事务里, 失败时返回错误让调用方重试
+func (s *Service) appendRevision(ctx context.Context, art domain.Article, kind domain.RevisionKind) error {
+	_, err := s.revisionRepo.Create(ctx, domain.ArticleRevision{
+		ArticleId: art.Id,
+		Operator:  art.AuthorId,
+		Kind:      kind,
+		Tittle:    art.Tittle,
+		Content:   art.Content,
+		Cover:     art.Cover,
+		Images:    art.Images,
+	})
+	if err != nil {
+		return fmt.Errorf("记录文章 %d 的版本失败: %w", art.Id, err)
+	}
+	return nil
+}
+
+// ListRevisions 作者 uid 查看文章的历史版本, 新的在前, 不包含正文
+func (s *Service) ListRevisions(ctx context.Context, uid int64, articleId int64, limit, offset int) ([]domain.ArticleRevision, error) {
+	if err := s.checkAuthor(ctx, uid, articleId); err != nil {
+		return nil, err
+	}
+	return s.revisionRepo.List(ctx, articleId, limit, offset)
+}
+
+// DiffRevisions 比较同一篇文章的两个版本
+func (s *Service) DiffRevisions(ctx context.Context, uid int64, articleId int64, from, to int64) (domain.RevisionDiff, error) {
+	if err := s.checkAuthor(ctx, uid, articleId); err != nil {
+		return domain.RevisionDiff{}, err
+	}
+	fromRev, err := s.findRevision(ctx, articleId, from)
+	if err != nil {
+		return domain.RevisionDiff{}, err
+	}
+	toRev, err := s.findRevision(ctx, articleId, to)
+	if err != nil {
+		return domain.RevisionDiff{}, err
+	}
+	lines := textdiff.Lines(fromRev.Content, toRev.Content)
+	res := domain.RevisionDiff{
+		From:    fromRev,
+		To:      toRev,
+		Content: make([]domain.DiffLine, 0, len(lines)),
+	}
+	for _, l := range lines {
+		res.Content = append(res.Content, domain.DiffLine{Op: l.Op.String(), Text: l.Text})
+	}
+	return res, nil
+}
+
+// RestoreRevision 把历史版本恢复为当前草稿, 恢复本身也会产生一个新版本
+func (s *Service) RestoreRevision(ctx context.Context, uid int64, articleId int64, revisionId int64) (int64, error) {
+	if err := s.checkAuthor(ctx, uid, articleId); err != nil {
+		return 0, err
+	}
+	rev, err := s.findRevision(ctx, articleId, revisionId)
+	if err != nil {
+		return 0, err
+	}
+	return s.Save(ctx, domain.Article{
+		Id:       articleId,
+		Tittle:   rev.Tittle,
+		Content:  rev.Content,
+		Cover:    rev.Cover,
+		Images:   rev.Images,
+		AuthorId: uid,
+	})
+}
+
+// PruneRevisions 删除超过 maxAge 的版本, 但每篇文章至少保留最新的 keep 个, 返回删除的数量
+func (s *Service) PruneRevisions(ctx context.Context, maxAge time.Duration, keep int) (int64, error) {
+	before := time.Now().Add(-maxAge)
+	var (
+		total int64
+		after int64
+	)
+	for {
+		ids, err := s.revisionRepo.ListArticleIdsBefore(ctx, before, after, pruneBatchSize)
+		if err != nil {
+			return total, err
+		}
+		for _, id := range ids {
+			cnt, err := s.revisionRepo.DeleteBefore(ctx, id, before, keep)
+			if err != nil {
+				return total, err
+			}
+			total += cnt
+			if cnt > 0 {
+				s.rebindMedia(ctx, id)
+			}
+		}
+		if len(ids) < pruneBatchSize {
+			return total, nil
+		}
+		after = ids[len(ids)-1]
+	}
+}
+
+// rebindMedia 删除历史版本之后, 只被这些版本引用的图片解除绑定, 由图片回收任务删除
+func (s *Service) rebindMedia(ctx context.Context, id int64) {
+	art, err := s.repo.GetDraft(ctx, id)
+	if err != nil {
+		// 已经彻底删除的文章由 PurgeTrash 解除绑定
+		zap.L().Warn("查询文章草稿失败, 不更新图片绑定", zap.Int64("aid", id), zap.Error(err))
+		return
+	}
+	if err = s.bindMedia(ctx, id, art.MediaKeys()); err != nil {
+		zap.L().Error("更新文章图片绑定失败", zap.Int64("aid", id), zap.Error(err))
+	}
+}
+
+func (s *Service) checkAuthor(ctx context.Context, uid int64, articleId int64) error {
+	art, err := s.repo.GetDraft(ctx, articleId)
+	if err != nil {
+		return err
+	}
+	if art.AuthorId != uid {
+		return ErrArticleNotFound
+	}
+	return nil
+}
+
+func (s *Service) findRevision(ctx context.Context, articleId int64, id int64) (domain.ArticleRevision, error) {
+	rev, err := s.revisionRepo.FindById(ctx, id)
+	if errors.Is(err, repository.ErrRevisionNotFound) {
+		return domain.ArticleRevision{}, ErrRevisionNotFound
+	}
+	if err != nil {
+		return domain.ArticleRevision{}, err
+	}
+	if rev.ArticleId != articleId {
+		return domain.ArticleRevision{}, ErrRevisionNotFound
+	}
+	return rev, nil
+}
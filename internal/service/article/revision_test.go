@@ -0,0 +1,177 @@
+package article
+
+import (
+	"context"
+	"errors"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/internal/repository"
+	"github.com/lutcoding/redbook/internal/service"
+	"github.com/lutcoding/redbook/pkg/objstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// 保存新版本时删掉的图片被历史版本引用, 图片回收之后仍然可以恢复历史版本
+func TestService_RestoreRevisionAfterGC(t *testing.T) {
+	ctx := context.Background()
+	store, err := objstore.NewLocalStore(t.TempDir(), "http://localhost/objects", []byte("secret"))
+	require.NoError(t, err)
+	for _, key := range []string{"media/1/a.jpg", "media/1/b.jpg"} {
+		require.NoError(t, store.Put(ctx, key, []byte("x"), "image/jpeg"))
+	}
+	mediaRepo := &fakeMediaRepo{ms: map[string]*domain.Media{
+		"media/1/a.jpg": {Id: 1, Uid: 1, Key: "media/1/a.jpg"},
+		"media/1/b.jpg": {Id: 2, Uid: 1, Key: "media/1/b.jpg"},
+	}}
+	mediaSvc := service.NewMediaService(mediaRepo, store)
+	repo := &fakeArticleRepo{drafts: map[int64]domain.Article{1: {Id: 1, AuthorId: 1}}}
+	revisionRepo := &fakeRevisionRepo{}
+	svc := NewService(repo, revisionRepo, nil, mediaSvc, nil)
+
+	_, err = svc.Save(ctx, domain.Article{Id: 1, AuthorId: 1, Tittle: "v1", Images: []string{"media/1/a.jpg"}})
+	require.NoError(t, err)
+	_, err = svc.Save(ctx, domain.Article{Id: 1, AuthorId: 1, Tittle: "v2", Images: []string{"media/1/b.jpg"}})
+	require.NoError(t, err)
+
+	// 宽限期是负数, 所有没有被引用的图片都会被回收
+	cnt, err := mediaSvc.GC(ctx, -time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 0, cnt)
+	_, err = store.Get(ctx, "media/1/a.jpg")
+	require.NoError(t, err)
+
+	_, err = svc.RestoreRevision(ctx, 1, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", repo.drafts[1].Tittle)
+	assert.Equal(t, []string{"media/1/a.jpg"}, repo.drafts[1].Images)
+	assert.Len(t, revisionRepo.rs, 3)
+}
+
+func TestService_SaveRevisionFailed(t *testing.T) {
+	ctx := context.Background()
+	store, err := objstore.NewLocalStore(t.TempDir(), "http://localhost/objects", []byte("secret"))
+	require.NoError(t, err)
+	mediaSvc := service.NewMediaService(&fakeMediaRepo{ms: map[string]*domain.Media{}}, store)
+	repo := &fakeArticleRepo{drafts: map[int64]domain.Article{1: {Id: 1, AuthorId: 1}}}
+	svc := NewService(repo, &fakeRevisionRepo{err: errors.New("mock db error")}, nil, mediaSvc, nil)
+
+	id, err := svc.Save(ctx, domain.Article{Id: 1, AuthorId: 1, Tittle: "v1"})
+	assert.Error(t, err)
+	assert.Equal(t, int64(1), id)
+}
+
+// fakeArticleRepo 只实现了保存草稿用到的方法
+type fakeArticleRepo struct {
+	repository.ArticleRepository
+	drafts map[int64]domain.Article
+}
+
+func (r *fakeArticleRepo) Update(ctx context.Context, art domain.Article) error {
+	r.drafts[art.Id] = art
+	return nil
+}
+
+func (r *fakeArticleRepo) GetDraft(ctx context.Context, id int64) (domain.Article, error) {
+	art, ok := r.drafts[id]
+	if !ok {
+		return domain.Article{}, repository.ErrArticleNotFound
+	}
+	return art, nil
+}
+
+func (r *fakeArticleRepo) GetPub(ctx context.Context, id int64) (domain.Article, error) {
+	return domain.Article{}, repository.ErrArticleNotFound
+}
+
+type fakeRevisionRepo struct {
+	repository.ArticleRevisionRepository
+	rs      []domain.ArticleRevision
+	err     error
+	deleted []int64
+}
+
+func (r *fakeRevisionRepo) Create(ctx context.Context, rev domain.ArticleRevision) (int64, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	rev.Id = int64(len(r.rs) + 1)
+	r.rs = append(r.rs, rev)
+	return rev.Id, nil
+}
+
+func (r *fakeRevisionRepo) FindById(ctx context.Context, id int64) (domain.ArticleRevision, error) {
+	if id <= 0 || int(id) > len(r.rs) {
+		return domain.ArticleRevision{}, repository.ErrRevisionNotFound
+	}
+	return r.rs[id-1], nil
+}
+
+func (r *fakeRevisionRepo) DeleteByArticle(ctx context.Context, articleId int64) error {
+	r.deleted = append(r.deleted, articleId)
+	return nil
+}
+
+func (r *fakeRevisionRepo) MediaKeys(ctx context.Context, articleId int64) ([]string, error) {
+	var res []string
+	for _, rev := range r.rs {
+		if rev.ArticleId == articleId {
+			res = append(res, domain.Article{Cover: rev.Cover, Images: rev.Images}.MediaKeys()...)
+		}
+	}
+	return res, nil
+}
+
+// fakeMediaRepo 和 GORMMediaDAO 一样, 解除绑定时更新 Utime
+type fakeMediaRepo struct {
+	repository.MediaRepository
+	ms map[string]*domain.Media
+}
+
+func (r *fakeMediaRepo) FindByKeys(ctx context.Context, keys []string) ([]domain.Media, error) {
+	var res []domain.Media
+	for _, key := range keys {
+		if m, ok := r.ms[key]; ok {
+			res = append(res, *m)
+		}
+	}
+	return res, nil
+}
+
+func (r *fakeMediaRepo) Bind(ctx context.Context, articleId int64, keys []string) error {
+	now := time.Now()
+	bound := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		bound[key] = true
+	}
+	for key, m := range r.ms {
+		switch {
+		case m.ArticleId == articleId && !bound[key]:
+			m.ArticleId, m.Utime = 0, now
+		case m.ArticleId == 0 && bound[key]:
+			m.ArticleId, m.Utime = articleId, now
+		}
+	}
+	return nil
+}
+
+func (r *fakeMediaRepo) ListUnbound(ctx context.Context, before time.Time, limit int) ([]domain.Media, error) {
+	var res []domain.Media
+	for _, m := range r.ms {
+		if m.ArticleId == 0 && m.Utime.Before(before) && len(res) < limit {
+			res = append(res, *m)
+		}
+	}
+	return res, nil
+}
+
+func (r *fakeMediaRepo) DeleteUnbound(ctx context.Context, id int64, before time.Time) (bool, error) {
+	for key, m := range r.ms {
+		if m.Id == id && m.ArticleId == 0 && m.Utime.Before(before) {
+			delete(r.ms, key)
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -2,37 +2,161 @@ package article
 
 import (
 	"context"
+	"errors"
 	"github.com/lutcoding/redbook/internal/domain"
 	"github.com/lutcoding/redbook/internal/events/article"
 	"github.com/lutcoding/redbook/internal/repository"
+	"github.com/lutcoding/redbook/internal/service"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"time"
 )
 
+var ErrInvalidPublishAt = errors.New("定时发表的时间必须晚于当前时间")
+
+const publishBatchSize = 100
+
 type Service struct {
-	repo     repository.ArticleRepository
-	producer article.Producer
+	repo         repository.ArticleRepository
+	revisionRepo repository.ArticleRevisionRepository
+	interRepo    repository.InteractiveRepository
+	mediaSvc     *service.MediaService
+	producer     article.Producer
 }
 
-func NewService(repo repository.ArticleRepository, producer article.Producer) *Service {
+func NewService(repo repository.ArticleRepository, revisionRepo repository.ArticleRevisionRepository,
+	interRepo repository.InteractiveRepository, mediaSvc *service.MediaService, producer article.Producer) *Service {
 	return &Service{
-		repo:     repo,
-		producer: producer,
+		repo:         repo,
+		revisionRepo: revisionRepo,
+		interRepo:    interRepo,
+		mediaSvc:     mediaSvc,
+		producer:     producer,
 	}
 }
 
-func (s *Service) GetPub(ctx context.Context, id int64) (domain.Article, error) {
-	return s.repo.GetPub(ctx, id)
+// GetPub 读者 uid 阅读文章 id, 成功后发送阅读事件
+func (s *Service) GetPub(ctx context.Context, id int64, uid int64) (domain.Article, error) {
+	art, err := s.repo.GetPub(ctx, id)
+	if err != nil {
+		return domain.Article{}, err
+	}
+	// 请求结束后 ctx 会被取消, 只保留链路信息
+	sc := trace.SpanContextFromContext(ctx)
+	go func() {
+		ctx, cancel := context.WithTimeout(trace.ContextWithSpanContext(context.Background(), sc), time.Second)
+		defer cancel()
+		err := s.producer.ProduceReadEvent(ctx, article.ReadEvent{
+			Uid: uid,
+			Aid: id,
+		})
+		if err != nil {
+			zap.L().Error("发送阅读事件失败", zap.Int64("aid", id), zap.Error(err))
+		}
+	}()
+	return art, nil
 }
 
 func (s *Service) GetDraft(ctx context.Context, id int64) (domain.Article, error) {
 	return s.repo.GetDraft(ctx, id)
 }
 
+// Save 保存草稿, 定时发表的文章被修改后会取消定时发表
 func (s *Service) Save(ctx context.Context, art domain.Article) (int64, error) {
 	art.ArticleStatus = domain.ArticleStatusUnPublished
+	art.PublishAt = time.Time{}
+	return s.save(ctx, art)
+}
+
+// Schedule 保存草稿, 并在 publishAt 自动发表
+func (s *Service) Schedule(ctx context.Context, art domain.Article, publishAt time.Time) (int64, error) {
+	if !publishAt.After(time.Now()) {
+		return 0, ErrInvalidPublishAt
+	}
+	art.ArticleStatus = domain.ArticleStatusScheduled
+	art.PublishAt = publishAt
+	return s.save(ctx, art)
+}
+
+func (s *Service) CancelSchedule(ctx context.Context, id int64, authorId int64) error {
+	return s.repo.CancelSchedule(ctx, id, authorId)
+}
+
+// PublishDue 发表所有到期的定时文章, 返回发表的数量.
+// 单篇文章失败不影响其他文章, 下一次执行时会重试
+func (s *Service) PublishDue(ctx context.Context) (int, error) {
+	now := time.Now()
+	var (
+		cnt    int
+		after  int64
+		revErr error
+	)
+	for {
+		arts, err := s.repo.ListScheduled(ctx, now, after, publishBatchSize)
+		if err != nil {
+			return cnt, multierr.Append(revErr, err)
+		}
+		for _, art := range arts {
+			ok, err := s.repo.PublishScheduled(ctx, art)
+			if err != nil {
+				zap.L().Error("定时发表失败", zap.Int64("aid", art.Id), zap.Error(err))
+				continue
+			}
+			if !ok {
+				continue
+			}
+			cnt++
+			art.ArticleStatus = domain.ArticleStatusPublished
+			// 文章已经发表了, 下一次执行不会再处理它, 只能把错误返回给任务
+			if err = s.appendRevision(ctx, art, domain.RevisionKindPublish); err != nil {
+				revErr = multierr.Append(revErr, err)
+			}
+		}
+		if len(arts) < publishBatchSize {
+			return cnt, revErr
+		}
+		after = arts[len(arts)-1].Id
+	}
+}
+
+func (s *Service) save(ctx context.Context, art domain.Article) (int64, error) {
+	keys := art.MediaKeys()
+	if err := s.mediaSvc.Check(ctx, art.AuthorId, art.Id, keys); err != nil {
+		return 0, err
+	}
+	var (
+		id  int64
+		err error
+	)
 	if art.Id == 0 {
-		return s.Create(ctx, art)
+		id, err = s.Create(ctx, art)
+	} else {
+		id, err = s.Update(ctx, art)
+	}
+	if err != nil {
+		return 0, err
 	}
-	return s.Update(ctx, art)
+	art.Id = id
+	// 先绑定图片, 没有记录版本时图片也不会被回收. 失败时返回 id, 重试会更新同一篇文章
+	if err = s.bindMedia(ctx, id, keys); err != nil {
+		return id, err
+	}
+	return id, s.appendRevision(ctx, art, domain.RevisionKindDraft)
+}
+
+// bindMedia 文章 id 现在引用的图片是 keys. 线上版本和历史版本引用的图片也保持绑定,
+// 否则会被图片回收任务删除, 之后恢复历史版本就会引用不存在的图片
+func (s *Service) bindMedia(ctx context.Context, id int64, keys []string) error {
+	// 草稿删掉的图片, 线上版本可能还在用
+	if pub, err := s.repo.GetPub(ctx, id); err == nil {
+		keys = append(keys, pub.MediaKeys()...)
+	}
+	revKeys, err := s.revisionRepo.MediaKeys(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.mediaSvc.Bind(ctx, id, append(keys, revKeys...))
 }
 
 func (s *Service) Create(ctx context.Context, art domain.Article) (int64, error) {
@@ -45,7 +169,19 @@ func (s *Service) Update(ctx context.Context, art domain.Article) (int64, error)
 
 func (s *Service) Sync(ctx context.Context, art domain.Article) (int64, error) {
 	art.ArticleStatus = domain.ArticleStatusPublished
-	return s.repo.Sync(ctx, art)
+	keys := art.MediaKeys()
+	if err := s.mediaSvc.Check(ctx, art.AuthorId, art.Id, keys); err != nil {
+		return 0, err
+	}
+	id, err := s.repo.Sync(ctx, art)
+	if err != nil {
+		return 0, err
+	}
+	art.Id = id
+	if err = s.bindMedia(ctx, id, keys); err != nil {
+		return id, err
+	}
+	return id, s.appendRevision(ctx, art, domain.RevisionKindPublish)
 }
 
 func (s *Service) ToPrivate(ctx context.Context, id int64, authorId int64) error {
@@ -0,0 +1,100 @@
+package article
+
+import (
+	"context"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 两个实例同时执行定时发表, 翻页时另一个实例已经发表了部分文章, 每篇文章仍然只发表一次
+func TestService_PublishDue(t *testing.T) {
+	repo := &fakeScheduleRepo{published: map[int64]int{}}
+	now := time.Now()
+	total := publishBatchSize*2 + 10
+	for i := 1; i <= total; i++ {
+		repo.arts = append(repo.arts, domain.Article{
+			Id:            int64(i),
+			AuthorId:      1,
+			ArticleStatus: domain.ArticleStatusScheduled,
+			PublishAt:     now.Add(-time.Minute),
+		})
+	}
+	// 还没到时间
+	repo.arts = append(repo.arts, domain.Article{
+		Id:            int64(total + 1),
+		ArticleStatus: domain.ArticleStatusScheduled,
+		PublishAt:     now.Add(time.Hour),
+	})
+
+	var (
+		wg   sync.WaitGroup
+		cnts = make([]int, 2)
+	)
+	for i := range cnts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			svc := NewService(repo, &fakeRevisionRepo{}, nil, nil, nil)
+			cnt, err := svc.PublishDue(context.Background())
+			assert.NoError(t, err)
+			cnts[i] = cnt
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, total, cnts[0]+cnts[1])
+	require.Len(t, repo.published, total)
+	for id, cnt := range repo.published {
+		assert.Equal(t, 1, cnt, "文章 %d 发表了 %d 次", id, cnt)
+	}
+	assert.Equal(t, domain.ArticleStatusScheduled, repo.arts[total].ArticleStatus)
+}
+
+// fakeScheduleRepo 和 GORMArticleDao 一样按状态和时间条件发表
+type fakeScheduleRepo struct {
+	repository.ArticleRepository
+	mu        sync.Mutex
+	arts      []domain.Article
+	published map[int64]int
+}
+
+func (r *fakeScheduleRepo) ListScheduled(ctx context.Context, before time.Time, afterId int64, limit int) ([]domain.Article, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var res []domain.Article
+	for _, art := range r.arts {
+		if art.ArticleStatus == domain.ArticleStatusScheduled && !art.PublishAt.After(before) && art.Id > afterId {
+			res = append(res, art)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Id < res[j].Id
+	})
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+func (r *fakeScheduleRepo) PublishScheduled(ctx context.Context, art domain.Article) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.arts {
+		if r.arts[i].Id != art.Id {
+			continue
+		}
+		if r.arts[i].ArticleStatus != domain.ArticleStatusScheduled || r.arts[i].PublishAt.After(time.Now()) {
+			return false, nil
+		}
+		r.arts[i].ArticleStatus = domain.ArticleStatusPublished
+		r.published[art.Id]++
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,101 @@
+package article
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/internal/repository"
+	"go.uber.org/zap"
+	"time"
+)
+
+var ErrNotTrashed = errors.New("文章不在回收站")
+
+const (
+	purgeBatchSize = 100
+	// biz 文章在点赞, 阅读计数里的业务类型
+	biz = "article"
+)
+
+// Delete 把文章放进回收站, 线上版本也会一起下线
+func (s *Service) Delete(ctx context.Context, id int64, authorId int64) error {
+	err := s.repo.Delete(ctx, id, authorId)
+	if errors.Is(err, repository.ErrArticleNotFound) {
+		return ErrArticleNotFound
+	}
+	return err
+}
+
+// Restore 从回收站恢复, 文章回到删除之前的状态
+func (s *Service) Restore(ctx context.Context, id int64, authorId int64) error {
+	err := s.repo.Restore(ctx, id, authorId)
+	if errors.Is(err, repository.ErrArticleNotTrashed) {
+		return ErrNotTrashed
+	}
+	return err
+}
+
+func (s *Service) ListTrash(ctx context.Context, uid int64, limit, offset int) ([]domain.Article, error) {
+	return s.repo.ListTrash(ctx, uid, limit, offset)
+}
+
+// PurgeTrash 彻底删除在回收站里超过 retention 的文章, 返回删除的数量.
+// 先把文章标记为正在彻底删除, 不能再恢复, 然后清理计数, 点赞, 收藏, 历史版本和图片, 最后删除文章本身.
+// 中途失败的文章保持标记, 下一次执行时继续清理
+func (s *Service) PurgeTrash(ctx context.Context, retention time.Duration) (int, error) {
+	before := time.Now().Add(-retention)
+	var (
+		cnt   int
+		after int64
+	)
+	for {
+		arts, err := s.repo.ListTrashedBefore(ctx, before, after, purgeBatchSize)
+		if err != nil {
+			return cnt, err
+		}
+		for _, art := range arts {
+			ok, err := s.purge(ctx, art, before)
+			if err != nil {
+				zap.L().Error("彻底删除文章失败", zap.Int64("aid", art.Id), zap.Error(err))
+				continue
+			}
+			if ok {
+				cnt++
+			}
+		}
+		if len(arts) < purgeBatchSize {
+			return cnt, nil
+		}
+		after = arts[len(arts)-1].Id
+	}
+}
+
+// purge 返回 false 表示文章已经被恢复了
+func (s *Service) purge(ctx context.Context, art domain.Article, before time.Time) (bool, error) {
+	if art.ArticleStatus != domain.ArticleStatusPurging {
+		ok, err := s.repo.MarkPurging(ctx, art, before)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	if err := s.purgeRelated(ctx, art.Id); err != nil {
+		return false, err
+	}
+	return true, s.repo.Purge(ctx, art)
+}
+
+// purgeRelated 清理文章相关的数据, 每一步都可以重复执行
+func (s *Service) purgeRelated(ctx context.Context, id int64) error {
+	if err := s.interRepo.Delete(ctx, biz, id); err != nil {
+		return fmt.Errorf("删除文章计数失败: %w", err)
+	}
+	if err := s.revisionRepo.DeleteByArticle(ctx, id); err != nil {
+		return fmt.Errorf("删除文章历史版本失败: %w", err)
+	}
+	// 解除绑定之后由图片回收任务删除
+	if err := s.mediaSvc.Bind(ctx, id, nil); err != nil {
+		return fmt.Errorf("解除文章图片绑定失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package article
+
+import (
+	"context"
+	"errors"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/internal/repository"
+	"github.com/lutcoding/redbook/internal/service"
+	"github.com/lutcoding/redbook/pkg/objstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestService_DeleteRestore(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeTrashRepo{arts: map[int64]*domain.Article{
+		1: {Id: 1, AuthorId: 1, ArticleStatus: domain.ArticleStatusPublished},
+	}}
+	svc := NewService(repo, nil, nil, nil, nil)
+
+	assert.Equal(t, ErrArticleNotFound, svc.Delete(ctx, 1, 2))
+	require.NoError(t, svc.Delete(ctx, 1, 1))
+	assert.Equal(t, domain.ArticleStatusTrashed, repo.arts[1].ArticleStatus)
+	// 已经在回收站里
+	assert.Equal(t, ErrArticleNotFound, svc.Delete(ctx, 1, 1))
+
+	require.NoError(t, svc.Restore(ctx, 1, 1))
+	assert.Equal(t, domain.ArticleStatusPublished, repo.arts[1].ArticleStatus)
+	assert.Equal(t, ErrNotTrashed, svc.Restore(ctx, 1, 1))
+}
+
+// 清理失败的文章不能再恢复, 下一次执行时继续清理
+func TestService_PurgeTrash(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	repo := &fakeTrashRepo{arts: map[int64]*domain.Article{
+		1: {Id: 1, AuthorId: 1, ArticleStatus: domain.ArticleStatusTrashed, Dtime: now.Add(-time.Hour * 48)},
+		// 还没到保留时间
+		2: {Id: 2, AuthorId: 1, ArticleStatus: domain.ArticleStatusTrashed, Dtime: now},
+		// 上一次没有清理完
+		3: {Id: 3, AuthorId: 1, ArticleStatus: domain.ArticleStatusPurging, Dtime: now.Add(-time.Hour * 48)},
+	}}
+	interRepo := &fakeInteractiveRepo{failed: map[int64]bool{1: true}}
+	revisionRepo := &fakeRevisionRepo{}
+	store, err := objstore.NewLocalStore(t.TempDir(), "http://localhost/objects", []byte("secret"))
+	require.NoError(t, err)
+	mediaRepo := &fakeMediaRepo{ms: map[string]*domain.Media{
+		"media/1/a.jpg": {Id: 1, Uid: 1, Key: "media/1/a.jpg", ArticleId: 1},
+	}}
+	svc := NewService(repo, revisionRepo, interRepo, service.NewMediaService(mediaRepo, store), nil)
+
+	cnt, err := svc.PurgeTrash(ctx, time.Hour*24)
+	require.NoError(t, err)
+	assert.Equal(t, 1, cnt)
+	assert.Nil(t, repo.arts[3])
+	assert.Equal(t, domain.ArticleStatusPurging, repo.arts[1].ArticleStatus)
+	assert.Equal(t, ErrNotTrashed, svc.Restore(ctx, 1, 1))
+	assert.Equal(t, int64(1), mediaRepo.ms["media/1/a.jpg"].ArticleId)
+
+	interRepo.failed = nil
+	cnt, err = svc.PurgeTrash(ctx, time.Hour*24)
+	require.NoError(t, err)
+	assert.Equal(t, 1, cnt)
+	assert.Nil(t, repo.arts[1])
+	assert.Equal(t, []int64{3, 1}, interRepo.deleted)
+	assert.Equal(t, []int64{3, 1}, revisionRepo.deleted)
+	assert.Equal(t, int64(0), mediaRepo.ms["media/1/a.jpg"].ArticleId)
+	assert.Equal(t, domain.ArticleStatusTrashed, repo.arts[2].ArticleStatus)
+}
+
+// 列出来之后作者恢复了文章, 不能再清理
+func TestService_PurgeTrashRestored(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeTrashRepo{arts: map[int64]*domain.Article{
+		1: {Id: 1, AuthorId: 1, ArticleStatus: domain.ArticleStatusTrashed,
+			Dtime: time.Now().Add(-time.Hour * 48)},
+	}, prev: map[int64]domain.ArticleStatus{1: domain.ArticleStatusPublished}}
+	repo.beforeMark = func() {
+		require.NoError(t, repo.Restore(ctx, 1, 1))
+	}
+	interRepo := &fakeInteractiveRepo{}
+	svc := NewService(repo, &fakeRevisionRepo{}, interRepo, nil, nil)
+
+	cnt, err := svc.PurgeTrash(ctx, time.Hour*24)
+	require.NoError(t, err)
+	assert.Equal(t, 0, cnt)
+	assert.Equal(t, domain.ArticleStatusPublished, repo.arts[1].ArticleStatus)
+	assert.Empty(t, interRepo.deleted)
+}
+
+// fakeTrashRepo 和 GORMArticleDao 一样按状态判断能不能放进回收站, 恢复和彻底删除
+type fakeTrashRepo struct {
+	repository.ArticleRepository
+	arts map[int64]*domain.Article
+	// prev 放进回收站之前的状态, 对应 prev_status 字段
+	prev map[int64]domain.ArticleStatus
+	// beforeMark 模拟列出文章之后, 标记之前的并发操作
+	beforeMark func()
+}
+
+func (r *fakeTrashRepo) Delete(ctx context.Context, id int64, authorId int64) error {
+	art, ok := r.arts[id]
+	if !ok || art.AuthorId != authorId ||
+		art.ArticleStatus == domain.ArticleStatusTrashed || art.ArticleStatus == domain.ArticleStatusPurging {
+		return repository.ErrArticleNotFound
+	}
+	if r.prev == nil {
+		r.prev = map[int64]domain.ArticleStatus{}
+	}
+	r.prev[id] = art.ArticleStatus
+	art.ArticleStatus, art.Dtime = domain.ArticleStatusTrashed, time.Now()
+	return nil
+}
+
+func (r *fakeTrashRepo) Restore(ctx context.Context, id int64, authorId int64) error {
+	art, ok := r.arts[id]
+	if !ok || art.AuthorId != authorId || art.ArticleStatus != domain.ArticleStatusTrashed {
+		return repository.ErrArticleNotTrashed
+	}
+	art.ArticleStatus, art.Dtime = r.prev[id], time.Time{}
+	return nil
+}
+
+func (r *fakeTrashRepo) ListTrashedBefore(ctx context.Context, before time.Time, afterId int64, limit int) ([]domain.Article, error) {
+	var res []domain.Article
+	for _, art := range r.arts {
+		if art.Id <= afterId {
+			continue
+		}
+		if (art.ArticleStatus == domain.ArticleStatusTrashed && !art.Dtime.After(before)) ||
+			art.ArticleStatus == domain.ArticleStatusPurging {
+			res = append(res, *art)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Id < res[j].Id
+	})
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+func (r *fakeTrashRepo) MarkPurging(ctx context.Context, art domain.Article, before time.Time) (bool, error) {
+	if r.beforeMark != nil {
+		r.beforeMark()
+	}
+	a, ok := r.arts[art.Id]
+	if !ok || a.ArticleStatus != domain.ArticleStatusTrashed || a.Dtime.After(before) {
+		return false, nil
+	}
+	a.ArticleStatus = domain.ArticleStatusPurging
+	return true, nil
+}
+
+func (r *fakeTrashRepo) Purge(ctx context.Context, art domain.Article) error {
+	if a, ok := r.arts[art.Id]; ok && a.ArticleStatus == domain.ArticleStatusPurging {
+		delete(r.arts, art.Id)
+	}
+	return nil
+}
+
+// fakeInteractiveRepo failed 里的文章删除计数失败
+type fakeInteractiveRepo struct {
+	repository.InteractiveRepository
+	failed  map[int64]bool
+	deleted []int64
+}
+
+func (r *fakeInteractiveRepo) Delete(ctx context.Context, biz string, bizId int64) error {
+	if r.failed[bizId] {
+		return errors.New("mock db error")
+	}
+	r.deleted = append(r.deleted, bizId)
+	return nil
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/internal/repository"
+)
+
+var (
+	ErrDuplicateCollection = repository.ErrDuplicateCollection
+	ErrCollectionNotFound  = repository.ErrCollectionNotFound
+	ErrInvalidCollection   = errors.New("收藏夹名称不合法")
+)
+
+type CollectionService struct {
+	repo repository.CollectionRepository
+}
+
+func NewCollectionService(repo repository.CollectionRepository) *CollectionService {
+	return &CollectionService{
+		repo: repo,
+	}
+}
+
+func (s *CollectionService) Create(ctx context.Context, c domain.Collection) (int64, error) {
+	if c.Name == "" || len([]rune(c.Name)) > 64 {
+		return 0, ErrInvalidCollection
+	}
+	return s.repo.Create(ctx, c)
+}
+
+// List 列出用户的收藏夹, 不包含默认收藏夹
+func (s *CollectionService) List(ctx context.Context, uid int64) ([]domain.Collection, error) {
+	return s.repo.List(ctx, uid)
+}
+
+func (s *CollectionService) ListItems(ctx context.Context, uid, cid int64, limit, offset int) ([]domain.CollectItem, error) {
+	return s.repo.ListItems(ctx, uid, cid, limit, offset)
+}
+
+// Move 把收藏移动到收藏夹 cid
+func (s *CollectionService) Move(ctx context.Context, uid int64, biz string, bizId int64, cid int64) error {
+	return s.repo.MoveItem(ctx, uid, biz, bizId, cid)
+}
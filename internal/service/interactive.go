@@ -46,3 +46,12 @@ func (s *InteractiveService) Like(ctx context.Context, uid int64, biz string, bi
 func (s *InteractiveService) CancelLike(ctx context.Context, uid int64, biz string, bizId int64) error {
 	return s.repo.DecrLikeCnt(ctx, uid, biz, bizId)
 }
+
+// Collect 收藏到收藏夹 cid, cid 为 0 代表默认收藏夹
+func (s *InteractiveService) Collect(ctx context.Context, uid, cid int64, biz string, bizId int64) error {
+	return s.repo.IncrCollectCnt(ctx, uid, cid, biz, bizId)
+}
+
+func (s *InteractiveService) CancelCollect(ctx context.Context, uid int64, biz string, bizId int64) error {
+	return s.repo.DecrCollectCnt(ctx, uid, biz, bizId)
+}
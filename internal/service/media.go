@@ -0,0 +1,333 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/internal/repository"
+	"github.com/lutcoding/redbook/pkg/objstore"
+	"go.uber.org/zap"
+	"golang.org/x/image/draw"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMediaTooLarge    = errors.New("图片太大")
+	ErrUnsupportedMedia = errors.New("不支持的图片格式")
+	ErrInvalidMedia     = errors.New("图片不存在或不属于当前用户")
+)
+
+const (
+	// maxPixels 限制图片的像素数, 防止解码时占用过多内存
+	maxPixels   = 50_000_000
+	gcBatchSize = 100
+)
+
+type MediaService struct {
+	repo  repository.MediaRepository
+	store objstore.ObjectStore
+	// maxSize 上传图片的最大字节数
+	maxSize int64
+	// thumbWidth 缩略图的宽度, 高度按比例缩放
+	thumbWidth int
+	urlExpire  time.Duration
+}
+
+func NewMediaService(repo repository.MediaRepository, store objstore.ObjectStore) *MediaService {
+	return &MediaService{
+		repo:       repo,
+		store:      store,
+		maxSize:    10 << 20,
+		thumbWidth: 320,
+		urlExpire:  time.Hour,
+	}
+}
+
+func (s *MediaService) SetMaxSize(size int64) *MediaService {
+	s.maxSize = size
+	return s
+}
+
+func (s *MediaService) SetThumbWidth(width int) *MediaService {
+	s.thumbWidth = width
+	return s
+}
+
+func (s *MediaService) SetURLExpire(expire time.Duration) *MediaService {
+	s.urlExpire = expire
+	return s
+}
+
+func (s *MediaService) MaxSize() int64 {
+	return s.maxSize
+}
+
+// Upload 校验并保存用户 uid 上传的图片.
+// 图片会被重新编码, 去掉 EXIF 等元数据(包括拍摄地点), 同时生成缩略图.
+func (s *MediaService) Upload(ctx context.Context, uid int64, data []byte) (domain.Media, error) {
+	if int64(len(data)) > s.maxSize {
+		return domain.Media{}, ErrMediaTooLarge
+	}
+	// 只看内容, 不相信客户端传的 Content-Type 和文件名
+	mimeType := http.DetectContentType(data)
+	var ext string
+	switch mimeType {
+	case "image/jpeg":
+		ext = ".jpg"
+	case "image/png":
+		ext = ".png"
+	default:
+		return domain.Media{}, ErrUnsupportedMedia
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return domain.Media{}, ErrUnsupportedMedia
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return domain.Media{}, ErrMediaTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return domain.Media{}, ErrUnsupportedMedia
+	}
+	// 重新编码会丢掉 EXIF, 先按 Orientation 把图片转正
+	if mimeType == "image/jpeg" {
+		img = orient(img, jpegOrientation(data))
+	}
+	origin, err := encodeImage(img, mimeType)
+	if err != nil {
+		return domain.Media{}, err
+	}
+	thumb, err := encodeImage(s.thumbnail(img), mimeType)
+	if err != nil {
+		return domain.Media{}, err
+	}
+
+	name := uuid.New().String()
+	m := domain.Media{
+		Uid:      uid,
+		Key:      fmt.Sprintf("media/%d/%s%s", uid, name, ext),
+		ThumbKey: fmt.Sprintf("media/%d/%s_thumb%s", uid, name, ext),
+		MimeType: mimeType,
+		Size:     int64(len(origin)),
+		Width:    img.Bounds().Dx(),
+		Height:   img.Bounds().Dy(),
+	}
+	if err = s.store.Put(ctx, m.Key, origin, mimeType); err != nil {
+		return domain.Media{}, err
+	}
+	if err = s.store.Put(ctx, m.ThumbKey, thumb, mimeType); err != nil {
+		s.deleteObjects(ctx, m)
+		return domain.Media{}, err
+	}
+	m.Id, err = s.repo.Create(ctx, m)
+	if err != nil {
+		s.deleteObjects(ctx, m)
+		return domain.Media{}, err
+	}
+	return m, nil
+}
+
+// URL 生成图片的临时下载链接, key 必须在 media/ 下
+func (s *MediaService) URL(ctx context.Context, key string) (string, error) {
+	if path.Clean(key) != key || !strings.HasPrefix(key, "media/") {
+		return "", ErrInvalidMedia
+	}
+	return s.store.Presign(ctx, key, s.urlExpire)
+}
+
+// Check 校验 keys 都是用户 uid 上传的, 并且没有被其他文章引用
+func (s *MediaService) Check(ctx context.Context, uid int64, articleId int64, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	ms, err := s.repo.FindByKeys(ctx, keys)
+	if err != nil {
+		return err
+	}
+	found := make(map[string]domain.Media, len(ms))
+	for _, m := range ms {
+		found[m.Key] = m
+	}
+	for _, key := range keys {
+		m, ok := found[key]
+		if !ok || m.Uid != uid || (m.ArticleId != 0 && m.ArticleId != articleId) {
+			return ErrInvalidMedia
+		}
+	}
+	return nil
+}
+
+// Bind 记录文章 articleId 引用了哪些图片, 不再引用的图片会在宽限期之后被回收
+func (s *MediaService) Bind(ctx context.Context, articleId int64, keys []string) error {
+	return s.repo.Bind(ctx, articleId, keys)
+}
+
+// GC 回收超过宽限期 grace 仍然没有被引用的图片, 返回回收的数量
+func (s *MediaService) GC(ctx context.Context, grace time.Duration) (int, error) {
+	before := time.Now().Add(-grace)
+	cnt := 0
+	for {
+		ms, err := s.repo.ListUnbound(ctx, before, gcBatchSize)
+		if err != nil {
+			return cnt, err
+		}
+		for _, m := range ms {
+			// 先删记录再删对象, 删除记录时会再次确认没有被引用
+			ok, err := s.repo.DeleteUnbound(ctx, m.Id, before)
+			if err != nil {
+				return cnt, err
+			}
+			if ok {
+				s.deleteObjects(ctx, m)
+				cnt++
+			}
+		}
+		if len(ms) < gcBatchSize {
+			return cnt, nil
+		}
+	}
+}
+
+func (s *MediaService) deleteObjects(ctx context.Context, m domain.Media) {
+	for _, key := range []string{m.Key, m.ThumbKey} {
+		if err := s.store.Delete(ctx, key); err != nil {
+			zap.L().Error("删除图片失败", zap.String("key", key), zap.Error(err))
+		}
+	}
+}
+
+func (s *MediaService) thumbnail(img image.Image) image.Image {
+	bounds := img.Bounds()
+	if bounds.Dx() <= s.thumbWidth {
+		return img
+	}
+	height := bounds.Dy() * s.thumbWidth / bounds.Dx()
+	if height == 0 {
+		height = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, s.thumbWidth, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+	return dst
+}
+
+func encodeImage(img image.Image, mimeType string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if mimeType == "image/png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+	}
+	return buf.Bytes(), err
+}
+
+// jpegOrientation 读取 jpeg 中 EXIF 的 Orientation, 没有或者解析失败时返回 1
+func jpegOrientation(data []byte) int {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		// SOS 之后是图像数据, 不会再有 APP1
+		if marker == 0xDA || marker == 0xD9 {
+			return 1
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return 1
+		}
+		seg := data[i+4 : i+2+size]
+		if marker == 0xE1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			return tiffOrientation(seg[6:])
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	n := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < n; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		// 0x0112 Orientation, 类型是 SHORT
+		if order.Uint16(tiff[entry:]) == 0x0112 && order.Uint16(tiff[entry+2:]) == 3 {
+			o := int(order.Uint16(tiff[entry+8:]))
+			if o < 1 || o > 8 {
+				return 1
+			}
+			return o
+		}
+	}
+	return 1
+}
+
+// orient 按 EXIF Orientation 翻转/旋转图片
+func orient(img image.Image, o int) image.Image {
+	if o <= 1 || o > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	// 5-8 需要旋转 90 度, 宽高互换
+	dw, dh := w, h
+	if o >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch o {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
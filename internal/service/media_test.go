@@ -0,0 +1,156 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"github.com/lutcoding/redbook/internal/domain"
+	mock_repository "github.com/lutcoding/redbook/internal/repository/mocks"
+	"github.com/lutcoding/redbook/pkg/objstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+	"time"
+)
+
+func TestMediaService_Upload(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	store, err := objstore.NewLocalStore(t.TempDir(), "http://localhost/objects", []byte("secret"))
+	require.NoError(t, err)
+	repo := mock_repository.NewMockMediaRepository(ctrl)
+	svc := NewMediaService(repo, store).SetMaxSize(1 << 20).SetThumbWidth(100)
+
+	_, err = svc.Upload(ctx, 1, []byte("hello world"))
+	assert.Equal(t, ErrUnsupportedMedia, err)
+	_, err = svc.Upload(ctx, 1, make([]byte, 1<<20+1))
+	assert.Equal(t, ErrMediaTooLarge, err)
+
+	repo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(int64(10), nil)
+	m, err := svc.Upload(ctx, 1, jpegWithExif(t, 400, 200))
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), m.Id)
+	assert.Equal(t, "image/jpeg", m.MimeType)
+	assert.Equal(t, 400, m.Width)
+
+	origin, err := store.Get(ctx, m.Key)
+	require.NoError(t, err)
+	assert.False(t, bytes.Contains(origin, []byte("Exif")), "EXIF 没有去掉")
+	thumb, err := store.Get(ctx, m.ThumbKey)
+	require.NoError(t, err)
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(thumb))
+	require.NoError(t, err)
+	assert.Equal(t, 100, cfg.Width)
+	assert.Equal(t, 50, cfg.Height)
+}
+
+func TestMediaService_GC(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	store, err := objstore.NewLocalStore(t.TempDir(), "http://localhost/objects", []byte("secret"))
+	require.NoError(t, err)
+	for _, key := range []string{"media/1/a.jpg", "media/1/a_thumb.jpg", "media/1/b.jpg", "media/1/b_thumb.jpg"} {
+		require.NoError(t, store.Put(ctx, key, []byte("x"), "image/jpeg"))
+	}
+	repo := mock_repository.NewMockMediaRepository(ctrl)
+	repo.EXPECT().ListUnbound(gomock.Any(), gomock.Any(), gcBatchSize).Return([]domain.Media{
+		{Id: 1, Key: "media/1/a.jpg", ThumbKey: "media/1/a_thumb.jpg"},
+		{Id: 2, Key: "media/1/b.jpg", ThumbKey: "media/1/b_thumb.jpg"},
+	}, nil)
+	repo.EXPECT().DeleteUnbound(gomock.Any(), int64(1), gomock.Any()).Return(true, nil)
+	// 查询之后又被文章引用了
+	repo.EXPECT().DeleteUnbound(gomock.Any(), int64(2), gomock.Any()).Return(false, nil)
+	svc := NewMediaService(repo, store)
+
+	cnt, err := svc.GC(ctx, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 1, cnt)
+	_, err = store.Get(ctx, "media/1/a.jpg")
+	assert.Equal(t, objstore.ErrObjectNotFound, err)
+	_, err = store.Get(ctx, "media/1/b_thumb.jpg")
+	assert.NoError(t, err)
+}
+
+// jpegWithExif 生成一张带 EXIF 段的 jpeg
+func jpegWithExif(t *testing.T, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, nil))
+	data := buf.Bytes()
+	payload := append([]byte("Exif\x00\x00"), []byte("GPS 22.5431N 114.0579E")...)
+	size := len(payload) + 2
+	app1 := append([]byte{0xFF, 0xE1, byte(size >> 8), byte(size)}, payload...)
+	// 插在 SOI 之后
+	res := append([]byte{}, data[:2]...)
+	res = append(res, app1...)
+	return append(res, data[2:]...)
+}
+
+func TestMediaService_UploadOrientation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	store, err := objstore.NewLocalStore(t.TempDir(), "http://localhost/objects", []byte("secret"))
+	require.NoError(t, err)
+	repo := mock_repository.NewMockMediaRepository(ctrl)
+	repo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(int64(10), nil)
+	svc := NewMediaService(repo, store)
+
+	// 左红右蓝, Orientation 6 表示需要顺时针旋转 90 度
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 20; y++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 20 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}))
+	data := buf.Bytes()
+	tiff := []byte{'M', 'M', 0, 42, 0, 0, 0, 8, 0, 1, 0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, 6, 0, 0, 0, 0, 0, 0, 0, 0}
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	size := len(payload) + 2
+	app1 := append([]byte{0xFF, 0xE1, byte(size >> 8), byte(size)}, payload...)
+	res := append([]byte{}, data[:2]...)
+	res = append(res, app1...)
+	res = append(res, data[2:]...)
+	assert.Equal(t, 6, jpegOrientation(res))
+
+	m, err := svc.Upload(ctx, 1, res)
+	require.NoError(t, err)
+	assert.Equal(t, 20, m.Width)
+	assert.Equal(t, 40, m.Height)
+	origin, err := store.Get(ctx, m.Key)
+	require.NoError(t, err)
+	got, err := jpeg.Decode(bytes.NewReader(origin))
+	require.NoError(t, err)
+	r, _, b, _ := got.At(10, 5).RGBA()
+	assert.True(t, r > b, "上半部分应该是红色")
+	r, _, b, _ = got.At(10, 35).RGBA()
+	assert.True(t, b > r, "下半部分应该是蓝色")
+}
+
+func TestMediaService_URL(t *testing.T) {
+	store, err := objstore.NewLocalStore(t.TempDir(), "http://localhost/objects", []byte("secret"))
+	require.NoError(t, err)
+	svc := NewMediaService(nil, store)
+	for _, key := range []string{"", "media", "user/1.jpg", "media/../secret", "media/1/../../secret", "media//1.jpg", "media/./1.jpg"} {
+		_, err = svc.URL(context.Background(), key)
+		assert.Equal(t, ErrInvalidMedia, err, key)
+	}
+	_, err = svc.URL(context.Background(), "media/1/a.jpg")
+	assert.NoError(t, err)
+}
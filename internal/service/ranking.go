@@ -0,0 +1,143 @@
+package service
+
+import (
+	"container/heap"
+	"context"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/internal/repository"
+	"math"
+	"time"
+)
+
+// ScoreFunc 根据点赞数, 阅读数和发表时间计算热度
+type ScoreFunc func(likeCnt, readCnt int64, utime time.Time) float64
+
+// DefaultScoreFunc 参考 Hacker News 的时间衰减公式, 阅读数权重是点赞数的十分之一
+func DefaultScoreFunc(likeCnt, readCnt int64, utime time.Time) float64 {
+	hours := time.Since(utime).Hours()
+	return (float64(likeCnt) + float64(readCnt)*0.1) / math.Pow(hours+2, 1.5)
+}
+
+type RankingService struct {
+	artRepo   repository.ArticleRepository
+	interRepo repository.InteractiveRepository
+	repo      repository.RankingRepository
+	biz       string
+	// 每次从数据库取多少篇文章
+	batchSize int
+	// 热榜保留多少篇
+	n int
+	// 只计算最近一段时间内发表的文章
+	window    time.Duration
+	scoreFunc ScoreFunc
+}
+
+func NewRankingService(artRepo repository.ArticleRepository,
+	interRepo repository.InteractiveRepository, repo repository.RankingRepository) *RankingService {
+	return &RankingService{
+		artRepo:   artRepo,
+		interRepo: interRepo,
+		repo:      repo,
+		biz:       "article",
+		batchSize: 100,
+		n:         100,
+		window:    time.Hour * 24 * 7,
+		scoreFunc: DefaultScoreFunc,
+	}
+}
+
+func (s *RankingService) SetScoreFunc(fn ScoreFunc) *RankingService {
+	s.scoreFunc = fn
+	return s
+}
+
+func (s *RankingService) SetTopN(n int) *RankingService {
+	s.n = n
+	return s
+}
+
+// GetTopN 查询热榜, 文章只有摘要
+func (s *RankingService) GetTopN(ctx context.Context) ([]domain.Article, error) {
+	return s.repo.GetTopN(ctx)
+}
+
+// RankTopN 重新计算热榜并保存
+func (s *RankingService) RankTopN(ctx context.Context) error {
+	arts, err := s.rankTopN(ctx)
+	if err != nil {
+		return err
+	}
+	return s.repo.ReplaceTopN(ctx, arts)
+}
+
+func (s *RankingService) rankTopN(ctx context.Context) ([]domain.Article, error) {
+	now := time.Now()
+	ddl := now.Add(-s.window)
+	h := make(rankingHeap, 0, s.n)
+	for offset := 0; ; offset += s.batchSize {
+		arts, err := s.artRepo.ListByTime(ctx, now, s.batchSize, offset)
+		if err != nil {
+			return nil, err
+		}
+		if len(arts) == 0 {
+			break
+		}
+		ids := make([]int64, len(arts))
+		for i, art := range arts {
+			ids[i] = art.Id
+		}
+		inters, err := s.interRepo.GetByIds(ctx, s.biz, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, art := range arts {
+			if art.Utime.Before(ddl) {
+				continue
+			}
+			inter := inters[art.Id]
+			score := s.scoreFunc(inter.LikeCnt, inter.ReadCnt, art.Utime)
+			art.Content = art.Abstract()
+			if h.Len() < s.n {
+				heap.Push(&h, rankingItem{art: art, score: score})
+				continue
+			}
+			if score > h[0].score {
+				h[0] = rankingItem{art: art, score: score}
+				heap.Fix(&h, 0)
+			}
+		}
+		// 按更新时间倒序取的, 最后一篇已经超出时间窗口就不用继续了
+		if len(arts) < s.batchSize || arts[len(arts)-1].Utime.Before(ddl) {
+			break
+		}
+	}
+	res := make([]domain.Article, h.Len())
+	for i := len(res) - 1; i >= 0; i-- {
+		res[i] = heap.Pop(&h).(rankingItem).art
+	}
+	return res, nil
+}
+
+type rankingItem struct {
+	art   domain.Article
+	score float64
+}
+
+// rankingHeap 小顶堆, 堆顶是当前热榜里分数最低的文章
+type rankingHeap []rankingItem
+
+func (h rankingHeap) Len() int           { return len(h) }
+func (h rankingHeap) Less(i, j int) bool { return h[i].score < h[j].score }
+func (h rankingHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *rankingHeap) Push(x any) {
+	*h = append(*h, x.(rankingItem))
+}
+
+func (h *rankingHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/internal/repository"
+	"github.com/lutcoding/redbook/internal/repository/cache"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRankingService_RankTopN(t *testing.T) {
+	now := time.Now()
+	// 按更新时间倒序, 和数据库的查询结果一致
+	arts := &fakeRankingArticleRepo{arts: []domain.Article{
+		{Id: 1, Tittle: "a1", Content: "c1", Utime: now.Add(-time.Hour)},
+		{Id: 2, Tittle: "a2", Content: "c2", Utime: now.Add(-time.Hour * 2)},
+		{Id: 3, Tittle: "a3", Content: "c3", Utime: now.Add(-time.Hour * 3)},
+		// 超出时间窗口, 点赞再多也不上榜, 之后的文章也不用再查
+		{Id: 4, Tittle: "a4", Content: "c4", Utime: now.Add(-time.Hour * 24 * 8)},
+		{Id: 5, Tittle: "a5", Content: "c5", Utime: now.Add(-time.Hour * 24 * 9)},
+	}}
+	inters := &fakeRankingInteractiveRepo{inters: map[int64]domain.Interactive{
+		1: {LikeCnt: 10},
+		2: {LikeCnt: 100},
+		3: {LikeCnt: 1},
+		4: {LikeCnt: 1000},
+		5: {LikeCnt: 1000},
+	}}
+	repo := &fakeRankingRepo{}
+	svc := NewRankingService(arts, inters, repo).SetTopN(2).
+		SetScoreFunc(func(likeCnt, readCnt int64, utime time.Time) float64 {
+			return float64(likeCnt)
+		})
+	svc.batchSize = 2
+
+	require.NoError(t, svc.RankTopN(context.Background()))
+	require.Len(t, repo.arts, 2)
+	assert.Equal(t, int64(2), repo.arts[0].Id)
+	assert.Equal(t, int64(1), repo.arts[1].Id)
+	// 热榜只保存摘要, 不修改查询出来的文章
+	assert.Equal(t, repo.arts[0].Abstract(), repo.arts[0].Content)
+	assert.Equal(t, "c2", arts.arts[1].Content)
+	assert.Equal(t, []int{0, 2}, arts.offsets)
+}
+
+func TestRankingService_GetTopNBeforeRanking(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	repo := repository.NewRankingCacheRepository(cache.NewRankingRedisCache(client), cache.NewRankingLocalCache())
+	svc := NewRankingService(nil, nil, repo)
+
+	arts, err := svc.GetTopN(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, arts)
+}
+
+type fakeRankingArticleRepo struct {
+	repository.ArticleRepository
+	arts    []domain.Article
+	offsets []int
+}
+
+func (r *fakeRankingArticleRepo) ListByTime(ctx context.Context, start time.Time, limit, offset int) ([]domain.Article, error) {
+	r.offsets = append(r.offsets, offset)
+	if offset >= len(r.arts) {
+		return nil, nil
+	}
+	end := offset + limit
+	if end > len(r.arts) {
+		end = len(r.arts)
+	}
+	res := make([]domain.Article, end-offset)
+	copy(res, r.arts[offset:end])
+	return res, nil
+}
+
+type fakeRankingInteractiveRepo struct {
+	repository.InteractiveRepository
+	inters map[int64]domain.Interactive
+}
+
+func (r *fakeRankingInteractiveRepo) GetByIds(ctx context.Context, biz string, bizIds []int64) (map[int64]domain.Interactive, error) {
+	res := make(map[int64]domain.Interactive, len(bizIds))
+	for _, id := range bizIds {
+		if inter, ok := r.inters[id]; ok {
+			res[id] = inter
+		}
+	}
+	return res, nil
+}
+
+type fakeRankingRepo struct {
+	repository.RankingRepository
+	arts []domain.Article
+}
+
+func (r *fakeRankingRepo) ReplaceTopN(ctx context.Context, arts []domain.Article) error {
+	r.arts = arts
+	return nil
+}
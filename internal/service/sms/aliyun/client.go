@@ -0,0 +1,148 @@
+package aliyun
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultEndpoint = "https://dysmsapi.aliyuncs.com"
+	apiVersion      = "2017-05-25"
+)
+
+// Client 阿里云短信的 RPC 接口, 只实现了 SendSms. 签名算法见阿里云文档的 "RPC 机制的签名"
+type Client struct {
+	endpoint        string
+	accessKeyId     string
+	accessKeySecret string
+	regionId        string
+	httpClient      *http.Client
+}
+
+func NewClient(accessKeyId, accessKeySecret string) *Client {
+	return &Client{
+		endpoint:        defaultEndpoint,
+		accessKeyId:     accessKeyId,
+		accessKeySecret: accessKeySecret,
+		regionId:        "cn-hangzhou",
+		httpClient:      &http.Client{Timeout: time.Second * 10},
+	}
+}
+
+// SetEndpoint 带上协议, 比如 https://dysmsapi.aliyuncs.com
+func (c *Client) SetEndpoint(endpoint string) *Client {
+	c.endpoint = strings.TrimSuffix(endpoint, "/")
+	return c
+}
+
+func (c *Client) SetRegionId(regionId string) *Client {
+	c.regionId = regionId
+	return c
+}
+
+func (c *Client) SetHTTPClient(client *http.Client) *Client {
+	c.httpClient = client
+	return c
+}
+
+type sendSmsRequest struct {
+	PhoneNumbers  string
+	SignName      string
+	TemplateCode  string
+	TemplateParam string
+}
+
+// response 成功和失败都是这个格式, 鉴权失败之类的错误 http 状态码不是 200
+type response struct {
+	RequestId string `json:"RequestId"`
+	Code      string `json:"Code"`
+	Message   string `json:"Message"`
+	BizId     string `json:"BizId"`
+}
+
+func (c *Client) sendSms(ctx context.Context, req sendSmsRequest) (response, error) {
+	params := url.Values{}
+	params.Set("Action", "SendSms")
+	params.Set("PhoneNumbers", req.PhoneNumbers)
+	params.Set("SignName", req.SignName)
+	params.Set("TemplateCode", req.TemplateCode)
+	if req.TemplateParam != "" {
+		params.Set("TemplateParam", req.TemplateParam)
+	}
+	return c.call(ctx, params)
+}
+
+func (c *Client) call(ctx context.Context, params url.Values) (response, error) {
+	params.Set("AccessKeyId", c.accessKeyId)
+	params.Set("Format", "JSON")
+	params.Set("RegionId", c.regionId)
+	params.Set("SignatureMethod", "HMAC-SHA1")
+	params.Set("SignatureNonce", uuid.NewString())
+	params.Set("SignatureVersion", "1.0")
+	params.Set("Timestamp", time.Now().UTC().Format("2006-01-02T15:04:05Z"))
+	params.Set("Version", apiVersion)
+	query := canonicalize(params)
+	query = "Signature=" + percentEncode(sign(http.MethodGet, query, c.accessKeySecret)) + "&" + query
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint+"/?"+query, nil)
+	if err != nil {
+		return response{}, err
+	}
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return response{}, err
+	}
+	defer httpResp.Body.Close()
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return response{}, err
+	}
+	var resp response
+	if err = json.Unmarshal(body, &resp); err != nil {
+		return response{}, fmt.Errorf("解析阿里云响应失败, http 状态码 %d: %w", httpResp.StatusCode, err)
+	}
+	if httpResp.StatusCode != http.StatusOK && resp.Code == "" {
+		return response{}, fmt.Errorf("阿里云响应错误, http 状态码 %d", httpResp.StatusCode)
+	}
+	return resp, nil
+}
+
+// canonicalize 按参数名排序之后拼接, 名字和值都要编码
+func canonicalize(params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, percentEncode(k)+"="+percentEncode(params.Get(k)))
+	}
+	return strings.Join(pairs, "&")
+}
+
+func sign(method, canonicalized, secret string) string {
+	stringToSign := method + "&" + percentEncode("/") + "&" + percentEncode(canonicalized)
+	mac := hmac.New(sha1.New, []byte(secret+"&"))
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// percentEncode 阿里云要求的编码, 和 url.QueryEscape 的区别是空格, * 和 ~
+func percentEncode(s string) string {
+	s = url.QueryEscape(s)
+	s = strings.ReplaceAll(s, "+", "%20")
+	s = strings.ReplaceAll(s, "*", "%2A")
+	return strings.ReplaceAll(s, "%7E", "~")
+}
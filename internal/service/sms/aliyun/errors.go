@@ -0,0 +1,89 @@
+package aliyun
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidNumber       = errors.New("手机号不合法")
+	ErrRateLimited         = errors.New("触发阿里云流控")
+	ErrInsufficientBalance = errors.New("阿里云账户余额不足")
+	ErrInvalidTemplate     = errors.New("短信模板不合法")
+	ErrInvalidSign         = errors.New("短信签名不合法")
+	ErrInvalidParams       = errors.New("模板参数不合法")
+	ErrUnauthorized        = errors.New("阿里云鉴权失败")
+	ErrUnknown             = errors.New("阿里云未知错误")
+)
+
+// codes 阿里云错误码到错误的映射, 没有列出来的都是 ErrUnknown
+var codes = map[string]error{
+	"isv.MOBILE_NUMBER_ILLEGAL":       ErrInvalidNumber,
+	"isv.MOBILE_COUNT_OVER_LIMIT":     ErrInvalidNumber,
+	"isv.BUSINESS_LIMIT_CONTROL":      ErrRateLimited,
+	"isv.DAY_LIMIT_CONTROL":           ErrRateLimited,
+	"Throttling.User":                 ErrRateLimited,
+	"isv.AMOUNT_NOT_ENOUGH":           ErrInsufficientBalance,
+	"isv.OUT_OF_SERVICE":              ErrInsufficientBalance,
+	"isv.SMS_TEMPLATE_ILLEGAL":        ErrInvalidTemplate,
+	"isv.SMS_SIGNATURE_ILLEGAL":       ErrInvalidSign,
+	"isv.TEMPLATE_MISSING_PARAMETERS": ErrInvalidParams,
+	"isv.INVALID_JSON_PARAM":          ErrInvalidParams,
+	"isv.PARAM_LENGTH_LIMIT":          ErrInvalidParams,
+	"InvalidAccessKeyId.NotFound":     ErrUnauthorized,
+	"SignatureDoesNotMatch":           ErrUnauthorized,
+	"isp.RAM_PERMISSION_DENY":         ErrUnauthorized,
+}
+
+// Error 某个手机号发送失败, 可以用 errors.Is 判断是哪一类错误
+type Error struct {
+	Number    string
+	Code      string
+	Message   string
+	RequestId string
+	kind      error
+}
+
+func newError(number string, resp response) *Error {
+	kind, ok := codes[resp.Code]
+	if !ok {
+		kind = ErrUnknown
+	}
+	return &Error{
+		Number:    number,
+		Code:      resp.Code,
+		Message:   resp.Message,
+		RequestId: resp.RequestId,
+		kind:      kind,
+	}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("send message to %s failed %s, %s", e.Number, e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.kind
+}
+
+func (e *Error) FailedNumber() string {
+	return e.Number
+}
+
+// sendError 请求阿里云失败, 每个手机号单独请求, 也只影响这一个手机号
+type sendError struct {
+	number string
+	err    error
+}
+
+func (e *sendError) Error() string {
+	return fmt.Sprintf("send message to %s failed: %v", e.number, e.err)
+}
+
+func (e *sendError) Unwrap() error {
+	return e.err
+}
+
+func (e *sendError) FailedNumber() string {
+	return e.number
+}
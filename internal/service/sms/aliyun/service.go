@@ -0,0 +1,104 @@
+package aliyun
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/multierr"
+	"strconv"
+	"sync"
+)
+
+// Service 阿里云短信. SendSms 一次可以发多个手机号, 但是只返回一个结果,
+// 所以这里每个手机号单独发送, 这样才知道是哪个手机号失败了
+type Service struct {
+	client      *Client
+	signName    string
+	paramNames  func(tplId string) []string
+	concurrency int
+}
+
+func NewService(client *Client, signName string) *Service {
+	return &Service{
+		client:      client,
+		signName:    signName,
+		concurrency: 10,
+	}
+}
+
+// SetParamNames 阿里云的模板参数是按名字传的, 用来把 args 按顺序对应到名字上.
+// 没有设置或者返回 nil 时参数名字是 "1", "2"...
+func (s *Service) SetParamNames(fn func(tplId string) []string) *Service {
+	s.paramNames = fn
+	return s
+}
+
+func (s *Service) SetConcurrency(concurrency int) *Service {
+	s.concurrency = concurrency
+	return s
+}
+
+func (s *Service) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	param, err := s.templateParam(tplId, args)
+	if err != nil {
+		return err
+	}
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, len(numbers))
+		sem  = make(chan struct{}, s.concurrency)
+	)
+	for i, number := range numbers {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, number string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			errs[i] = s.send(ctx, tplId, param, number)
+		}(i, number)
+	}
+	wg.Wait()
+	// 按手机号的顺序合并, 结果是稳定的
+	return multierr.Combine(errs...)
+}
+
+func (s *Service) send(ctx context.Context, tplId, param, number string) error {
+	resp, err := s.client.sendSms(ctx, sendSmsRequest{
+		PhoneNumbers:  number,
+		SignName:      s.signName,
+		TemplateCode:  tplId,
+		TemplateParam: param,
+	})
+	if err != nil {
+		return &sendError{number: number, err: err}
+	}
+	if resp.Code != "OK" {
+		return newError(number, resp)
+	}
+	return nil
+}
+
+func (s *Service) templateParam(tplId string, args []string) (string, error) {
+	if len(args) == 0 {
+		return "", nil
+	}
+	var names []string
+	if s.paramNames != nil {
+		names = s.paramNames(tplId)
+	}
+	if names != nil && len(names) != len(args) {
+		return "", fmt.Errorf("%w: 模板 %s 需要 %d 个参数, 传入了 %d 个", ErrInvalidParams, tplId, len(names), len(args))
+	}
+	param := make(map[string]string, len(args))
+	for i, arg := range args {
+		name := strconv.Itoa(i + 1)
+		if names != nil {
+			name = names[i]
+		}
+		param[name] = arg
+	}
+	res, err := json.Marshal(param)
+	return string(res), err
+}
@@ -0,0 +1,184 @@
+package aliyun
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/multierr"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testKeyId  = "test-key-id"
+	testSecret = "test-secret"
+)
+
+// fakeAliyun 本地模拟的阿里云短信接口, 校验签名之后按手机号返回 codes 里面的错误码
+type fakeAliyun struct {
+	mu     sync.Mutex
+	params []url.Values
+	codes  map[string]string
+	delay  time.Duration
+}
+
+func (f *fakeAliyun) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.delay > 0 {
+		time.Sleep(f.delay)
+	}
+	query := r.URL.Query()
+	signature := query.Get("Signature")
+	query.Del("Signature")
+	if query.Get("AccessKeyId") != testKeyId {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(response{RequestId: "req", Code: "InvalidAccessKeyId.NotFound", Message: "Specified access key is not found."})
+		return
+	}
+	if sign(r.Method, canonicalize(query), testSecret) != signature {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(response{RequestId: "req", Code: "SignatureDoesNotMatch", Message: "Specified signature is not matched."})
+		return
+	}
+	f.mu.Lock()
+	f.params = append(f.params, query)
+	f.mu.Unlock()
+	code, ok := f.codes[query.Get("PhoneNumbers")]
+	if !ok {
+		code = "OK"
+	}
+	_ = json.NewEncoder(w).Encode(response{RequestId: "req", Code: code, Message: code, BizId: "biz"})
+}
+
+func TestService_Send(t *testing.T) {
+	testCases := []struct {
+		name       string
+		keyId      string
+		secret     string
+		codes      map[string]string
+		paramNames func(tplId string) []string
+		args       []string
+		numbers    []string
+
+		wantErrs  []error
+		wantParam string
+	}{
+		{
+			name:       "发送成功",
+			keyId:      testKeyId,
+			secret:     testSecret,
+			paramNames: func(tplId string) []string { return []string{"code"} },
+			args:       []string{"123456"},
+			numbers:    []string{"13800000001", "13800000002"},
+			wantParam:  `{"code":"123456"}`,
+		},
+		{
+			name:      "没有参数名字",
+			keyId:     testKeyId,
+			secret:    testSecret,
+			args:      []string{"123456", "5"},
+			numbers:   []string{"13800000001"},
+			wantParam: `{"1":"123456","2":"5"}`,
+		},
+		{
+			name:   "部分手机号失败",
+			keyId:  testKeyId,
+			secret: testSecret,
+			codes: map[string]string{
+				"13800000002": "isv.MOBILE_NUMBER_ILLEGAL",
+				"13800000003": "isv.BUSINESS_LIMIT_CONTROL",
+				"13800000004": "isv.SOMETHING_NEW",
+			},
+			numbers:  []string{"13800000001", "13800000002", "13800000003", "13800000004"},
+			wantErrs: []error{ErrInvalidNumber, ErrRateLimited, ErrUnknown},
+		},
+		{
+			name:     "签名错误",
+			keyId:    testKeyId,
+			secret:   "wrong-secret",
+			numbers:  []string{"13800000001"},
+			wantErrs: []error{ErrUnauthorized},
+		},
+		{
+			name:     "AccessKey 不存在",
+			keyId:    "unknown",
+			secret:   testSecret,
+			numbers:  []string{"13800000001"},
+			wantErrs: []error{ErrUnauthorized},
+		},
+		{
+			name:       "参数个数不对",
+			keyId:      testKeyId,
+			secret:     testSecret,
+			paramNames: func(tplId string) []string { return []string{"code", "minutes"} },
+			args:       []string{"123456"},
+			numbers:    []string{"13800000001"},
+			wantErrs:   []error{ErrInvalidParams},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := &fakeAliyun{codes: tc.codes}
+			server := httptest.NewServer(fake)
+			defer server.Close()
+
+			svc := NewService(NewClient(tc.keyId, tc.secret).SetEndpoint(server.URL), "红书").
+				SetParamNames(tc.paramNames)
+			err := svc.Send(context.Background(), "SMS_1", tc.args, tc.numbers...)
+
+			errs := multierr.Errors(err)
+			require.Len(t, errs, len(tc.wantErrs))
+			for i, wantErr := range tc.wantErrs {
+				assert.ErrorIs(t, errs[i], wantErr)
+			}
+			if len(tc.wantErrs) > 0 {
+				return
+			}
+			require.Len(t, fake.params, len(tc.numbers))
+			for _, params := range fake.params {
+				assert.Equal(t, "SendSms", params.Get("Action"))
+				assert.Equal(t, "红书", params.Get("SignName"))
+				assert.Equal(t, "SMS_1", params.Get("TemplateCode"))
+				assert.JSONEq(t, tc.wantParam, params.Get("TemplateParam"))
+			}
+		})
+	}
+}
+
+func TestService_SendError(t *testing.T) {
+	fake := &fakeAliyun{codes: map[string]string{"13800000002": "isv.AMOUNT_NOT_ENOUGH"}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	svc := NewService(NewClient(testKeyId, testSecret).SetEndpoint(server.URL), "红书")
+	err := svc.Send(context.Background(), "SMS_1", nil, "13800000002")
+	var e *Error
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, "13800000002", e.Number)
+	assert.Equal(t, "isv.AMOUNT_NOT_ENOUGH", e.Code)
+	assert.ErrorIs(t, err, ErrInsufficientBalance)
+	assert.True(t, strings.Contains(err.Error(), "13800000002"))
+}
+
+func TestService_SendTimeout(t *testing.T) {
+	fake := &fakeAliyun{delay: time.Millisecond * 200}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	svc := NewService(NewClient(testKeyId, testSecret).SetEndpoint(server.URL), "红书")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	err := svc.Send(ctx, "SMS_1", nil, "13800000001")
+	// failover 按 context.DeadlineExceeded 统计超时
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestPercentEncode(t *testing.T) {
+	assert.Equal(t, "a%20b%2Ac~d%2F", percentEncode("a b*c~d/"))
+}
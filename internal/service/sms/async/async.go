@@ -2,89 +2,352 @@ package async
 
 import (
 	"context"
+	"errors"
 	"github.com/lutcoding/redbook/internal/domain"
 	"github.com/lutcoding/redbook/internal/repository"
 	"github.com/lutcoding/redbook/internal/service/sms"
+	smsratelimit "github.com/lutcoding/redbook/internal/service/sms/ratelimit"
+	"go.uber.org/zap"
+	"math/rand"
+	"sync"
 	"time"
 )
 
 type Service struct {
-	svc      sms.Service
+	svc sms.Service
+	// worker 后台发送异步短信用的服务, 群发的量大, 不能和验证码共用限流
+	worker   sms.Service
 	repo     repository.AsyncSmsRepository
 	retryMax int64
+
+	monitor *monitor
+	// 超过其中一个阈值就转异步
+	latencyThreshold time.Duration
+	errRateThreshold float64
+	// minRequests 窗口内至少有这么多请求才判断是否转异步
+	minRequests int
+	coolDown    time.Duration
+	probeRatio  float64
+	// minProbes 异步期间窗口内至少有这么多探测的请求才判断是否恢复同步.
+	// 探测的流量只有 probeRatio, 用 minRequests 的话流量小的时候永远凑不够
+	minProbes int
+
+	mu sync.Mutex
+	// asyncMode 当前是否转为异步
+	asyncMode  bool
+	asyncSince time.Time
+	// probing 正在发送的补足样本的探测请求数, 并发的请求不能都被当成探测同步发送
+	probing int
+
+	// 后台发送异步短信
+	workers     int
+	batchSize   int
+	sendTimeout time.Duration
+	// lease 抢占之后这么久没有结果, 其他实例可以重新抢占
+	lease        time.Duration
+	pollInterval time.Duration
+	backoffBase  time.Duration
+	backoffMax   time.Duration
 }
 
 func NewService(svc sms.Service, repo repository.AsyncSmsRepository, retryMax int64) *Service {
 	return &Service{
-		svc:      svc,
-		repo:     repo,
-		retryMax: retryMax,
+		svc:              svc,
+		worker:           svc,
+		repo:             repo,
+		retryMax:         retryMax,
+		monitor:          newMonitor(time.Second*10, 10),
+		latencyThreshold: time.Millisecond * 500,
+		errRateThreshold: 0.3,
+		minRequests:      10,
+		coolDown:         time.Minute,
+		probeRatio:       0.01,
+		minProbes:        3,
+		workers:          4,
+		batchSize:        10,
+		sendTimeout:      time.Second * 5,
+		lease:            time.Minute,
+		pollInterval:     time.Second,
+		backoffBase:      time.Second * 5,
+		backoffMax:       time.Minute * 5,
 	}
 }
 
-func (s *Service) Start() {
-	go func() {
-		for {
-			s.async()
-		}
-	}()
+// SetWorkerService 后台发送异步短信用的服务, 默认和同步发送用同一个
+func (s *Service) SetWorkerService(svc sms.Service) *Service {
+	s.worker = svc
+	return s
+}
+
+// SetWindow 统计响应时间和错误率的窗口, 分成 10 个桶
+func (s *Service) SetWindow(window time.Duration) *Service {
+	s.monitor = newMonitor(window, 10)
+	return s
+}
+
+func (s *Service) SetLatencyThreshold(threshold time.Duration) *Service {
+	s.latencyThreshold = threshold
+	return s
+}
+
+func (s *Service) SetErrRateThreshold(threshold float64) *Service {
+	s.errRateThreshold = threshold
+	return s
+}
+
+func (s *Service) SetMinRequests(cnt int) *Service {
+	s.minRequests = cnt
+	return s
+}
+
+func (s *Service) SetCoolDown(coolDown time.Duration) *Service {
+	s.coolDown = coolDown
+	return s
+}
+
+func (s *Service) SetWorkers(workers int) *Service {
+	s.workers = workers
+	return s
+}
+
+// SetBatchSize 每次抢占多少条, 要保证一批短信能在租约之内发完
+func (s *Service) SetBatchSize(size int) *Service {
+	s.batchSize = size
+	return s
+}
+
+func (s *Service) SetBackoff(base, maxDelay time.Duration) *Service {
+	s.backoffBase, s.backoffMax = base, maxDelay
+	return s
+}
+
+// SetProbeRatio 异步期间继续同步发送的流量比例
+func (s *Service) SetProbeRatio(ratio float64) *Service {
+	s.probeRatio = ratio
+	return s
+}
+
+func (s *Service) SetMinProbes(cnt int) *Service {
+	s.minProbes = cnt
+	return s
 }
 
-func (s *Service) async() {
-	ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second)
-	// 抢占一个异步发送消息
-	// 比如k8s部署了三个实例，保证只有一个实例抢占到该消息
-	waitingSms, err := s.repo.GetWaitingSms(ctx)
-	cancelFunc()
-	switch err {
-	case nil:
-		ctx, cancelFunc = context.WithTimeout(context.Background(), time.Second)
-		defer cancelFunc()
-		err := s.svc.Send(ctx, waitingSms.TplId, waitingSms.Args, waitingSms.Numbers...)
+// Run 不断抢占等待发送的短信, 交给 workers 个协程发送.
+// ctx 被取消之后等正在发送的短信处理完再返回, 已经抢占但是没有发送的等租约过期之后重新被抢占
+func (s *Service) Run(ctx context.Context) error {
+	ch := make(chan domain.AsyncSms)
+	var wg sync.WaitGroup
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for sms := range ch {
+				s.sendAsync(sms)
+			}
+		}()
+	}
+	defer func() {
+		close(ch)
+		wg.Wait()
+	}()
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		smses, err := s.claim(ctx)
 		if err != nil {
-			// 记录下日志
+			// 一般是数据库出了问题, 等一会再试, 避免短时间的抖动
+			zap.L().Error("抢占异步短信失败", zap.Error(err))
 		}
-		isSuc := err == nil
-		err = s.repo.ReportScheduleResult(ctx, waitingSms.Id, isSuc)
+		if len(smses) == 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(s.pollInterval):
+			}
+			continue
+		}
+		for _, sms := range smses {
+			select {
+			case ch <- sms:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+func (s *Service) claim(ctx context.Context) ([]domain.AsyncSms, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*3)
+	defer cancel()
+	return s.repo.ClaimWaitingSms(ctx, s.batchSize, s.lease)
+}
+
+func (s *Service) sendAsync(sms domain.AsyncSms) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.sendTimeout)
+	start := time.Now()
+	err := s.worker.Send(ctx, sms.TplId, sms.Args, sms.Numbers...)
+	cancel()
+	if errors.Is(err, smsratelimit.ErrLimited) {
+		s.postpone(sms)
+		return
+	}
+	attempt := domain.AsyncSmsAttempt{
+		SmsId:   sms.Id,
+		Attempt: sms.RetryCnt,
+		Latency: time.Since(start),
+	}
+	// 不能用发送用的 ctx, 它可能已经超时了
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	if err == nil {
+		asyncResultCounter.WithLabelValues("success").Inc()
+		err = s.repo.MarkSuccess(ctx, attempt)
 		if err != nil {
-			// 记录日志
+			zap.L().Error("保存异步短信发送结果失败", zap.Int64("id", sms.Id), zap.Error(err))
+		}
+		return
+	}
+	asyncResultCounter.WithLabelValues("failed").Inc()
+	zap.L().Warn("异步短信发送失败", zap.Int64("id", sms.Id),
+		zap.Int64("attempt", sms.RetryCnt), zap.Error(err))
+	attempt.Err = err.Error()
+	err = s.repo.MarkFailed(ctx, attempt, time.Now().Add(s.backoff(sms.RetryCnt)))
+	if err != nil {
+		zap.L().Error("保存异步短信发送结果失败", zap.Int64("id", sms.Id), zap.Error(err))
+	}
+}
+
+// postpone 被限流的短信没有真正发出去, 稍后重新抢占, 不占用重试次数
+func (s *Service) postpone(sms domain.AsyncSms) {
+	asyncResultCounter.WithLabelValues("limited").Inc()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	d := s.backoffBase/2 + time.Duration(rand.Int63n(int64(s.backoffBase/2)+1))
+	err := s.repo.Postpone(ctx, sms, time.Now().Add(d))
+	if err != nil {
+		// 租约过期之后会被重新抢占, 只是会占用一次重试次数
+		zap.L().Error("推迟被限流的异步短信失败", zap.Int64("id", sms.Id), zap.Error(err))
+	}
+}
+
+// backoff 第 n 次发送失败之后等多久重试, 指数增长到 backoffMax 为止.
+// 在 [d/2, d] 之间随机, 避免同时失败的短信同时重试
+func (s *Service) backoff(n int64) time.Duration {
+	d := s.backoffMax
+	if n < 1 {
+		n = 1
+	}
+	if n <= 32 {
+		if b := s.backoffBase << (n - 1); b > 0 && b < d {
+			d = b
 		}
-	case repository.ErrWaitingSMSNotFound:
-		time.Sleep(time.Second)
-	default:
-		// 正常来说应该是数据库那边出了问题，
-		// 但是为了尽量运行，还是要继续的
-		// 你可以稍微睡眠，也可以不睡眠
-		// 睡眠的话可以帮你规避掉短时间的网络抖动问题
-		time.Sleep(time.Second)
 	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
 }
 
 func (s *Service) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
-	if s.needAsync() {
-		return s.repo.Add(ctx, domain.AsyncSms{
+	async, probe := s.needAsync()
+	if async {
+		sendCounter.WithLabelValues("async").Inc()
+		return s.enqueue(ctx, tplId, args, numbers)
+	}
+	if probe {
+		defer s.probeDone()
+	}
+	sendCounter.WithLabelValues("sync").Inc()
+	start := time.Now()
+	err := s.svc.Send(ctx, tplId, args, numbers...)
+	s.monitor.record(time.Now(), time.Since(start), err)
+	return err
+}
+
+// enqueue 每个手机号保存成一条异步短信, 部分手机号失败时重试不会重复发给已经成功的手机号
+func (s *Service) enqueue(ctx context.Context, tplId string, args []string, numbers []string) error {
+	smses := make([]domain.AsyncSms, 0, len(numbers))
+	for _, number := range numbers {
+		smses = append(smses, domain.AsyncSms{
 			RetryMax: s.retryMax,
 			AsyncSmsConfig: domain.AsyncSmsConfig{
 				TplId:   tplId,
 				Args:    args,
-				Numbers: numbers,
+				Numbers: []string{number},
 			},
 		})
 	}
-	return s.svc.Send(ctx, tplId, args, numbers...)
+	if len(smses) == 1 {
+		return s.repo.Add(ctx, smses[0])
+	}
+	return s.repo.AddBatch(ctx, smses)
 }
 
-// 提前引导你们，开始思考系统容错问题
-// 你们面试装逼，赢得竞争优势就靠这一类的东西
-func (s *Service) needAsync() bool {
-	// 这边就是你要设计的，各种判定要不要触发异步的方案
-	// 1. 基于响应时间的，平均响应时间
-	// 1.1 使用绝对阈值，比如说直接发送的时候，（连续一段时间，或者连续N个请求）响应时间超过了 500ms，然后后续请求转异步
-	// 1.2 变化趋势，比如说当前一秒钟内的所有请求的响应时间比上一秒钟增长了 X%，就转异步
-	// 2. 基于错误率：一段时间内，收到 err 的请求比率大于 X%，转异步
+// needAsync 同步发送时, 窗口内的平均响应时间或者错误率超过阈值就转异步.
+// 异步期间保留 probeRatio 的流量继续同步发送, 冷却 coolDown 之后如果探测的流量正常就退出异步.
+// probe 为 true 表示这是补足样本的探测请求, 发送完之后要调用 probeDone
+func (s *Service) needAsync() (async bool, probe bool) {
+	now := time.Now()
+	st := s.monitor.stat(now)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.asyncMode {
+		if st.cnt >= s.minRequests && s.exceeded(st) {
+			s.switchMode(true, now, st)
+			return true, false
+		}
+		return false, false
+	}
+	if now.Sub(s.asyncSince) >= s.coolDown {
+		switch {
+		case st.cnt < s.minProbes:
+			// 流量小的时候按比例凑不够样本, 直接同步发送来探测, 样本够了再判断.
+			// 正在发送的也算, 最多补足 minProbes 个, 其他的仍然走异步
+			if st.cnt+s.probing < s.minProbes {
+				s.probing++
+				sendCounter.WithLabelValues("probe").Inc()
+				return false, true
+			}
+		case !s.exceeded(st):
+			s.switchMode(false, now, st)
+			return false, false
+		default:
+			// 探测的流量仍然不正常, 重新开始冷却
+			s.asyncSince = now
+		}
+	}
+	if rand.Float64() < s.probeRatio {
+		sendCounter.WithLabelValues("probe").Inc()
+		return false, false
+	}
+	return true, false
+}
+
+func (s *Service) probeDone() {
+	s.mu.Lock()
+	s.probing--
+	s.mu.Unlock()
+}
 
-	// 什么时候退出异步
-	// 1. 进入异步 N 分钟后
-	// 2. 保留 1% 的流量（或者更少），继续同步发送，判定响应时间/错误率
-	return true
+// exceeded 平均响应时间或者错误率超过阈值. 调用方要先确认样本足够, 避免一两个请求就触发切换
+func (s *Service) exceeded(st stat) bool {
+	return st.avgLatency() > s.latencyThreshold || st.errRate() > s.errRateThreshold
+}
+
+func (s *Service) switchMode(async bool, now time.Time, st stat) {
+	s.asyncMode, s.asyncSince = async, now
+	// 切换之后重新统计, 异步期间窗口里只有探测的流量
+	s.monitor.reset()
+	fields := []zap.Field{
+		zap.Int("cnt", st.cnt),
+		zap.Duration("avg_latency", st.avgLatency()),
+		zap.Float64("err_rate", st.errRate()),
+	}
+	if async {
+		modeGauge.Set(1)
+		switchCounter.WithLabelValues("async").Inc()
+		zap.L().Warn("短信发送转为异步", fields...)
+		return
+	}
+	modeGauge.Set(0)
+	switchCounter.WithLabelValues("sync").Inc()
+	zap.L().Info("短信发送恢复同步", fields...)
 }
@@ -0,0 +1,362 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/lutcoding/redbook/internal/domain"
+	smsratelimit "github.com/lutcoding/redbook/internal/service/sms/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeSms struct {
+	err error
+	cnt int
+}
+
+func (f *fakeSms) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	f.cnt++
+	return f.err
+}
+
+// numberSms 号码为 fail 时发送失败, 可以并发调用
+type numberSms struct{}
+
+func (numberSms) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	if numbers[0] == "fail" {
+		return errors.New("服务商出错")
+	}
+	return nil
+}
+
+// blockingSms 一直阻塞到 release 被关闭
+type blockingSms struct {
+	cnt     atomic.Int32
+	release chan struct{}
+}
+
+func (b *blockingSms) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	b.cnt.Add(1)
+	<-b.release
+	return nil
+}
+
+type fakeRepo struct {
+	mu        sync.Mutex
+	added     []domain.AsyncSms
+	waiting   []domain.AsyncSms
+	success   []domain.AsyncSmsAttempt
+	failed    []domain.AsyncSmsAttempt
+	postponed []domain.AsyncSms
+}
+
+func (f *fakeRepo) Add(ctx context.Context, sms domain.AsyncSms) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.added = append(f.added, sms)
+	return nil
+}
+
+func (f *fakeRepo) AddBatch(ctx context.Context, smses []domain.AsyncSms) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.added = append(f.added, smses...)
+	return nil
+}
+
+func (f *fakeRepo) ClaimWaitingSms(ctx context.Context, limit int, lease time.Duration) ([]domain.AsyncSms, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if limit > len(f.waiting) {
+		limit = len(f.waiting)
+	}
+	res := f.waiting[:limit]
+	f.waiting = f.waiting[limit:]
+	return res, nil
+}
+
+func (f *fakeRepo) MarkSuccess(ctx context.Context, attempt domain.AsyncSmsAttempt) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.success = append(f.success, attempt)
+	return nil
+}
+
+func (f *fakeRepo) MarkFailed(ctx context.Context, attempt domain.AsyncSmsAttempt, nextRetryAt time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failed = append(f.failed, attempt)
+	return nil
+}
+
+func (f *fakeRepo) Postpone(ctx context.Context, sms domain.AsyncSms, nextRetryAt time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.postponed = append(f.postponed, sms)
+	return nil
+}
+
+func (f *fakeRepo) FindById(ctx context.Context, id int64) (domain.AsyncSms, error) {
+	return domain.AsyncSms{}, errors.New("not implemented")
+}
+
+func (f *fakeRepo) List(ctx context.Context, query domain.AsyncSmsQuery, limit, offset int) ([]domain.AsyncSms, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeRepo) Count(ctx context.Context, query domain.AsyncSmsQuery) (int64, error) {
+	return 0, errors.New("not implemented")
+}
+
+func (f *fakeRepo) ListAttempts(ctx context.Context, smsId int64) ([]domain.AsyncSmsAttempt, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeRepo) Resend(ctx context.Context, id int64) (bool, error) {
+	return false, errors.New("not implemented")
+}
+
+func (f *fakeRepo) results() (int, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.success), len(f.failed)
+}
+
+func TestService_SwitchByErrRate(t *testing.T) {
+	provider := &fakeSms{err: errors.New("服务商出错")}
+	repo := &fakeRepo{}
+	svc := NewService(provider, repo, 3).
+		SetMinRequests(5).
+		SetErrRateThreshold(0.5).
+		SetCoolDown(time.Millisecond * 50).
+		SetProbeRatio(0)
+	ctx := context.Background()
+
+	// 前 5 个请求同步发送, 错误率 100% 之后转异步
+	for i := 0; i < 8; i++ {
+		_ = svc.Send(ctx, "tpl", nil, "152")
+	}
+	assert.Equal(t, 5, provider.cnt)
+	assert.Len(t, repo.added, 3)
+	assert.True(t, svc.asyncMode)
+	// 多个手机号分开保存
+	_ = svc.Send(ctx, "tpl", nil, "152", "153")
+	assert.Len(t, repo.added, 5)
+	assert.Equal(t, []string{"153"}, repo.added[4].Numbers)
+
+	// 冷却之后没有探测流量, 先同步发送 3 个探测, 都正常才恢复同步
+	provider.err = nil
+	time.Sleep(time.Millisecond * 60)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, svc.Send(ctx, "tpl", nil, "152"))
+		assert.True(t, svc.asyncMode)
+	}
+	assert.NoError(t, svc.Send(ctx, "tpl", nil, "152"))
+	assert.False(t, svc.asyncMode)
+	assert.Equal(t, 9, provider.cnt)
+	assert.Len(t, repo.added, 5)
+}
+
+// 流量小的时候探测的样本少于 minRequests, 探测仍然失败就不能恢复同步
+func TestService_LowTrafficKeepsAsync(t *testing.T) {
+	provider := &fakeSms{err: errors.New("服务商出错")}
+	repo := &fakeRepo{}
+	svc := NewService(provider, repo, 3).
+		SetCoolDown(time.Millisecond * 50).
+		SetProbeRatio(0)
+	svc.asyncMode, svc.asyncSince = true, time.Now()
+	ctx := context.Background()
+
+	for round := 0; round < 2; round++ {
+		time.Sleep(time.Millisecond * 60)
+		for i := 0; i < 5; i++ {
+			_ = svc.Send(ctx, "tpl", nil, "152")
+			assert.True(t, svc.asyncMode)
+		}
+	}
+	// 第一轮冷却之后 3 个探测失败, 重新冷却. 第二轮窗口里还有这 3 个探测, 仍然失败
+	assert.Equal(t, 3, provider.cnt)
+	assert.Len(t, repo.added, 7)
+}
+
+// 冷却之后并发的请求最多只有 minProbes 个作为探测同步发送
+func TestService_ConcurrentProbes(t *testing.T) {
+	provider := &blockingSms{release: make(chan struct{})}
+	repo := &fakeRepo{}
+	svc := NewService(provider, repo, 3).
+		SetCoolDown(time.Millisecond * 10).
+		SetProbeRatio(0)
+	svc.asyncMode, svc.asyncSince = true, time.Now().Add(-time.Second)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = svc.Send(ctx, "tpl", nil, "152")
+		}()
+	}
+	require.Eventually(t, func() bool {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+		return len(repo.added) == 7
+	}, time.Second, time.Millisecond*10)
+	assert.Equal(t, int32(3), provider.cnt.Load())
+	close(provider.release)
+	wg.Wait()
+	assert.Equal(t, 0, svc.probing)
+}
+
+func TestService_SwitchByLatency(t *testing.T) {
+	svc := NewService(&fakeSms{}, &fakeRepo{}, 3).
+		SetMinRequests(2).
+		SetLatencyThreshold(time.Millisecond * 100).
+		SetCoolDown(time.Minute).
+		SetProbeRatio(0)
+	now := time.Now()
+	svc.monitor.record(now, time.Millisecond*50, nil)
+	async, _ := svc.needAsync()
+	assert.False(t, async)
+	svc.monitor.record(now, time.Millisecond*300, nil)
+	// 平均 175ms
+	async, _ = svc.needAsync()
+	assert.True(t, async)
+	async, _ = svc.needAsync()
+	assert.True(t, async)
+}
+
+func TestService_ProbeKeepsAsync(t *testing.T) {
+	provider := &fakeSms{err: errors.New("服务商出错")}
+	svc := NewService(provider, &fakeRepo{}, 3).
+		SetMinRequests(2).
+		SetErrRateThreshold(0.5).
+		SetCoolDown(time.Millisecond * 50).
+		SetProbeRatio(1)
+	svc.asyncMode, svc.asyncSince = true, time.Now()
+	ctx := context.Background()
+
+	// 异步期间全部作为探测流量同步发送, 仍然出错
+	for i := 0; i < 3; i++ {
+		_ = svc.Send(ctx, "tpl", nil, "152")
+	}
+	time.Sleep(time.Millisecond * 60)
+	svc.SetProbeRatio(0)
+	async, _ := svc.needAsync()
+	assert.True(t, async)
+	assert.True(t, svc.asyncMode)
+}
+
+func TestMonitor_Window(t *testing.T) {
+	m := newMonitor(time.Second, 10)
+	now := time.Now()
+	m.record(now, time.Millisecond*10, nil)
+	m.record(now, time.Millisecond*30, errors.New("出错"))
+	st := m.stat(now)
+	assert.Equal(t, 2, st.cnt)
+	assert.Equal(t, time.Millisecond*20, st.avgLatency())
+	assert.Equal(t, 0.5, st.errRate())
+
+	// 窗口之外的桶不统计, 写入同一个桶时重置
+	later := now.Add(time.Second)
+	assert.Equal(t, 0, m.stat(later).cnt)
+	m.record(later, time.Millisecond*10, nil)
+	assert.Equal(t, 1, m.stat(later).cnt)
+}
+
+func TestService_Run(t *testing.T) {
+	repo := &fakeRepo{}
+	for i := 1; i <= 25; i++ {
+		number := "152"
+		if i%5 == 0 {
+			number = "fail"
+		}
+		repo.waiting = append(repo.waiting, domain.AsyncSms{
+			Id:             int64(i),
+			RetryCnt:       1,
+			RetryMax:       3,
+			AsyncSmsConfig: domain.AsyncSmsConfig{TplId: "tpl", Numbers: []string{number}},
+		})
+	}
+	svc := NewService(numberSms{}, repo, 3).SetWorkers(3).SetBatchSize(4)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- svc.Run(ctx)
+	}()
+	require.Eventually(t, func() bool {
+		success, failed := repo.results()
+		return success+failed == 25
+	}, time.Second*3, time.Millisecond*10)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	success, failed := repo.results()
+	assert.Equal(t, 20, success)
+	assert.Equal(t, 5, failed)
+	for _, attempt := range repo.failed {
+		assert.Equal(t, int64(1), attempt.Attempt)
+		assert.Equal(t, "服务商出错", attempt.Err)
+	}
+}
+
+func TestService_RunLimited(t *testing.T) {
+	repo := &fakeRepo{}
+	for i := 1; i <= 5; i++ {
+		repo.waiting = append(repo.waiting, domain.AsyncSms{
+			Id:             int64(i),
+			RetryCnt:       1,
+			RetryMax:       3,
+			AsyncSmsConfig: domain.AsyncSmsConfig{TplId: "tpl", Numbers: []string{"152"}},
+		})
+	}
+	syncSvc := &fakeSms{}
+	// 后台发送被限流, 不算失败也不能走同步发送的服务
+	svc := NewService(syncSvc, repo, 3).
+		SetWorkerService(&fakeSms{err: fmt.Errorf("限流: %w", smsratelimit.ErrLimited)}).
+		SetWorkers(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- svc.Run(ctx)
+	}()
+	require.Eventually(t, func() bool {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+		return len(repo.postponed) == 5
+	}, time.Second*3, time.Millisecond*10)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	success, failed := repo.results()
+	assert.Equal(t, 0, success)
+	assert.Equal(t, 0, failed)
+	assert.Equal(t, 0, syncSvc.cnt)
+}
+
+func TestService_Backoff(t *testing.T) {
+	svc := NewService(&fakeSms{}, &fakeRepo{}, 3).SetBackoff(time.Second, time.Second*10)
+	testCases := []struct {
+		n   int64
+		max time.Duration
+	}{
+		{n: 0, max: time.Second},
+		{n: 1, max: time.Second},
+		{n: 2, max: time.Second * 2},
+		{n: 4, max: time.Second * 8},
+		{n: 5, max: time.Second * 10},
+		{n: 100, max: time.Second * 10},
+	}
+	for _, tc := range testCases {
+		for i := 0; i < 100; i++ {
+			d := svc.backoff(tc.n)
+			assert.GreaterOrEqual(t, d, tc.max/2)
+			assert.LessOrEqual(t, d, tc.max)
+		}
+	}
+}
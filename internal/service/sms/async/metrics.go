@@ -0,0 +1,35 @@
+package async
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	// modeGauge 1 为异步发送, 0 为同步发送
+	modeGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "redbook",
+		Subsystem: "sms",
+		Name:      "async_mode",
+		Help:      "短信当前是否转为异步发送",
+	})
+	switchCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "redbook",
+		Subsystem: "sms",
+		Name:      "async_switch_total",
+		Help:      "同步异步切换的次数, to 为 async, sync",
+	}, []string{"to"})
+	sendCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "redbook",
+		Subsystem: "sms",
+		Name:      "send_total",
+		Help:      "发送的短信数, mode 为 sync, async, probe(异步期间同步发送的探测流量)",
+	}, []string{"mode"})
+	asyncResultCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "redbook",
+		Subsystem: "sms",
+		Name:      "async_send_total",
+		Help:      "后台发送异步短信的结果, result 为 success, failed, limited",
+	}, []string{"result"})
+)
+
+func init() {
+	prometheus.MustRegister(modeGauge, switchCounter, sendCounter, asyncResultCounter)
+}
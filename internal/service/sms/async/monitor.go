@@ -0,0 +1,91 @@
+package async
+
+import (
+	"sync"
+	"time"
+)
+
+// monitor 用滑动窗口统计最近一段时间同步发送的响应时间和错误率.
+// 窗口分成多个桶, 每个桶统计一小段时间, 过期的桶在写入时重置
+type monitor struct {
+	mu      sync.Mutex
+	width   time.Duration
+	buckets []bucket
+}
+
+type bucket struct {
+	// start 桶对应的时间段的开始, 和当前时间相差超过窗口说明已经过期
+	start   time.Time
+	cnt     int
+	errCnt  int
+	latency time.Duration
+}
+
+type stat struct {
+	cnt     int
+	errCnt  int
+	latency time.Duration
+}
+
+func (s stat) avgLatency() time.Duration {
+	if s.cnt == 0 {
+		return 0
+	}
+	return s.latency / time.Duration(s.cnt)
+}
+
+func (s stat) errRate() float64 {
+	if s.cnt == 0 {
+		return 0
+	}
+	return float64(s.errCnt) / float64(s.cnt)
+}
+
+// newMonitor window 窗口大小, size 桶的数量
+func newMonitor(window time.Duration, size int) *monitor {
+	return &monitor{
+		width:   window / time.Duration(size),
+		buckets: make([]bucket, size),
+	}
+}
+
+func (m *monitor) record(now time.Time, latency time.Duration, err error) {
+	start := now.Truncate(m.width)
+	idx := int(start.UnixNano()/int64(m.width)) % len(m.buckets)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b := &m.buckets[idx]
+	if !b.start.Equal(start) {
+		*b = bucket{start: start}
+	}
+	b.cnt++
+	b.latency += latency
+	if err != nil {
+		b.errCnt++
+	}
+}
+
+// stat 统计窗口内所有没有过期的桶
+func (m *monitor) stat(now time.Time) stat {
+	window := m.width * time.Duration(len(m.buckets))
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var res stat
+	for _, b := range m.buckets {
+		if now.Sub(b.start) >= window {
+			continue
+		}
+		res.cnt += b.cnt
+		res.errCnt += b.errCnt
+		res.latency += b.latency
+	}
+	return res
+}
+
+func (m *monitor) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.buckets {
+		m.buckets[i] = bucket{}
+	}
+}
@@ -2,41 +2,102 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/lutcoding/redbook/internal/repository"
 	"github.com/lutcoding/redbook/internal/service/sms"
+	"github.com/lutcoding/redbook/internal/service/sms/template"
+	"go.uber.org/zap"
 )
 
-var errNotValid = fmt.Errorf("非合法申请短信服务的用户")
+var (
+	ErrInvalidToken    = errors.New("非合法申请短信服务的用户")
+	ErrTokenRevoked    = errors.New("短信凭证已经被吊销")
+	ErrTplNotAllowed   = errors.New("短信凭证不能使用这个模板")
+	ErrRateLimited     = repository.ErrSmsRateLimited
+	ErrQuotaExhausted  = repository.ErrSmsQuotaExhausted
+	ErrNumbersRequired = errors.New("手机号不能为空")
+)
+
+type tokenKey struct{}
 
+// WithToken 业务方的凭证放在 ctx 里传给 Service
+func WithToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenKey{}, token)
+}
+
+// Service 校验业务方的凭证, 只能使用凭证绑定的模板, 并且扣减凭证的额度.
+// 发送失败时退回额度
 type Service struct {
-	sms sms.Service
-	key string
+	sms  sms.Service
+	repo repository.SmsTokenRepository
+	key  []byte
 }
 
-func NewService(sms sms.Service, key string) *Service {
+func NewService(sms sms.Service, repo repository.SmsTokenRepository, key []byte) *Service {
 	return &Service{
-		sms: sms,
-		key: key,
+		sms:  sms,
+		repo: repo,
+		key:  key,
 	}
 }
 
 func (s *Service) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
-	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tplId, claims, func(token *jwt.Token) (interface{}, error) {
-		return s.key, nil
-	})
+	if len(numbers) == 0 {
+		return ErrNumbersRequired
+	}
+	tokenStr, _ := ctx.Value(tokenKey{}).(string)
+	claims, err := parseToken(tokenStr, s.key)
+	if err != nil {
+		return err
+	}
+	t, err := s.repo.FindById(ctx, claims.TokenId)
+	if errors.Is(err, repository.ErrSmsTokenNotFound) {
+		return ErrInvalidToken
+	}
 	if err != nil {
 		return err
 	}
-	if !token.Valid || claims.Tpl == "" {
-		return errNotValid
+	switch {
+	case t.Revoked:
+		return ErrTokenRevoked
+	// 凭证绑定的是模板名字, 可以使用模板的所有语言
+	case !t.AllowTpl(template.Name(tplId)):
+		return ErrTplNotAllowed
 	}
+	cnt := int64(len(numbers))
+	err = s.repo.Consume(ctx, t, cnt)
+	if err != nil {
+		return err
+	}
+	err = s.sms.Send(ctx, tplId, args, numbers...)
+	if err != nil {
+		if er := s.repo.Refund(ctx, t.Id, cnt); er != nil {
+			zap.L().Error("退回短信额度失败", zap.Int64("token", t.Id),
+				zap.Int64("cnt", cnt), zap.Error(er))
+		}
+	}
+	return err
+}
 
-	return s.sms.Send(ctx, claims.Tpl, args, numbers...)
+func parseToken(tokenStr string, key []byte) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+		return key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, err)
+	}
+	if !token.Valid || claims.TokenId == 0 {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
 }
 
 type Claims struct {
 	jwt.RegisteredClaims
-	Tpl string
+	// TokenId 对应 domain.SmsToken, 模板和额度以数据库里的为准, 吊销之后立刻失效
+	TokenId int64
+	Biz     string
 }
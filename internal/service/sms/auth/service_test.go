@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/internal/repository"
+	mock_repository "github.com/lutcoding/redbook/internal/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+)
+
+type fakeSms struct {
+	err error
+	cnt int
+}
+
+func (f *fakeSms) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	f.cnt++
+	return f.err
+}
+
+func TestService_Send(t *testing.T) {
+	key := []byte("sms-key")
+	token := domain.SmsToken{Id: 1, Biz: "order", Tpls: []string{"tpl1", "tpl2"}, MonthlyQuota: 100}
+	issue := func(t *testing.T, repo *mock_repository.MockSmsTokenRepository, token domain.SmsToken) string {
+		repo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(token.Id, nil)
+		_, str, err := NewTokenService(repo, key).Issue(context.Background(), token)
+		require.NoError(t, err)
+		return str
+	}
+	testCases := []struct {
+		name    string
+		token   func(t *testing.T, repo *mock_repository.MockSmsTokenRepository) string
+		mock    func(repo *mock_repository.MockSmsTokenRepository)
+		tplId   string
+		smsErr  error
+		wantErr error
+		wantCnt int
+	}{
+		{
+			name: "发送成功",
+			token: func(t *testing.T, repo *mock_repository.MockSmsTokenRepository) string {
+				return issue(t, repo, token)
+			},
+			mock: func(repo *mock_repository.MockSmsTokenRepository) {
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).Return(token, nil)
+				repo.EXPECT().Consume(gomock.Any(), token, int64(2)).Return(nil)
+			},
+			tplId:   "tpl2",
+			wantCnt: 1,
+		},
+		{
+			name: "发送失败退回额度",
+			token: func(t *testing.T, repo *mock_repository.MockSmsTokenRepository) string {
+				return issue(t, repo, token)
+			},
+			mock: func(repo *mock_repository.MockSmsTokenRepository) {
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).Return(token, nil)
+				repo.EXPECT().Consume(gomock.Any(), token, int64(2)).Return(nil)
+				repo.EXPECT().Refund(gomock.Any(), int64(1), int64(2)).Return(nil)
+			},
+			tplId:   "tpl1",
+			smsErr:  errors.New("服务商出错"),
+			wantErr: errors.New("服务商出错"),
+			wantCnt: 1,
+		},
+		{
+			name: "签名不对",
+			token: func(t *testing.T, repo *mock_repository.MockSmsTokenRepository) string {
+				str, err := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{TokenId: 1}).
+					SignedString([]byte("other-key"))
+				require.NoError(t, err)
+				return str
+			},
+			mock:    func(repo *mock_repository.MockSmsTokenRepository) {},
+			tplId:   "tpl1",
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "过期",
+			token: func(t *testing.T, repo *mock_repository.MockSmsTokenRepository) string {
+				str, err := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+					RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute))},
+					TokenId:          1,
+				}).SignedString(key)
+				require.NoError(t, err)
+				return str
+			},
+			mock:    func(repo *mock_repository.MockSmsTokenRepository) {},
+			tplId:   "tpl1",
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "已经吊销",
+			token: func(t *testing.T, repo *mock_repository.MockSmsTokenRepository) string {
+				return issue(t, repo, token)
+			},
+			mock: func(repo *mock_repository.MockSmsTokenRepository) {
+				revoked := token
+				revoked.Revoked = true
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).Return(revoked, nil)
+			},
+			tplId:   "tpl1",
+			wantErr: ErrTokenRevoked,
+		},
+		{
+			name: "模板不允许",
+			token: func(t *testing.T, repo *mock_repository.MockSmsTokenRepository) string {
+				return issue(t, repo, token)
+			},
+			mock: func(repo *mock_repository.MockSmsTokenRepository) {
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).Return(token, nil)
+			},
+			tplId:   "tpl3",
+			wantErr: ErrTplNotAllowed,
+		},
+		{
+			name: "额度用完",
+			token: func(t *testing.T, repo *mock_repository.MockSmsTokenRepository) string {
+				return issue(t, repo, token)
+			},
+			mock: func(repo *mock_repository.MockSmsTokenRepository) {
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).Return(token, nil)
+				repo.EXPECT().Consume(gomock.Any(), token, int64(2)).Return(repository.ErrSmsQuotaExhausted)
+			},
+			tplId:   "tpl1",
+			wantErr: ErrQuotaExhausted,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mock_repository.NewMockSmsTokenRepository(ctrl)
+			ctx := WithToken(context.Background(), tc.token(t, repo))
+			tc.mock(repo)
+			provider := &fakeSms{err: tc.smsErr}
+			err := NewService(provider, repo, key).Send(ctx, tc.tplId, nil, "152", "153")
+			if tc.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.wantErr.Error())
+			}
+			assert.Equal(t, tc.wantCnt, provider.cnt)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/internal/repository"
+	"time"
+)
+
+var (
+	ErrTokenNotFound   = repository.ErrSmsTokenNotFound
+	ErrInvalidTokenReq = errors.New("业务方和模板不能为空, 额度和频率不能为负数, 过期时间要晚于现在")
+)
+
+const maxTokenPageSize = 100
+
+// TokenService 管理后台签发和吊销业务方的短信凭证
+type TokenService struct {
+	repo repository.SmsTokenRepository
+	key  []byte
+}
+
+func NewTokenService(repo repository.SmsTokenRepository, key []byte) *TokenService {
+	return &TokenService{
+		repo: repo,
+		key:  key,
+	}
+}
+
+// Issue 返回凭证的 id 和 jwt, jwt 只在签发的时候返回一次
+func (s *TokenService) Issue(ctx context.Context, t domain.SmsToken) (int64, string, error) {
+	if t.Biz == "" || len(t.Tpls) == 0 || t.MonthlyQuota < 0 || t.RatePerMinute < 0 ||
+		(!t.ExpireAt.IsZero() && !t.ExpireAt.After(time.Now())) {
+		return 0, "", ErrInvalidTokenReq
+	}
+	t.Revoked = false
+	id, err := s.repo.Create(ctx, t)
+	if err != nil {
+		return 0, "", err
+	}
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt: jwt.NewNumericDate(time.Now()),
+		},
+		TokenId: id,
+		Biz:     t.Biz,
+	}
+	if !t.ExpireAt.IsZero() {
+		claims.ExpiresAt = jwt.NewNumericDate(t.ExpireAt)
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.key)
+	return id, token, err
+}
+
+func (s *TokenService) List(ctx context.Context, limit, offset int) ([]domain.SmsToken, error) {
+	if limit <= 0 || limit > maxTokenPageSize {
+		limit = maxTokenPageSize
+	}
+	return s.repo.List(ctx, limit, offset)
+}
+
+func (s *TokenService) Revoke(ctx context.Context, id int64) error {
+	return s.repo.Revoke(ctx, id)
+}
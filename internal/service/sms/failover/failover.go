@@ -0,0 +1,50 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/lutcoding/redbook/internal/service/sms"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"sync/atomic"
+)
+
+// Service 轮询服务商, 每次请求从下一个服务商开始, 失败时换下一个, 直到所有服务商都试过.
+// 服务商返回部分手机号失败时, 下一个服务商只发送失败的手机号
+type Service struct {
+	providers []Provider
+	idx       atomic.Uint64
+}
+
+func NewService(providers []Provider) *Service {
+	return &Service{
+		providers: providers,
+	}
+}
+
+func (s *Service) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	start := s.idx.Add(1)
+	length := uint64(len(s.providers))
+	var errs error
+	for i := uint64(0); i < length; i++ {
+		p := s.providers[(start+i)%length]
+		err := p.Send(ctx, tplId, args, numbers...)
+		observe(p.Name, err)
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, context.Canceled) || ctx.Err() != nil {
+			// 调用方已经放弃了, 换服务商也没用
+			return err
+		}
+		errs = multierr.Append(errs, fmt.Errorf("%s: %w", p.Name, err))
+		// 其他手机号已经发出去了, 不能重复发送
+		if failed, ok := sms.FailedNumbers(err); ok {
+			numbers = failed
+		}
+		zap.L().Warn("短信服务商发送失败, 切换下一个", zap.String("provider", p.Name),
+			zap.Strings("numbers", numbers), zap.Error(err))
+	}
+	return fmt.Errorf("%w: %w", ErrAllFailed, errs)
+}
@@ -0,0 +1,162 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/multierr"
+	"testing"
+)
+
+// fakeService 依次返回 errs 里的错误, 用完之后一直成功
+type fakeService struct {
+	errs    []error
+	cnt     int
+	numbers [][]string
+}
+
+func (f *fakeService) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	f.cnt++
+	f.numbers = append(f.numbers, numbers)
+	if len(f.errs) == 0 {
+		return nil
+	}
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+	return err
+}
+
+func TestService_Send(t *testing.T) {
+	errSend := errors.New("发送失败")
+	testCases := []struct {
+		name      string
+		providers []*fakeService
+		wantErr   error
+		wantCnts  []int
+	}{
+		{
+			name:      "第一个服务商成功",
+			providers: []*fakeService{{}, {}},
+			// idx 从 1 开始
+			wantCnts: []int{0, 1},
+		},
+		{
+			name:      "切换到下一个服务商",
+			providers: []*fakeService{{}, {errs: []error{errSend}}},
+			wantCnts:  []int{1, 1},
+		},
+		{
+			name: "全部失败",
+			providers: []*fakeService{
+				{errs: []error{errSend}},
+				{errs: []error{context.DeadlineExceeded}},
+			},
+			wantErr:  ErrAllFailed,
+			wantCnts: []int{1, 1},
+		},
+		{
+			name: "调用方取消之后不再切换",
+			providers: []*fakeService{
+				{},
+				{errs: []error{context.Canceled}},
+			},
+			wantErr:  context.Canceled,
+			wantCnts: []int{0, 1},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := NewService(providers(tc.providers))
+			err := svc.Send(context.Background(), "tpl", []string{"123456"}, "152")
+			assert.ErrorIs(t, err, tc.wantErr)
+			for i, p := range tc.providers {
+				assert.Equal(t, tc.wantCnts[i], p.cnt)
+			}
+		})
+	}
+}
+
+// numberErr 某个手机号发送失败
+type numberErr string
+
+func (e numberErr) Error() string {
+	return "send message to " + string(e) + " failed"
+}
+
+func (e numberErr) FailedNumber() string {
+	return string(e)
+}
+
+func TestService_PartialFailure(t *testing.T) {
+	errSend := errors.New("发送失败")
+	fakes := []*fakeService{
+		{errs: []error{errSend}},
+		{errs: []error{multierr.Combine(numberErr("b"), numberErr("c"))}},
+		{errs: []error{numberErr("c")}},
+	}
+	svc := NewService(providers(fakes))
+	err := svc.Send(context.Background(), "tpl", nil, "a", "b", "c")
+	// idx 从 1 开始: 第二个服务商只有 a 成功, 第三个服务商只发 b, c, 第一个服务商只发 c
+	assert.Equal(t, [][]string{{"a", "b", "c"}}, fakes[1].numbers)
+	assert.Equal(t, [][]string{{"b", "c"}}, fakes[2].numbers)
+	assert.Equal(t, [][]string{{"c"}}, fakes[0].numbers)
+	assert.ErrorIs(t, err, ErrAllFailed)
+	assert.ErrorIs(t, err, errSend)
+	assert.ErrorAs(t, err, new(numberErr))
+}
+
+func TestService_RoundRobin(t *testing.T) {
+	fakes := []*fakeService{{}, {}, {}}
+	svc := NewService(providers(fakes))
+	for i := 0; i < 6; i++ {
+		assert.NoError(t, svc.Send(context.Background(), "tpl", nil, "152"))
+	}
+	for _, f := range fakes {
+		assert.Equal(t, 2, f.cnt)
+	}
+}
+
+func TestTimeoutFailoverService_Send(t *testing.T) {
+	timeout := context.DeadlineExceeded
+	fakes := []*fakeService{
+		{errs: []error{timeout, errors.New("参数错误"), timeout, timeout, timeout}},
+		{},
+	}
+	svc := NewTimeoutFailoverService(providers(fakes), 2)
+	ctx := context.Background()
+
+	// 超时一次, 其他错误不计数也不清零
+	assert.Equal(t, timeout, svc.Send(ctx, "tpl", nil, "152"))
+	assert.Error(t, svc.Send(ctx, "tpl", nil, "152"))
+	assert.Equal(t, int32(1), svc.cnt)
+	// 连续超时两次
+	assert.Equal(t, timeout, svc.Send(ctx, "tpl", nil, "152"))
+	assert.Equal(t, int32(2), svc.cnt)
+	// 切换到第二个服务商
+	assert.NoError(t, svc.Send(ctx, "tpl", nil, "152"))
+	assert.Equal(t, int32(1), svc.idx)
+	assert.Equal(t, int32(0), svc.cnt)
+	assert.Equal(t, 3, fakes[0].cnt)
+	assert.Equal(t, 1, fakes[1].cnt)
+}
+
+func TestTimeoutFailoverService_ResetOnSuccess(t *testing.T) {
+	timeout := context.DeadlineExceeded
+	fakes := []*fakeService{{errs: []error{timeout, nil, timeout}}, {}}
+	svc := NewTimeoutFailoverService(providers(fakes), 2)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		_ = svc.Send(ctx, "tpl", nil, "152")
+	}
+	// 中间成功过, 没有连续超时两次
+	assert.Equal(t, int32(0), svc.idx)
+	assert.Equal(t, int32(1), svc.cnt)
+}
+
+func providers(fakes []*fakeService) []Provider {
+	res := make([]Provider, len(fakes))
+	for i, f := range fakes {
+		res[i] = Provider{Name: "fake", Service: f}
+	}
+	return res
+}
@@ -0,0 +1,44 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	requestCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "redbook",
+		Subsystem: "sms",
+		Name:      "provider_requests_total",
+		Help:      "每个短信服务商的发送次数, result 为 success, timeout, failed",
+	}, []string{"provider", "result"})
+	// healthGauge 最近一次发送是否成功, 1 为成功
+	healthGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "redbook",
+		Subsystem: "sms",
+		Name:      "provider_up",
+		Help:      "短信服务商最近一次发送是否成功",
+	}, []string{"provider"})
+)
+
+func init() {
+	prometheus.MustRegister(requestCounter, healthGauge)
+}
+
+func observe(provider string, err error) {
+	result := "success"
+	switch {
+	case err == nil:
+	case errors.Is(err, context.DeadlineExceeded):
+		result = "timeout"
+	default:
+		result = "failed"
+	}
+	requestCounter.WithLabelValues(provider, result).Inc()
+	if err == nil {
+		healthGauge.WithLabelValues(provider).Set(1)
+	} else {
+		healthGauge.WithLabelValues(provider).Set(0)
+	}
+}
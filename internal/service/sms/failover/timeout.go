@@ -0,0 +1,52 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"sync/atomic"
+)
+
+// TimeoutFailoverService 连续超时 threshold 次之后切换到下一个服务商.
+// 只有超时才计数, 其他错误可能是参数问题, 不代表服务商不可用
+type TimeoutFailoverService struct {
+	providers []Provider
+	// idx 当前使用的服务商
+	idx int32
+	// cnt 当前服务商连续超时的次数
+	cnt       int32
+	threshold int32
+}
+
+func NewTimeoutFailoverService(providers []Provider, threshold int32) *TimeoutFailoverService {
+	return &TimeoutFailoverService{
+		providers: providers,
+		threshold: threshold,
+	}
+}
+
+func (s *TimeoutFailoverService) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	idx := atomic.LoadInt32(&s.idx)
+	cnt := atomic.LoadInt32(&s.cnt)
+	if cnt >= s.threshold {
+		newIdx := (idx + 1) % int32(len(s.providers))
+		// 并发时只有一个请求能切换成功, 其他请求直接用切换后的服务商
+		if atomic.CompareAndSwapInt32(&s.idx, idx, newIdx) {
+			atomic.StoreInt32(&s.cnt, 0)
+			zap.L().Warn("短信服务商连续超时, 切换下一个",
+				zap.String("from", s.providers[idx].Name),
+				zap.String("to", s.providers[newIdx].Name))
+		}
+		idx = atomic.LoadInt32(&s.idx)
+	}
+	p := s.providers[idx]
+	err := p.Send(ctx, tplId, args, numbers...)
+	observe(p.Name, err)
+	switch {
+	case err == nil:
+		atomic.StoreInt32(&s.cnt, 0)
+	case errors.Is(err, context.DeadlineExceeded):
+		atomic.AddInt32(&s.cnt, 1)
+	}
+	return err
+}
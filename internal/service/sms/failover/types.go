@@ -0,0 +1,14 @@
+package failover
+
+import (
+	"errors"
+	"github.com/lutcoding/redbook/internal/service/sms"
+)
+
+var ErrAllFailed = errors.New("所有短信服务商都发送失败")
+
+// Provider 短信服务商, Name 用于监控
+type Provider struct {
+	Name string
+	sms.Service
+}
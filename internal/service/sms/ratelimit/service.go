@@ -7,28 +7,36 @@ import (
 	"github.com/lutcoding/redbook/pkg/ratelimit"
 )
 
-var errLimited = fmt.Errorf("trigger sms rate limit")
+var ErrLimited = fmt.Errorf("trigger sms rate limit")
 
 // Service 装饰器模式
 type Service struct {
 	svc     sms.Service
 	limiter ratelimit.Limiter
+	key     string
 }
 
 func NewService(svc sms.Service, limiter ratelimit.Limiter) *Service {
 	return &Service{
 		svc:     svc,
 		limiter: limiter,
+		key:     "sms_ratelimit",
 	}
 }
 
+// SetKey 不同的 key 分别计算限流, 互不占用额度
+func (s *Service) SetKey(key string) *Service {
+	s.key = key
+	return s
+}
+
 func (s *Service) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
-	limit, err := s.limiter.Limit(ctx, "sms_ratelimit")
+	limit, err := s.limiter.Limit(ctx, s.key)
 	if err != nil {
 		return fmt.Errorf("短信服务判断是否限流出现问题, %w", err)
 	}
 	if limit {
-		return errLimited
+		return ErrLimited
 	}
 	return s.svc.Send(ctx, tplId, args, numbers...)
 }
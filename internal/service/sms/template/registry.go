@@ -0,0 +1,209 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Registry 按名字和语言查找短信模板. 创建之后只读, 可以并发使用
+type Registry struct {
+	templates     map[string]Template
+	defaultLocale string
+}
+
+// NewRegistry 检查配置, 每个模板都要有默认语言
+func NewRegistry(templates []Template, defaultLocale string) (*Registry, error) {
+	r := &Registry{
+		templates:     make(map[string]Template, len(templates)),
+		defaultLocale: strings.ToLower(defaultLocale),
+	}
+	for _, tpl := range templates {
+		if tpl.Name == "" || strings.Contains(tpl.Name, "@") {
+			return nil, fmt.Errorf("短信模板名字不能为空, 也不能包含 @: %q", tpl.Name)
+		}
+		if _, ok := r.templates[tpl.Name]; ok {
+			return nil, fmt.Errorf("短信模板 %s 重复", tpl.Name)
+		}
+		params := make(map[string]struct{}, len(tpl.Params))
+		for _, p := range tpl.Params {
+			params[p.Name] = struct{}{}
+		}
+		// viper 读出来的 key 都是小写, 统一转成小写
+		locales := make(map[string]Locale, len(tpl.Locales))
+		for name, locale := range tpl.Locales {
+			for provider, pt := range locale.Providers {
+				if pt.Id == "" {
+					return nil, fmt.Errorf("短信模板 %s 的 %s 没有配置 %s 的模板 id", tpl.Name, name, provider)
+				}
+				for _, p := range pt.Params {
+					if _, ok := params[p]; !ok {
+						return nil, fmt.Errorf("短信模板 %s 的 %s 没有参数 %s", tpl.Name, provider, p)
+					}
+				}
+			}
+			locales[strings.ToLower(name)] = locale
+		}
+		if _, ok := locales[r.defaultLocale]; !ok {
+			return nil, fmt.Errorf("短信模板 %s 没有默认语言 %s", tpl.Name, defaultLocale)
+		}
+		tpl.Locales = locales
+		r.templates[tpl.Name] = tpl
+	}
+	return r, nil
+}
+
+// Templates 所有模板, 顺序不固定
+func (r *Registry) Templates() []Template {
+	res := make([]Template, 0, len(r.templates))
+	for _, tpl := range r.templates {
+		res = append(res, tpl)
+	}
+	return res
+}
+
+// Validate 检查 tplId 是否存在, 参数个数和格式是否正确
+func (r *Registry) Validate(tplId string, args []string) error {
+	name, locale := parseId(tplId)
+	tpl, _, err := r.find(name, locale)
+	if err != nil {
+		return err
+	}
+	return tpl.validate(args)
+}
+
+// Args 把命名参数转换成 sms.Service 需要的参数顺序
+func (r *Registry) Args(name string, params map[string]string) ([]string, error) {
+	tpl, ok := r.templates[name]
+	if !ok {
+		return nil, ErrTemplateNotFound
+	}
+	args := make([]string, 0, len(tpl.Params))
+	for _, p := range tpl.Params {
+		val, ok := params[p.Name]
+		if !ok {
+			return nil, fmt.Errorf("%w: 缺少参数 %s", ErrInvalidArgs, p.Name)
+		}
+		args = append(args, val)
+	}
+	if len(params) != len(args) {
+		return nil, fmt.Errorf("%w: 有多余的参数", ErrInvalidArgs)
+	}
+	return args, tpl.validate(args)
+}
+
+// Preview 用参数替换模板内容里的 {name}
+func (r *Registry) Preview(name, locale string, params map[string]string) (string, error) {
+	args, err := r.Args(name, params)
+	if err != nil {
+		return "", err
+	}
+	tpl, l, err := r.find(name, locale)
+	if err != nil {
+		return "", err
+	}
+	pairs := make([]string, 0, len(args)*2)
+	for i, p := range tpl.Params {
+		pairs = append(pairs, "{"+p.Name+"}", args[i])
+	}
+	return strings.NewReplacer(pairs...).Replace(l.Content), nil
+}
+
+// Resolve 找到服务商的模板 id, 并且按照服务商模板的顺序排列参数
+func (r *Registry) Resolve(provider, tplId string, args []string) (string, []string, error) {
+	name, locale := parseId(tplId)
+	tpl, l, err := r.find(name, locale)
+	if err != nil {
+		return "", nil, err
+	}
+	if err = tpl.validate(args); err != nil {
+		return "", nil, err
+	}
+	pt, ok := l.Providers[provider]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: %s %s", ErrProviderNotFound, provider, tplId)
+	}
+	if len(pt.Params) == 0 {
+		return pt.Id, args, nil
+	}
+	index := make(map[string]int, len(tpl.Params))
+	for i, p := range tpl.Params {
+		index[p.Name] = i
+	}
+	res := make([]string, 0, len(pt.Params))
+	for _, p := range pt.Params {
+		res = append(res, args[index[p]])
+	}
+	return pt.Id, res, nil
+}
+
+// ParamNames 服务商模板的参数名字, 给按名字传参的服务商使用
+func (r *Registry) ParamNames(provider, providerTplId string) []string {
+	for _, tpl := range r.templates {
+		for _, l := range tpl.Locales {
+			pt, ok := l.Providers[provider]
+			if !ok || pt.Id != providerTplId {
+				continue
+			}
+			if len(pt.Params) > 0 {
+				return pt.Params
+			}
+			names := make([]string, 0, len(tpl.Params))
+			for _, p := range tpl.Params {
+				names = append(names, p.Name)
+			}
+			return names
+		}
+	}
+	return nil
+}
+
+// find 没有 locale 时依次找语言本身, 比如 en-US 找 en, 最后使用默认语言
+func (r *Registry) find(name, locale string) (Template, Locale, error) {
+	tpl, ok := r.templates[name]
+	if !ok {
+		return Template{}, Locale{}, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	locale = strings.ToLower(locale)
+	candidates := []string{locale}
+	if lang, _, ok := strings.Cut(locale, "-"); ok {
+		candidates = append(candidates, lang)
+	}
+	for _, c := range candidates {
+		if l, ok := tpl.Locales[c]; ok && c != "" {
+			return tpl, l, nil
+		}
+	}
+	return tpl, tpl.Locales[r.defaultLocale], nil
+}
+
+func (tpl Template) validate(args []string) error {
+	if len(args) != len(tpl.Params) {
+		return fmt.Errorf("%w: %s 需要 %d 个参数, 传了 %d 个", ErrInvalidArgs, tpl.Name, len(tpl.Params), len(args))
+	}
+	for i, p := range tpl.Params {
+		if err := p.validate(args[i]); err != nil {
+			return fmt.Errorf("%w: %s 的参数 %s %s", ErrInvalidArgs, tpl.Name, p.Name, err.Error())
+		}
+	}
+	return nil
+}
+
+func (p Param) validate(val string) error {
+	n := utf8.RuneCountInString(val)
+	if p.MinLen > 0 && n < p.MinLen {
+		return fmt.Errorf("不能少于 %d 个字符", p.MinLen)
+	}
+	if p.MaxLen > 0 && n > p.MaxLen {
+		return fmt.Errorf("不能超过 %d 个字符", p.MaxLen)
+	}
+	if p.Digits {
+		for _, c := range val {
+			if c < '0' || c > '9' {
+				return errors.New("只能是数字")
+			}
+		}
+	}
+	return nil
+}
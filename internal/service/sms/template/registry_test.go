@@ -0,0 +1,128 @@
+package template
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func newTestRegistry(t *testing.T) *Registry {
+	r, err := NewRegistry([]Template{
+		{
+			Name: "login_code",
+			Params: []Param{
+				{Name: "code", MinLen: 6, MaxLen: 6, Digits: true},
+				{Name: "minutes", MaxLen: 2, Digits: true},
+			},
+			Locales: map[string]Locale{
+				"zh-CN": {
+					Content: "您的验证码是{code}, {minutes}分钟内有效",
+					Providers: map[string]ProviderTemplate{
+						"tencent": {Id: "1"},
+						"aliyun":  {Id: "SMS_001", Params: []string{"minutes", "code"}},
+					},
+				},
+				"en": {
+					Content: "Your code is {code}, valid for {minutes} minutes",
+					Providers: map[string]ProviderTemplate{
+						"tencent": {Id: "2"},
+					},
+				},
+			},
+		},
+	}, "zh-cn")
+	require.NoError(t, err)
+	return r
+}
+
+func TestRegistry_Resolve(t *testing.T) {
+	r := newTestRegistry(t)
+	testCases := []struct {
+		name     string
+		provider string
+		tplId    string
+		args     []string
+		wantId   string
+		wantArgs []string
+		wantErr  error
+	}{
+		{name: "默认语言", provider: "tencent", tplId: "login_code", args: []string{"123456", "5"},
+			wantId: "1", wantArgs: []string{"123456", "5"}},
+		{name: "调整参数顺序", provider: "aliyun", tplId: "login_code", args: []string{"123456", "5"},
+			wantId: "SMS_001", wantArgs: []string{"5", "123456"}},
+		{name: "按语言查找", provider: "tencent", tplId: "login_code@en-US", args: []string{"123456", "5"},
+			wantId: "2", wantArgs: []string{"123456", "5"}},
+		{name: "没有这个语言", provider: "tencent", tplId: "login_code@ja", args: []string{"123456", "5"},
+			wantId: "1", wantArgs: []string{"123456", "5"}},
+		{name: "服务商没有配置这个语言", provider: "aliyun", tplId: "login_code@en", args: []string{"123456", "5"},
+			wantErr: ErrProviderNotFound},
+		{name: "模板不存在", provider: "tencent", tplId: "notify", wantErr: ErrTemplateNotFound},
+		{name: "参数个数不对", provider: "tencent", tplId: "login_code", args: []string{"123456"},
+			wantErr: ErrInvalidArgs},
+		{name: "不是数字", provider: "tencent", tplId: "login_code", args: []string{"12345a", "5"},
+			wantErr: ErrInvalidArgs},
+		{name: "太长", provider: "tencent", tplId: "login_code", args: []string{"1234567", "5"},
+			wantErr: ErrInvalidArgs},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			id, args, err := r.Resolve(tc.provider, tc.tplId, tc.args)
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.wantId, id)
+			assert.Equal(t, tc.wantArgs, args)
+		})
+	}
+}
+
+func TestRegistry_Preview(t *testing.T) {
+	r := newTestRegistry(t)
+	params := map[string]string{"code": "123456", "minutes": "5"}
+	content, err := r.Preview("login_code", "", params)
+	require.NoError(t, err)
+	assert.Equal(t, "您的验证码是123456, 5分钟内有效", content)
+	content, err = r.Preview("login_code", "en-GB", params)
+	require.NoError(t, err)
+	assert.Equal(t, "Your code is 123456, valid for 5 minutes", content)
+
+	_, err = r.Preview("login_code", "", map[string]string{"code": "123456"})
+	assert.ErrorIs(t, err, ErrInvalidArgs)
+	_, err = r.Preview("login_code", "", map[string]string{"code": "123456", "minutes": "5", "name": "x"})
+	assert.ErrorIs(t, err, ErrInvalidArgs)
+}
+
+func TestNewRegistry(t *testing.T) {
+	_, err := NewRegistry([]Template{{Name: "a", Locales: map[string]Locale{"en": {}}}}, "zh-CN")
+	assert.Error(t, err)
+	_, err = NewRegistry([]Template{{
+		Name: "a",
+		Locales: map[string]Locale{"zh-CN": {Providers: map[string]ProviderTemplate{
+			"tencent": {Id: "1", Params: []string{"code"}},
+		}}},
+	}}, "zh-CN")
+	assert.Error(t, err)
+}
+
+type fakeSms struct {
+	tplId string
+	args  []string
+}
+
+func (f *fakeSms) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	f.tplId, f.args = tplId, args
+	return nil
+}
+
+func TestProviderService_Send(t *testing.T) {
+	r := newTestRegistry(t)
+	provider := &fakeSms{}
+	svc := NewService(NewProviderService(provider, r, "aliyun"), r)
+	require.NoError(t, svc.Send(context.Background(), Id("login_code", "zh-CN"), []string{"654321", "10"}, "152"))
+	assert.Equal(t, "SMS_001", provider.tplId)
+	assert.Equal(t, []string{"10", "654321"}, provider.args)
+	assert.Equal(t, []string{"minutes", "code"}, r.ParamNames("aliyun", "SMS_001"))
+	assert.Equal(t, []string{"code", "minutes"}, r.ParamNames("tencent", "2"))
+
+	err := svc.Send(context.Background(), "login_code", []string{"abc", "10"}, "152")
+	assert.ErrorIs(t, err, ErrInvalidArgs)
+}
@@ -0,0 +1,51 @@
+package template
+
+import (
+	"context"
+	"github.com/lutcoding/redbook/internal/service/sms"
+)
+
+// Service 放在最外层检查模板和参数, 参数不对的短信不会进入异步发送和重试
+type Service struct {
+	svc      sms.Service
+	registry *Registry
+}
+
+func NewService(svc sms.Service, registry *Registry) *Service {
+	return &Service{
+		svc:      svc,
+		registry: registry,
+	}
+}
+
+func (s *Service) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	err := s.registry.Validate(tplId, args)
+	if err != nil {
+		return err
+	}
+	return s.svc.Send(ctx, tplId, args, numbers...)
+}
+
+// ProviderService 放在每个服务商外面, 把模板名字转换成这个服务商的模板 id 和参数顺序.
+// 换服务商的时候只需要修改配置
+type ProviderService struct {
+	svc      sms.Service
+	registry *Registry
+	provider string
+}
+
+func NewProviderService(svc sms.Service, registry *Registry, provider string) *ProviderService {
+	return &ProviderService{
+		svc:      svc,
+		registry: registry,
+		provider: provider,
+	}
+}
+
+func (s *ProviderService) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	id, providerArgs, err := s.registry.Resolve(s.provider, tplId, args)
+	if err != nil {
+		return err
+	}
+	return s.svc.Send(ctx, id, providerArgs, numbers...)
+}
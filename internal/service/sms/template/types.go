@@ -0,0 +1,65 @@
+package template
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrTemplateNotFound = errors.New("短信模板不存在")
+	ErrLocaleNotFound   = errors.New("短信模板没有这个语言")
+	// ErrProviderNotFound 服务商没有配置这个模板
+	ErrProviderNotFound = errors.New("服务商没有对应的短信模板")
+	ErrInvalidArgs      = errors.New("短信模板参数错误")
+)
+
+// Template 逻辑上的短信模板, 业务代码只使用 Name, 每个服务商的模板 id 在 Locales 里配置
+type Template struct {
+	Name string
+	// Params 参数按这个顺序传给 sms.Service
+	Params []Param
+	// Locales key 是语言, 比如 zh-CN, 不区分大小写
+	Locales map[string]Locale
+}
+
+type Param struct {
+	Name string
+	// 按字符计算长度, 0 表示不限制
+	MinLen int
+	MaxLen int
+	// Digits 只能是数字, 比如验证码
+	Digits bool
+}
+
+type Locale struct {
+	// Content 预览用的内容, 参数写成 {name}
+	Content string
+	// Providers key 是服务商的名字, 比如 tencent
+	Providers map[string]ProviderTemplate
+}
+
+type ProviderTemplate struct {
+	Id string
+	// Params 服务商模板的参数顺序, 为空时和 Template.Params 一致
+	Params []string
+}
+
+// Id 带上语言的模板 id, locale 为空时使用默认语言.
+// 异步发送的短信只保存模板 id, 所以语言也要放在模板 id 里
+func Id(name, locale string) string {
+	if locale == "" {
+		return name
+	}
+	return name + "@" + locale
+}
+
+// Name 去掉模板 id 里的语言
+func Name(tplId string) string {
+	name, _ := parseId(tplId)
+	return name
+}
+
+func parseId(tplId string) (string, string) {
+	name, locale, _ := strings.Cut(tplId, "@")
+	return name, locale
+}
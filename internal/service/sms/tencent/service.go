@@ -6,6 +6,7 @@ import (
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
 	sms "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/sms/v20210111"
 	"go.uber.org/multierr"
+	"strings"
 )
 
 type Service struct {
@@ -33,8 +34,44 @@ func (s *Service) Send(ctx context.Context, tplId string, args []string, numbers
 	}
 	for _, status := range response.Response.SendStatusSet {
 		if status.Code == nil || *(status.Code) != "OK" {
-			err = multierr.Append(err, fmt.Errorf("send message failed %s, %s ", *status.Code, *status.Message))
+			err = multierr.Append(err, &Error{
+				Number:  matchNumber(numbers, stringValue(status.PhoneNumber)),
+				Code:    stringValue(status.Code),
+				Message: stringValue(status.Message),
+			})
 		}
 	}
 	return err
 }
+
+// matchNumber 腾讯云返回的手机号带 +86 之类的前缀, 找回调用方传入的手机号
+func matchNumber(numbers []string, phone string) string {
+	for _, number := range numbers {
+		if number == phone || strings.HasSuffix(phone, strings.TrimLeft(number, "+")) {
+			return number
+		}
+	}
+	return phone
+}
+
+// Error 某个手机号发送失败
+type Error struct {
+	Number  string
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("send message to %s failed %s, %s", e.Number, e.Code, e.Message)
+}
+
+func (e *Error) FailedNumber() string {
+	return e.Number
+}
+
+func stringValue(p *string) string {
+	if p == nil {
+		return ""
+	}
+	return *p
+}
@@ -1,7 +1,30 @@
 package sms
 
-import "context"
+import (
+	"context"
+	"errors"
+	"go.uber.org/multierr"
+)
 
 type Service interface {
 	Send(ctx context.Context, tplId string, args []string, numbers ...string) error
 }
+
+// NumberError 某个手机号发送失败. 部分手机号失败时服务商用 multierr 合并每个手机号的错误
+type NumberError interface {
+	error
+	FailedNumber() string
+}
+
+// FailedNumbers 返回 err 里发送失败的手机号.
+// ok 为 false 表示有的错误不属于某个手机号, 不知道哪些手机号已经发送成功
+func FailedNumbers(err error) (numbers []string, ok bool) {
+	for _, e := range multierr.Errors(err) {
+		var ne NumberError
+		if !errors.As(e, &ne) {
+			return nil, false
+		}
+		numbers = append(numbers, ne.FailedNumber())
+	}
+	return numbers, len(numbers) > 0
+}
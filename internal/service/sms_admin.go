@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/internal/repository"
+	"github.com/lutcoding/redbook/internal/service/sms"
+	"github.com/lutcoding/redbook/internal/service/sms/template"
+	"strings"
+	"time"
+)
+
+var (
+	ErrSmsNotFound       = repository.ErrAsyncSmsNotFound
+	ErrSmsNotResendable  = errors.New("只有发送成功或者失败的短信可以重新发送")
+	ErrSmsNoNumbers      = errors.New("手机号不能为空")
+	ErrSmsTooManyNumbers = errors.New("手机号太多")
+	ErrSmsScheduleTime   = errors.New("定时发送的时间必须晚于现在")
+)
+
+const (
+	// smsBatchRows 群发时一次插入多少条异步短信
+	smsBatchRows   = 200
+	maxBulkNumbers = 10000
+	maxSmsPageSize = 100
+)
+
+// SmsAdminService 短信管理后台. 群发和定时发送都先保存成异步短信, 由后台任务发送
+type SmsAdminService struct {
+	repo     repository.AsyncSmsRepository
+	smsSvc   sms.Service
+	registry *template.Registry
+	retryMax int64
+}
+
+func NewSmsAdminService(repo repository.AsyncSmsRepository, smsSvc sms.Service) *SmsAdminService {
+	return &SmsAdminService{
+		repo:     repo,
+		smsSvc:   smsSvc,
+		retryMax: 3,
+	}
+}
+
+func (s *SmsAdminService) SetRetryMax(retryMax int64) *SmsAdminService {
+	s.retryMax = retryMax
+	return s
+}
+
+// SetRegistry 群发和定时发送保存之前检查模板和参数, 不然错误的短信要到后台发送时才会失败, 还会一直重试
+func (s *SmsAdminService) SetRegistry(registry *template.Registry) *SmsAdminService {
+	s.registry = registry
+	return s
+}
+
+// List limit 不在 (0, maxSmsPageSize] 之间时按 maxSmsPageSize 查询
+func (s *SmsAdminService) List(ctx context.Context, query domain.AsyncSmsQuery, limit, offset int) ([]domain.AsyncSms, error) {
+	if limit <= 0 || limit > maxSmsPageSize {
+		limit = maxSmsPageSize
+	}
+	return s.repo.List(ctx, query, limit, offset)
+}
+
+func (s *SmsAdminService) Count(ctx context.Context, query domain.AsyncSmsQuery) (int64, error) {
+	return s.repo.Count(ctx, query)
+}
+
+// Detail 短信和每一次发送的结果, 用来排查失败原因
+func (s *SmsAdminService) Detail(ctx context.Context, id int64) (domain.AsyncSms, []domain.AsyncSmsAttempt, error) {
+	res, err := s.repo.FindById(ctx, id)
+	if err != nil {
+		return domain.AsyncSms{}, nil, err
+	}
+	attempts, err := s.repo.ListAttempts(ctx, id)
+	return res, attempts, err
+}
+
+// Resend 补发, 发送成功的也可以补发, 比如用户没有收到
+func (s *SmsAdminService) Resend(ctx context.Context, id int64) error {
+	ok, err := s.repo.Resend(ctx, id)
+	if err != nil || ok {
+		return err
+	}
+	_, err = s.repo.FindById(ctx, id)
+	if err != nil {
+		return err
+	}
+	return ErrSmsNotResendable
+}
+
+// Send 单发, 直接同步发送
+func (s *SmsAdminService) Send(ctx context.Context, tplId string, args []string, number string) error {
+	number = strings.TrimSpace(number)
+	if number == "" {
+		return ErrSmsNoNumbers
+	}
+	return s.smsSvc.Send(ctx, tplId, args, number)
+}
+
+// BulkSend 群发, 返回保存了多少条异步短信
+func (s *SmsAdminService) BulkSend(ctx context.Context, conf domain.AsyncSmsConfig) (int, error) {
+	return s.enqueue(ctx, conf, time.Time{})
+}
+
+// Schedule 在 sendAt 之后发送
+func (s *SmsAdminService) Schedule(ctx context.Context, conf domain.AsyncSmsConfig, sendAt time.Time) (int, error) {
+	if !sendAt.After(time.Now()) {
+		return 0, ErrSmsScheduleTime
+	}
+	return s.enqueue(ctx, conf, sendAt)
+}
+
+// enqueue 手机号去重之后每个手机号保存成一条异步短信, 每 smsBatchRows 条插入一次.
+// 一条短信包含多个手机号的话, 部分手机号失败时整条重试, 已经成功的手机号会重复收到
+func (s *SmsAdminService) enqueue(ctx context.Context, conf domain.AsyncSmsConfig, sendAt time.Time) (int, error) {
+	numbers := s.normalize(conf.Numbers)
+	if len(numbers) == 0 {
+		return 0, ErrSmsNoNumbers
+	}
+	if len(numbers) > maxBulkNumbers {
+		return 0, ErrSmsTooManyNumbers
+	}
+	if s.registry != nil {
+		if err := s.registry.Validate(conf.TplId, conf.Args); err != nil {
+			return 0, err
+		}
+	}
+	cnt := 0
+	for start := 0; start < len(numbers); start += smsBatchRows {
+		end := start + smsBatchRows
+		if end > len(numbers) {
+			end = len(numbers)
+		}
+		smses := make([]domain.AsyncSms, 0, end-start)
+		for _, number := range numbers[start:end] {
+			smses = append(smses, domain.AsyncSms{
+				RetryMax:    s.retryMax,
+				NextRetryAt: sendAt,
+				AsyncSmsConfig: domain.AsyncSmsConfig{
+					TplId:   conf.TplId,
+					Args:    conf.Args,
+					Numbers: []string{number},
+				},
+			})
+		}
+		if err := s.repo.AddBatch(ctx, smses); err != nil {
+			// 已经保存的会照常发送, 返回数量让管理员知道发出去了多少
+			return cnt, err
+		}
+		cnt += len(smses)
+	}
+	return cnt, nil
+}
+
+func (s *SmsAdminService) normalize(numbers []string) []string {
+	seen := make(map[string]struct{}, len(numbers))
+	res := make([]string, 0, len(numbers))
+	for _, number := range numbers {
+		number = strings.TrimSpace(number)
+		if number == "" {
+			continue
+		}
+		if _, ok := seen[number]; ok {
+			continue
+		}
+		seen[number] = struct{}{}
+		res = append(res, number)
+	}
+	return res
+}
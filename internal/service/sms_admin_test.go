@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/internal/repository"
+	mock_repository "github.com/lutcoding/redbook/internal/repository/mocks"
+	"github.com/lutcoding/redbook/internal/service/sms/memory"
+	"github.com/lutcoding/redbook/internal/service/sms/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+)
+
+func TestSmsAdminService_BulkSend(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := mock_repository.NewMockAsyncSmsRepository(ctrl)
+	svc := NewSmsAdminService(repo, memory.NewService()).SetRetryMax(5)
+
+	// 450 个手机号, 重复和空的去掉, 每个手机号一条, 分 200, 200, 50 三次插入
+	numbers := []string{"", " "}
+	for i := 0; i < 450; i++ {
+		numbers = append(numbers, fmt.Sprintf("152%08d", i), fmt.Sprintf(" 152%08d ", i))
+	}
+	var sizes []int
+	repo.EXPECT().AddBatch(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, smses []domain.AsyncSms) error {
+		for _, sms := range smses {
+			assert.Equal(t, int64(5), sms.RetryMax)
+			assert.True(t, sms.NextRetryAt.IsZero())
+			assert.Equal(t, "tpl", sms.TplId)
+			assert.Len(t, sms.Numbers, 1)
+		}
+		sizes = append(sizes, len(smses))
+		return nil
+	}).Times(3)
+	cnt, err := svc.BulkSend(context.Background(), domain.AsyncSmsConfig{TplId: "tpl", Numbers: numbers})
+	assert.NoError(t, err)
+	assert.Equal(t, 450, cnt)
+	assert.Equal(t, []int{200, 200, 50}, sizes)
+
+	_, err = svc.BulkSend(context.Background(), domain.AsyncSmsConfig{TplId: "tpl", Numbers: []string{" "}})
+	assert.Equal(t, ErrSmsNoNumbers, err)
+	numbers = make([]string, 0, maxBulkNumbers+1)
+	for i := 0; i <= maxBulkNumbers; i++ {
+		numbers = append(numbers, fmt.Sprintf("152%08d", i))
+	}
+	_, err = svc.BulkSend(context.Background(), domain.AsyncSmsConfig{TplId: "tpl", Numbers: numbers})
+	assert.Equal(t, ErrSmsTooManyNumbers, err)
+}
+
+func TestSmsAdminService_Schedule(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := mock_repository.NewMockAsyncSmsRepository(ctrl)
+	svc := NewSmsAdminService(repo, memory.NewService())
+	conf := domain.AsyncSmsConfig{TplId: "tpl", Numbers: []string{"152"}}
+
+	_, err := svc.Schedule(context.Background(), conf, time.Now().Add(-time.Minute))
+	assert.Equal(t, ErrSmsScheduleTime, err)
+
+	sendAt := time.Now().Add(time.Hour)
+	repo.EXPECT().AddBatch(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, smses []domain.AsyncSms) error {
+		assert.Equal(t, sendAt, smses[0].NextRetryAt)
+		return nil
+	})
+	cnt, err := svc.Schedule(context.Background(), conf, sendAt)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, cnt)
+}
+
+// 模板或者参数不对的短信不保存, 不然后台发送时会一直失败重试
+func TestSmsAdminService_BulkSendValidate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	registry, err := template.NewRegistry([]template.Template{
+		{
+			Name:   "notify",
+			Params: []template.Param{{Name: "code", MinLen: 6, MaxLen: 6, Digits: true}},
+			Locales: map[string]template.Locale{
+				"zh-CN": {Providers: map[string]template.ProviderTemplate{"tencent": {Id: "1"}}},
+			},
+		},
+	}, "zh-CN")
+	require.NoError(t, err)
+	repo := mock_repository.NewMockAsyncSmsRepository(ctrl)
+	svc := NewSmsAdminService(repo, memory.NewService()).SetRegistry(registry)
+	ctx := context.Background()
+
+	_, err = svc.BulkSend(ctx, domain.AsyncSmsConfig{TplId: "login", Args: []string{"123456"}, Numbers: []string{"152"}})
+	assert.ErrorIs(t, err, template.ErrTemplateNotFound)
+	_, err = svc.Schedule(ctx, domain.AsyncSmsConfig{TplId: "notify", Args: []string{"abc"}, Numbers: []string{"152"}},
+		time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, template.ErrInvalidArgs)
+
+	repo.EXPECT().AddBatch(gomock.Any(), gomock.Any()).Return(nil)
+	cnt, err := svc.BulkSend(ctx, domain.AsyncSmsConfig{TplId: "notify", Args: []string{"123456"}, Numbers: []string{"152"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, cnt)
+}
+
+func TestSmsAdminService_Resend(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mock    func(repo *mock_repository.MockAsyncSmsRepository)
+		wantErr error
+	}{
+		{
+			name: "补发成功",
+			mock: func(repo *mock_repository.MockAsyncSmsRepository) {
+				repo.EXPECT().Resend(gomock.Any(), int64(1)).Return(true, nil)
+			},
+		},
+		{
+			name: "短信不存在",
+			mock: func(repo *mock_repository.MockAsyncSmsRepository) {
+				repo.EXPECT().Resend(gomock.Any(), int64(1)).Return(false, nil)
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).Return(domain.AsyncSms{}, repository.ErrAsyncSmsNotFound)
+			},
+			wantErr: ErrSmsNotFound,
+		},
+		{
+			name: "正在发送",
+			mock: func(repo *mock_repository.MockAsyncSmsRepository) {
+				repo.EXPECT().Resend(gomock.Any(), int64(1)).Return(false, nil)
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).
+					Return(domain.AsyncSms{Id: 1, Status: domain.AsyncSmsStatusSending}, nil)
+			},
+			wantErr: ErrSmsNotResendable,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := mock_repository.NewMockAsyncSmsRepository(ctrl)
+			tc.mock(repo)
+			err := NewSmsAdminService(repo, memory.NewService()).Resend(context.Background(), 1)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/exporters/zipkin"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"os"
+)
+
+const serviceName = "redbook"
+
+// initTrace 根据 cfg.Trace.Exporter 初始化链路追踪:
+// stdout 打印到标准输出, file 写入 cfg.Trace.File, zipkin 上报到 cfg.Trace.Endpoint, 为空时不采集.
+// 不管是否采集, 都会传递上游带过来的链路信息.
+func (s *Server) initTrace() error {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+	cfg := s.cfg.Trace
+	switch cfg.Exporter {
+	case "":
+		return nil
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "file":
+		var f *os.File
+		f, err = os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		s.onShutdown("trace_file", func(ctx context.Context) error {
+			return f.Close()
+		})
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(f))
+	case "zipkin":
+		exporter, err = zipkin.New(cfg.Endpoint)
+	default:
+		return fmt.Errorf("未知的 trace exporter: %s", cfg.Exporter)
+	}
+	if err != nil {
+		return err
+	}
+	res, err := resource.Merge(resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName)))
+	if err != nil {
+		return err
+	}
+	ratio := cfg.SampleRatio
+	if ratio <= 0 {
+		ratio = 1
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(tp)
+	// 最先注册, 最后关闭, 保证其他资源关闭过程中产生的 span 也能导出
+	s.onShutdown("trace", tp.Shutdown)
+	return nil
+}
@@ -1,23 +1,24 @@
 package article
 
 import (
-	"context"
-	"fmt"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/lutcoding/redbook/common/globalkey"
 	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/internal/service"
 	"github.com/lutcoding/redbook/pkg/ginx/middlewares"
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
 	"net/http"
 	"strconv"
-	"time"
 )
 
 func (h *Handler) Create(ctx *gin.Context) {
 	type CreateReq struct {
-		Tittle  string `json:"tittle"`
-		Content string `json:"content"`
+		Tittle  string   `json:"tittle"`
+		Content string   `json:"content"`
+		Cover   string   `json:"cover"`
+		Images  []string `json:"images"`
 	}
 	var req CreateReq
 	err := ctx.Bind(&req)
@@ -28,9 +29,18 @@ func (h *Handler) Create(ctx *gin.Context) {
 	id, err := h.svc.Save(ctx, domain.Article{
 		Tittle:   req.Tittle,
 		Content:  req.Content,
+		Cover:    req.Cover,
+		Images:   req.Images,
 		AuthorId: ctx.GetInt64(globalkey.JwtUserId),
 	})
+	if errors.Is(err, service.ErrInvalidMedia) {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: err.Error()})
+		return
+	}
 	if err != nil {
+		// 文章可能已经保存了, 只是后续步骤失败, 返回 id 避免客户端重复创建
+		zap.L().Error("保存文章失败", zap.Int64("id", id), zap.Error(err))
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "系统错误", Data: id})
 		return
 	}
 	ctx.JSON(http.StatusOK, middlewares.Result[int64]{Data: id})
@@ -38,9 +48,11 @@ func (h *Handler) Create(ctx *gin.Context) {
 
 func (h *Handler) Edit(ctx *gin.Context) {
 	type CreateReq struct {
-		Id      int64  `json:"id"`
-		Tittle  string `json:"tittle"`
-		Content string `json:"content"`
+		Id      int64    `json:"id"`
+		Tittle  string   `json:"tittle"`
+		Content string   `json:"content"`
+		Cover   string   `json:"cover"`
+		Images  []string `json:"images"`
 	}
 	var req CreateReq
 	err := ctx.Bind(&req)
@@ -52,9 +64,17 @@ func (h *Handler) Edit(ctx *gin.Context) {
 		Id:       req.Id,
 		Tittle:   req.Tittle,
 		Content:  req.Content,
+		Cover:    req.Cover,
+		Images:   req.Images,
 		AuthorId: ctx.GetInt64(globalkey.JwtUserId),
 	})
+	if errors.Is(err, service.ErrInvalidMedia) {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: err.Error()})
+		return
+	}
 	if err != nil {
+		zap.L().Error("保存文章失败", zap.Int64("id", id), zap.Error(err))
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "系统错误", Data: id})
 		return
 	}
 	ctx.JSON(http.StatusOK, middlewares.Result[int64]{Data: id})
@@ -80,22 +100,33 @@ func (h *Handler) GetDraft(ctx *gin.Context) {
 
 func (h *Handler) Publish(ctx *gin.Context) {
 	type CreateReq struct {
-		Id      int64  `json:"id"`
-		Tittle  string `json:"tittle"`
-		Content string `json:"content"`
+		Id      int64    `json:"id"`
+		Tittle  string   `json:"tittle"`
+		Content string   `json:"content"`
+		Cover   string   `json:"cover"`
+		Images  []string `json:"images"`
 	}
 	var req CreateReq
 	err := ctx.Bind(&req)
 	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "解析json错误，请传入正确参数"})
 		return
 	}
 	id, err := h.svc.Sync(ctx, domain.Article{
 		Id:       req.Id,
 		Tittle:   req.Tittle,
 		Content:  req.Content,
+		Cover:    req.Cover,
+		Images:   req.Images,
 		AuthorId: ctx.GetInt64(globalkey.JwtUserId),
 	})
+	if errors.Is(err, service.ErrInvalidMedia) {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: err.Error()})
+		return
+	}
 	if err != nil {
+		zap.L().Error("发表文章失败", zap.Int64("id", id), zap.Error(err))
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "系统错误", Data: id})
 		return
 	}
 	ctx.JSON(http.StatusOK, middlewares.Result[int64]{Data: id})
@@ -216,21 +247,21 @@ func (h *Handler) GetPub(ctx *gin.Context) {
 	var res ArticleVO
 	var eg errgroup.Group
 
+	value, exists := ctx.Get(globalkey.JwtUserId)
+	if !exists {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "server internal error"})
+		return
+	}
+	uid := value.(int64)
 	// TODO 这里有BUG 这篇文章对应表interactives中如果没有记录, 使用errgroup.Group就会返回错误
 	eg.Go(func() error {
-		art, err := h.svc.GetPub(ctx, id)
+		art, err := h.svc.GetPub(ctx, id, uid)
 		if err != nil {
 			return err
 		}
 		res.Art = art
 		return nil
 	})
-	value, exists := ctx.Get(globalkey.JwtUserId)
-	if !exists {
-		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "server internal error"})
-		return
-	}
-	uid := value.(int64)
 	eg.Go(func() error {
 		info, err := h.interSvc.GetInteractiveInfo(ctx, uid, h.biz, id)
 		if err != nil {
@@ -246,15 +277,6 @@ func (h *Handler) GetPub(ctx *gin.Context) {
 		return
 	}
 
-	go func() {
-		newCtx, cancel := context.WithTimeout(context.Background(), time.Second)
-		defer cancel()
-		err := h.interSvc.IncrReadCnt(newCtx, h.biz, id)
-		if err != nil {
-			zap.L().Error("设置缓存失败", zap.Error(err))
-		}
-	}()
-	fmt.Println(res)
 	ctx.JSON(http.StatusOK, middlewares.Result[ArticleVO]{Data: res})
 }
 
@@ -286,3 +308,61 @@ func (h *Handler) Like(ctx *gin.Context) {
 	}
 	ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "ok"})
 }
+
+func (h *Handler) Collect(ctx *gin.Context) {
+	type Req struct {
+		Id int64 `json:"id"`
+		// 收藏夹 id, 0 代表默认收藏夹
+		Cid     int64 `json:"cid"`
+		Collect bool  `json:"collect"`
+	}
+	var req Req
+	err := ctx.Bind(&req)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "解析json错误，请传入正确参数"})
+		return
+	}
+	value, exists := ctx.Get(globalkey.JwtUserId)
+	if !exists {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "server internal error"})
+		return
+	}
+	uid := value.(int64)
+	if req.Collect {
+		err = h.interSvc.Collect(ctx, uid, req.Cid, h.biz, req.Id)
+	} else {
+		err = h.interSvc.CancelCollect(ctx, uid, h.biz, req.Id)
+	}
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "ok"})
+}
+
+// Hot 热榜, 只返回摘要
+func (h *Handler) Hot(ctx *gin.Context) {
+	type ArticleVO struct {
+		Id       int64  `json:"id"`
+		Tittle   string `json:"tittle"`
+		Abstract string `json:"abstract"`
+		AuthorId int64  `json:"author_id"`
+		Utime    int64  `json:"utime"`
+	}
+	arts, err := h.rankingSvc.GetTopN(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "系统错误"})
+		return
+	}
+	res := make([]ArticleVO, len(arts))
+	for i, art := range arts {
+		res[i] = ArticleVO{
+			Id:       art.Id,
+			Tittle:   art.Tittle,
+			Abstract: art.Abstract(),
+			AuthorId: art.AuthorId,
+			Utime:    art.Utime.UnixMilli(),
+		}
+	}
+	ctx.JSON(http.StatusOK, middlewares.Result[[]ArticleVO]{Data: res})
+}
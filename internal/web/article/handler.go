@@ -6,15 +6,18 @@ import (
 )
 
 type Handler struct {
-	svc      *article.Service
-	interSvc *service.InteractiveService
-	biz      string
+	svc        *article.Service
+	interSvc   *service.InteractiveService
+	rankingSvc *service.RankingService
+	biz        string
 }
 
-func NewHandler(svc *article.Service, interSvc *service.InteractiveService) *Handler {
+func NewHandler(svc *article.Service, interSvc *service.InteractiveService,
+	rankingSvc *service.RankingService) *Handler {
 	return &Handler{
-		svc:      svc,
-		interSvc: interSvc,
-		biz:      "article",
+		svc:        svc,
+		interSvc:   interSvc,
+		rankingSvc: rankingSvc,
+		biz:        "article",
 	}
 }
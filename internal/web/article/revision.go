@@ -0,0 +1,116 @@
+package article
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lutcoding/redbook/common/globalkey"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/pkg/ginx/middlewares"
+	"net/http"
+)
+
+type RevisionVO struct {
+	Id       int64 `json:"id"`
+	Operator int64 `json:"operator"`
+	// Kind 1 保存草稿, 2 发表
+	Kind    uint8    `json:"kind"`
+	Tittle  string   `json:"tittle"`
+	Content string   `json:"content,omitempty"`
+	Cover   string   `json:"cover"`
+	Images  []string `json:"images,omitempty"`
+	Ctime   int64    `json:"ctime"`
+}
+
+func newRevisionVO(r domain.ArticleRevision) RevisionVO {
+	return RevisionVO{
+		Id:       r.Id,
+		Operator: r.Operator,
+		Kind:     r.Kind.ToUint8(),
+		Tittle:   r.Tittle,
+		Content:  r.Content,
+		Cover:    r.Cover,
+		Images:   r.Images,
+		Ctime:    r.Ctime.UnixMilli(),
+	}
+}
+
+func (h *Handler) ListRevisions(ctx *gin.Context) {
+	type ListReq struct {
+		ArticleId int64 `json:"article_id"`
+		Limit     int   `json:"limit"`
+		Offset    int   `json:"offset"`
+	}
+	var req ListReq
+	err := ctx.Bind(&req)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "解析json错误，请传入正确参数"})
+		return
+	}
+	rs, err := h.svc.ListRevisions(ctx, ctx.GetInt64(globalkey.JwtUserId), req.ArticleId, req.Limit, req.Offset)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: err.Error()})
+		return
+	}
+	res := make([]RevisionVO, 0, len(rs))
+	for _, r := range rs {
+		res = append(res, newRevisionVO(r))
+	}
+	ctx.JSON(http.StatusOK, middlewares.Result[[]RevisionVO]{Data: res})
+}
+
+func (h *Handler) DiffRevisions(ctx *gin.Context) {
+	type DiffReq struct {
+		ArticleId int64 `json:"article_id"`
+		From      int64 `json:"from"`
+		To        int64 `json:"to"`
+	}
+	type LineVO struct {
+		Op   string `json:"op"`
+		Text string `json:"text"`
+	}
+	type DiffVO struct {
+		From    RevisionVO `json:"from"`
+		To      RevisionVO `json:"to"`
+		Content []LineVO   `json:"content"`
+	}
+	var req DiffReq
+	err := ctx.Bind(&req)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "解析json错误，请传入正确参数"})
+		return
+	}
+	diff, err := h.svc.DiffRevisions(ctx, ctx.GetInt64(globalkey.JwtUserId), req.ArticleId, req.From, req.To)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: err.Error()})
+		return
+	}
+	res := DiffVO{
+		From:    newRevisionVO(diff.From),
+		To:      newRevisionVO(diff.To),
+		Content: make([]LineVO, 0, len(diff.Content)),
+	}
+	// 正文已经在 content 里逐行给出
+	res.From.Content, res.To.Content = "", ""
+	for _, l := range diff.Content {
+		res.Content = append(res.Content, LineVO{Op: l.Op, Text: l.Text})
+	}
+	ctx.JSON(http.StatusOK, middlewares.Result[DiffVO]{Data: res})
+}
+
+func (h *Handler) RestoreRevision(ctx *gin.Context) {
+	type RestoreReq struct {
+		ArticleId  int64 `json:"article_id"`
+		RevisionId int64 `json:"revision_id"`
+	}
+	var req RestoreReq
+	err := ctx.Bind(&req)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "解析json错误，请传入正确参数"})
+		return
+	}
+	id, err := h.svc.RestoreRevision(ctx, ctx.GetInt64(globalkey.JwtUserId), req.ArticleId, req.RevisionId)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, middlewares.Result[int64]{Data: id})
+}
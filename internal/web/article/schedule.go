@@ -0,0 +1,67 @@
+package article
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/lutcoding/redbook/common/globalkey"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/internal/service"
+	"github.com/lutcoding/redbook/internal/service/article"
+	"github.com/lutcoding/redbook/pkg/ginx/middlewares"
+	"net/http"
+	"time"
+)
+
+// Schedule 保存文章并定时发表
+func (h *Handler) Schedule(ctx *gin.Context) {
+	type ScheduleReq struct {
+		Id      int64    `json:"id"`
+		Tittle  string   `json:"tittle"`
+		Content string   `json:"content"`
+		Cover   string   `json:"cover"`
+		Images  []string `json:"images"`
+		// PublishAt 发表时间, 毫秒时间戳
+		PublishAt int64 `json:"publish_at"`
+	}
+	var req ScheduleReq
+	err := ctx.Bind(&req)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "解析json错误，请传入正确参数"})
+		return
+	}
+	id, err := h.svc.Schedule(ctx, domain.Article{
+		Id:       req.Id,
+		Tittle:   req.Tittle,
+		Content:  req.Content,
+		Cover:    req.Cover,
+		Images:   req.Images,
+		AuthorId: ctx.GetInt64(globalkey.JwtUserId),
+	}, time.UnixMilli(req.PublishAt))
+	if errors.Is(err, article.ErrInvalidPublishAt) || errors.Is(err, service.ErrInvalidMedia) {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "系统错误"})
+		return
+	}
+	ctx.JSON(http.StatusOK, middlewares.Result[int64]{Data: id})
+}
+
+func (h *Handler) CancelSchedule(ctx *gin.Context) {
+	type Req struct {
+		Id int64 `json:"id"`
+	}
+	var req Req
+	err := ctx.Bind(&req)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "解析json错误，请传入正确参数"})
+		return
+	}
+	err = h.svc.CancelSchedule(ctx, req.Id, ctx.GetInt64(globalkey.JwtUserId))
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "ok"})
+}
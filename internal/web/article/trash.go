@@ -0,0 +1,94 @@
+package article
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/lutcoding/redbook/common/globalkey"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/internal/service/article"
+	"github.com/lutcoding/redbook/pkg/ginx/middlewares"
+	"net/http"
+)
+
+// Delete 把文章放进回收站
+func (h *Handler) Delete(ctx *gin.Context) {
+	type Req struct {
+		Id int64 `json:"id"`
+	}
+	var req Req
+	err := ctx.Bind(&req)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "解析json错误，请传入正确参数"})
+		return
+	}
+	err = h.svc.Delete(ctx, req.Id, ctx.GetInt64(globalkey.JwtUserId))
+	if errors.Is(err, article.ErrArticleNotFound) {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "系统错误"})
+		return
+	}
+	ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "ok"})
+}
+
+func (h *Handler) Restore(ctx *gin.Context) {
+	type Req struct {
+		Id int64 `json:"id"`
+	}
+	var req Req
+	err := ctx.Bind(&req)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "解析json错误，请传入正确参数"})
+		return
+	}
+	err = h.svc.Restore(ctx, req.Id, ctx.GetInt64(globalkey.JwtUserId))
+	if errors.Is(err, article.ErrNotTrashed) {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "系统错误"})
+		return
+	}
+	ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "ok"})
+}
+
+func (h *Handler) ListTrash(ctx *gin.Context) {
+	type ListReq struct {
+		Limit  int `json:"limit"`
+		Offset int `json:"offset"`
+	}
+	type ArticleVO struct {
+		Id       int64  `json:"id"`
+		Tittle   string `json:"tittle"`
+		Abstract string `json:"abstract"`
+		// Dtime 放进回收站的时间, 毫秒时间戳
+		Dtime int64 `json:"dtime"`
+	}
+	var req ListReq
+	err := ctx.Bind(&req)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "解析json错误，请传入正确参数"})
+		return
+	}
+	arts, err := h.svc.ListTrash(ctx, ctx.GetInt64(globalkey.JwtUserId), req.Limit, req.Offset)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "系统错误"})
+		return
+	}
+	fn := func(arts []domain.Article) []ArticleVO {
+		res := make([]ArticleVO, len(arts))
+		for i, art := range arts {
+			res[i] = ArticleVO{
+				Id:       art.Id,
+				Tittle:   art.Tittle,
+				Abstract: art.Abstract(),
+				Dtime:    art.Dtime.UnixMilli(),
+			}
+		}
+		return res
+	}
+	ctx.JSON(http.StatusOK, middlewares.Result[[]ArticleVO]{Data: fn(arts)})
+}
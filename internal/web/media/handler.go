@@ -0,0 +1,90 @@
+package media
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/lutcoding/redbook/common/globalkey"
+	"github.com/lutcoding/redbook/internal/service"
+	"github.com/lutcoding/redbook/pkg/ginx/middlewares"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+)
+
+type Handler struct {
+	svc *service.MediaService
+}
+
+func NewHandler(svc *service.MediaService) *Handler {
+	return &Handler{
+		svc: svc,
+	}
+}
+
+type MediaVO struct {
+	Key      string `json:"key"`
+	ThumbKey string `json:"thumb_key"`
+	URL      string `json:"url"`
+	ThumbURL string `json:"thumb_url"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+}
+
+// Upload 上传图片, multipart 表单字段 file.
+// 返回的 key 用作文章的 cover 和 images
+func (h *Handler) Upload(ctx *gin.Context) {
+	fh, err := ctx.FormFile("file")
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[MediaVO]{Msg: "请上传图片"})
+		return
+	}
+	if fh.Size > h.svc.MaxSize() {
+		ctx.JSON(http.StatusOK, middlewares.Result[MediaVO]{Msg: service.ErrMediaTooLarge.Error()})
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[MediaVO]{Msg: "系统错误"})
+		return
+	}
+	defer f.Close()
+	// 多读一个字节, 用来判断是否超过大小限制
+	data, err := io.ReadAll(io.LimitReader(f, h.svc.MaxSize()+1))
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[MediaVO]{Msg: "系统错误"})
+		return
+	}
+	m, err := h.svc.Upload(ctx, ctx.GetInt64(globalkey.JwtUserId), data)
+	switch {
+	case errors.Is(err, service.ErrMediaTooLarge), errors.Is(err, service.ErrUnsupportedMedia):
+		ctx.JSON(http.StatusOK, middlewares.Result[MediaVO]{Msg: err.Error()})
+		return
+	case err != nil:
+		zap.L().Error("上传图片失败", zap.Error(err))
+		ctx.JSON(http.StatusOK, middlewares.Result[MediaVO]{Msg: "系统错误"})
+		return
+	}
+	vo := MediaVO{
+		Key:      m.Key,
+		ThumbKey: m.ThumbKey,
+		Width:    m.Width,
+		Height:   m.Height,
+	}
+	vo.URL, _ = h.svc.URL(ctx, m.Key)
+	vo.ThumbURL, _ = h.svc.URL(ctx, m.ThumbKey)
+	ctx.JSON(http.StatusOK, middlewares.Result[MediaVO]{Data: vo})
+}
+
+// URL 获取图片的临时下载链接
+func (h *Handler) URL(ctx *gin.Context) {
+	url, err := h.svc.URL(ctx, ctx.Query("key"))
+	if errors.Is(err, service.ErrInvalidMedia) {
+		ctx.JSON(http.StatusOK, middlewares.Result[string]{Msg: "图片不存在"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[string]{Msg: "系统错误"})
+		return
+	}
+	ctx.JSON(http.StatusOK, middlewares.Result[string]{Data: url})
+}
@@ -0,0 +1,82 @@
+package sms
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/pkg/ginx/middlewares"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	exportPageSize = 100
+	// maxExportRows 一次最多导出的行数, 需要更多时缩小时间范围分几次导出
+	maxExportRows = 10000
+	timeLayout    = "2006-01-02 15:04:05"
+)
+
+// Export 按查询条件导出 csv, 按 id 倒序
+func (h *Handler) Export(ctx *gin.Context) {
+	var req QueryReq
+	err := ctx.Bind(&req)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "解析json错误，请传入正确参数"})
+		return
+	}
+	query, err := req.toDomain()
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: err.Error()})
+		return
+	}
+	// 先查第一页, 查询失败的时候还可以返回 json
+	smses, err := h.svc.List(ctx, query, exportPageSize, 0)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "系统错误"})
+		return
+	}
+	ctx.Header("Content-Type", "text/csv; charset=utf-8")
+	ctx.Header("Content-Disposition",
+		fmt.Sprintf("attachment; filename=sms_%s.csv", time.Now().Format("20060102150405")))
+	// 加上 BOM, excel 打开时中文才不会乱码
+	_, _ = ctx.Writer.WriteString("\xEF\xBB\xBF")
+	w := csv.NewWriter(ctx.Writer)
+	_ = w.Write([]string{"id", "tpl_id", "args", "numbers", "status", "retry_cnt", "retry_max",
+		"last_err", "next_retry_at", "ctime", "utime"})
+	for offset := 0; ; {
+		for _, sms := range smses {
+			_ = w.Write(toRecord(sms))
+		}
+		offset += len(smses)
+		if len(smses) < exportPageSize || offset >= maxExportRows {
+			break
+		}
+		smses, err = h.svc.List(ctx, query, exportPageSize, offset)
+		if err != nil {
+			// 响应头已经发出去了, 只能记日志, 导出的文件不完整
+			zap.L().Error("导出短信失败", zap.Int("offset", offset), zap.Error(err))
+			break
+		}
+	}
+	w.Flush()
+}
+
+func toRecord(sms domain.AsyncSms) []string {
+	return []string{
+		strconv.FormatInt(sms.Id, 10),
+		sms.TplId,
+		strings.Join(sms.Args, ";"),
+		strings.Join(sms.Numbers, ";"),
+		sms.Status.String(),
+		strconv.FormatInt(sms.RetryCnt, 10),
+		strconv.FormatInt(sms.RetryMax, 10),
+		sms.LastErr,
+		sms.NextRetryAt.Format(timeLayout),
+		sms.Ctime.Format(timeLayout),
+		sms.Utime.Format(timeLayout),
+	}
+}
@@ -0,0 +1,288 @@
+package sms
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/internal/service"
+	"github.com/lutcoding/redbook/internal/service/sms/template"
+	"github.com/lutcoding/redbook/pkg/ginx/middlewares"
+	"net/http"
+	"time"
+)
+
+var errInvalidStatus = errors.New("状态错误, 可选 waiting, sending, success, failed")
+
+// Handler 短信管理后台, 只有管理员可以访问
+type Handler struct {
+	svc *service.SmsAdminService
+}
+
+func NewHandler(svc *service.SmsAdminService) *Handler {
+	return &Handler{
+		svc: svc,
+	}
+}
+
+type SmsVO struct {
+	Id       int64    `json:"id"`
+	TplId    string   `json:"tpl_id"`
+	Args     []string `json:"args"`
+	Numbers  []string `json:"numbers"`
+	Status   string   `json:"status"`
+	RetryCnt int64    `json:"retry_cnt"`
+	RetryMax int64    `json:"retry_max"`
+	LastErr  string   `json:"last_err"`
+	// NextRetryAt 等待发送时是下一次发送的时间, 毫秒时间戳
+	NextRetryAt int64 `json:"next_retry_at"`
+	Ctime       int64 `json:"ctime"`
+	Utime       int64 `json:"utime"`
+}
+
+func newSmsVO(sms domain.AsyncSms) SmsVO {
+	return SmsVO{
+		Id:          sms.Id,
+		TplId:       sms.TplId,
+		Args:        sms.Args,
+		Numbers:     sms.Numbers,
+		Status:      sms.Status.String(),
+		RetryCnt:    sms.RetryCnt,
+		RetryMax:    sms.RetryMax,
+		LastErr:     sms.LastErr,
+		NextRetryAt: sms.NextRetryAt.UnixMilli(),
+		Ctime:       sms.Ctime.UnixMilli(),
+		Utime:       sms.Utime.UnixMilli(),
+	}
+}
+
+type QueryReq struct {
+	// Status 为空时查询所有状态
+	Status string `json:"status"`
+	Number string `json:"number"`
+	// 创建时间在 [Start, End) 之间, 毫秒时间戳, 0 表示不限制
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+func (req QueryReq) toDomain() (domain.AsyncSmsQuery, error) {
+	query := domain.AsyncSmsQuery{
+		Number: req.Number,
+	}
+	if req.Status != "" {
+		status, ok := parseStatus(req.Status)
+		if !ok {
+			return domain.AsyncSmsQuery{}, errInvalidStatus
+		}
+		query.Status = &status
+	}
+	if req.Start > 0 {
+		query.Start = time.UnixMilli(req.Start)
+	}
+	if req.End > 0 {
+		query.End = time.UnixMilli(req.End)
+	}
+	return query, nil
+}
+
+func parseStatus(str string) (domain.AsyncSmsStatus, bool) {
+	for _, status := range []domain.AsyncSmsStatus{domain.AsyncSmsStatusWaiting, domain.AsyncSmsStatusSending,
+		domain.AsyncSmsStatusSuccess, domain.AsyncSmsStatusFailed} {
+		if status.String() == str {
+			return status, true
+		}
+	}
+	return 0, false
+}
+
+func (h *Handler) List(ctx *gin.Context) {
+	type ListReq struct {
+		QueryReq
+		Limit  int `json:"limit"`
+		Offset int `json:"offset"`
+	}
+	type ListVO struct {
+		Total int64   `json:"total"`
+		List  []SmsVO `json:"list"`
+	}
+	var req ListReq
+	err := ctx.Bind(&req)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "解析json错误，请传入正确参数"})
+		return
+	}
+	query, err := req.toDomain()
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: err.Error()})
+		return
+	}
+	smses, err := h.svc.List(ctx, query, req.Limit, req.Offset)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "系统错误"})
+		return
+	}
+	total, err := h.svc.Count(ctx, query)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "系统错误"})
+		return
+	}
+	res := ListVO{Total: total, List: make([]SmsVO, 0, len(smses))}
+	for _, sms := range smses {
+		res.List = append(res.List, newSmsVO(sms))
+	}
+	ctx.JSON(http.StatusOK, middlewares.Result[ListVO]{Data: res})
+}
+
+// Detail 短信和每一次发送的结果
+func (h *Handler) Detail(ctx *gin.Context) {
+	type Req struct {
+		Id int64 `json:"id"`
+	}
+	type AttemptVO struct {
+		Attempt int64 `json:"attempt"`
+		// Err 为空表示发送成功
+		Err string `json:"err"`
+		// Latency 发送耗时, 毫秒
+		Latency int64 `json:"latency"`
+		Ctime   int64 `json:"ctime"`
+	}
+	type DetailVO struct {
+		SmsVO
+		Attempts []AttemptVO `json:"attempts"`
+	}
+	var req Req
+	err := ctx.Bind(&req)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "解析json错误，请传入正确参数"})
+		return
+	}
+	sms, attempts, err := h.svc.Detail(ctx, req.Id)
+	if errors.Is(err, service.ErrSmsNotFound) {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "短信不存在"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "系统错误"})
+		return
+	}
+	res := DetailVO{SmsVO: newSmsVO(sms), Attempts: make([]AttemptVO, 0, len(attempts))}
+	for _, attempt := range attempts {
+		res.Attempts = append(res.Attempts, AttemptVO{
+			Attempt: attempt.Attempt,
+			Err:     attempt.Err,
+			Latency: attempt.Latency.Milliseconds(),
+			Ctime:   attempt.Ctime.UnixMilli(),
+		})
+	}
+	ctx.JSON(http.StatusOK, middlewares.Result[DetailVO]{Data: res})
+}
+
+// Resend 补发发送成功或者失败的短信
+func (h *Handler) Resend(ctx *gin.Context) {
+	type Req struct {
+		Id int64 `json:"id"`
+	}
+	var req Req
+	err := ctx.Bind(&req)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "解析json错误，请传入正确参数"})
+		return
+	}
+	err = h.svc.Resend(ctx, req.Id)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "ok"})
+	case errors.Is(err, service.ErrSmsNotFound):
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "短信不存在"})
+	case errors.Is(err, service.ErrSmsNotResendable):
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: err.Error()})
+	default:
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "系统错误"})
+	}
+}
+
+// Send 单发, 同步返回发送结果
+func (h *Handler) Send(ctx *gin.Context) {
+	type SendReq struct {
+		TplId  string   `json:"tpl_id"`
+		Args   []string `json:"args"`
+		Number string   `json:"number"`
+	}
+	var req SendReq
+	err := ctx.Bind(&req)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "解析json错误，请传入正确参数"})
+		return
+	}
+	err = h.svc.Send(ctx, req.TplId, req.Args, req.Number)
+	if errors.Is(err, service.ErrSmsNoNumbers) || isTemplateErr(err) {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "发送失败"})
+		return
+	}
+	ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "ok"})
+}
+
+type BulkReq struct {
+	TplId   string   `json:"tpl_id"`
+	Args    []string `json:"args"`
+	Numbers []string `json:"numbers"`
+}
+
+func (req BulkReq) toDomain() domain.AsyncSmsConfig {
+	return domain.AsyncSmsConfig{
+		TplId:   req.TplId,
+		Args:    req.Args,
+		Numbers: req.Numbers,
+	}
+}
+
+// BulkSend 群发, 返回保存的异步短信数量, 由后台任务发送
+func (h *Handler) BulkSend(ctx *gin.Context) {
+	var req BulkReq
+	err := ctx.Bind(&req)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "解析json错误，请传入正确参数"})
+		return
+	}
+	cnt, err := h.svc.BulkSend(ctx, req.toDomain())
+	h.enqueueResult(ctx, cnt, err)
+}
+
+// Schedule 定时发送
+func (h *Handler) Schedule(ctx *gin.Context) {
+	type ScheduleReq struct {
+		BulkReq
+		// SendAt 发送时间, 毫秒时间戳
+		SendAt int64 `json:"send_at"`
+	}
+	var req ScheduleReq
+	err := ctx.Bind(&req)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "解析json错误，请传入正确参数"})
+		return
+	}
+	cnt, err := h.svc.Schedule(ctx, req.toDomain(), time.UnixMilli(req.SendAt))
+	h.enqueueResult(ctx, cnt, err)
+}
+
+func (h *Handler) enqueueResult(ctx *gin.Context, cnt int, err error) {
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, middlewares.Result[int]{Data: cnt})
+	case errors.Is(err, service.ErrSmsNoNumbers), errors.Is(err, service.ErrSmsTooManyNumbers),
+		errors.Is(err, service.ErrSmsScheduleTime), isTemplateErr(err):
+		ctx.JSON(http.StatusOK, middlewares.Result[int]{Msg: err.Error()})
+	default:
+		// 部分保存成功的会照常发送
+		ctx.JSON(http.StatusOK, middlewares.Result[int]{Msg: "系统错误", Data: cnt})
+	}
+}
+
+// isTemplateErr 模板不存在或者参数不对, 直接告诉管理员原因
+func isTemplateErr(err error) bool {
+	return errors.Is(err, template.ErrTemplateNotFound) || errors.Is(err, template.ErrLocaleNotFound) ||
+		errors.Is(err, template.ErrInvalidArgs)
+}
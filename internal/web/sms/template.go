@@ -0,0 +1,89 @@
+package sms
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/lutcoding/redbook/internal/service/sms/template"
+	"github.com/lutcoding/redbook/pkg/ginx/middlewares"
+	"net/http"
+	"sort"
+)
+
+// TemplateHandler 查看配置的短信模板和预览内容
+type TemplateHandler struct {
+	registry *template.Registry
+}
+
+func NewTemplateHandler(registry *template.Registry) *TemplateHandler {
+	return &TemplateHandler{
+		registry: registry,
+	}
+}
+
+func (h *TemplateHandler) List(ctx *gin.Context) {
+	type ParamVO struct {
+		Name   string `json:"name"`
+		MinLen int    `json:"min_len"`
+		MaxLen int    `json:"max_len"`
+		Digits bool   `json:"digits"`
+	}
+	type LocaleVO struct {
+		Locale  string `json:"locale"`
+		Content string `json:"content"`
+		// Providers 服务商到模板 id
+		Providers map[string]string `json:"providers"`
+	}
+	type TemplateVO struct {
+		Name    string     `json:"name"`
+		Params  []ParamVO  `json:"params"`
+		Locales []LocaleVO `json:"locales"`
+	}
+	tpls := h.registry.Templates()
+	sort.Slice(tpls, func(i, j int) bool {
+		return tpls[i].Name < tpls[j].Name
+	})
+	res := make([]TemplateVO, 0, len(tpls))
+	for _, tpl := range tpls {
+		vo := TemplateVO{Name: tpl.Name}
+		for _, p := range tpl.Params {
+			vo.Params = append(vo.Params, ParamVO{Name: p.Name, MinLen: p.MinLen, MaxLen: p.MaxLen, Digits: p.Digits})
+		}
+		for locale, l := range tpl.Locales {
+			providers := make(map[string]string, len(l.Providers))
+			for provider, pt := range l.Providers {
+				providers[provider] = pt.Id
+			}
+			vo.Locales = append(vo.Locales, LocaleVO{Locale: locale, Content: l.Content, Providers: providers})
+		}
+		sort.Slice(vo.Locales, func(i, j int) bool {
+			return vo.Locales[i].Locale < vo.Locales[j].Locale
+		})
+		res = append(res, vo)
+	}
+	ctx.JSON(http.StatusOK, middlewares.Result[[]TemplateVO]{Data: res})
+}
+
+// Preview 用参数渲染模板内容, locale 为空时使用默认语言
+func (h *TemplateHandler) Preview(ctx *gin.Context) {
+	type PreviewReq struct {
+		Name   string            `json:"name"`
+		Locale string            `json:"locale"`
+		Params map[string]string `json:"params"`
+	}
+	var req PreviewReq
+	err := ctx.Bind(&req)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "解析json错误，请传入正确参数"})
+		return
+	}
+	content, err := h.registry.Preview(req.Name, req.Locale, req.Params)
+	if errors.Is(err, template.ErrTemplateNotFound) || errors.Is(err, template.ErrInvalidArgs) {
+		ctx.JSON(http.StatusOK, middlewares.Result[string]{Msg: err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[string]{Msg: "系统错误"})
+		return
+	}
+	ctx.JSON(http.StatusOK, middlewares.Result[string]{Data: content})
+}
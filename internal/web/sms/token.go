@@ -0,0 +1,168 @@
+package sms
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/lutcoding/redbook/common/globalkey"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/internal/service/sms/auth"
+	"github.com/lutcoding/redbook/pkg/ginx/middlewares"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TokenHandler 管理后台签发业务方的短信凭证, 业务方带着凭证来发送短信
+type TokenHandler struct {
+	tokenSvc *auth.TokenService
+	authSvc  *auth.Service
+}
+
+func NewTokenHandler(tokenSvc *auth.TokenService, authSvc *auth.Service) *TokenHandler {
+	return &TokenHandler{
+		tokenSvc: tokenSvc,
+		authSvc:  authSvc,
+	}
+}
+
+func (h *TokenHandler) Create(ctx *gin.Context) {
+	type CreateReq struct {
+		Biz           string   `json:"biz"`
+		Tpls          []string `json:"tpls"`
+		MonthlyQuota  int64    `json:"monthly_quota"`
+		RatePerMinute int64    `json:"rate_per_minute"`
+		// ExpireAt 毫秒时间戳, 0 表示不过期
+		ExpireAt int64 `json:"expire_at"`
+	}
+	type CreateVO struct {
+		Id    int64  `json:"id"`
+		Token string `json:"token"`
+	}
+	var req CreateReq
+	err := ctx.Bind(&req)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "解析json错误，请传入正确参数"})
+		return
+	}
+	t := domain.SmsToken{
+		Biz:           req.Biz,
+		Tpls:          req.Tpls,
+		MonthlyQuota:  req.MonthlyQuota,
+		RatePerMinute: req.RatePerMinute,
+		Creator:       ctx.GetInt64(globalkey.JwtUserId),
+	}
+	if req.ExpireAt > 0 {
+		t.ExpireAt = time.UnixMilli(req.ExpireAt)
+	}
+	id, token, err := h.tokenSvc.Issue(ctx, t)
+	if errors.Is(err, auth.ErrInvalidTokenReq) {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "系统错误"})
+		return
+	}
+	ctx.JSON(http.StatusOK, middlewares.Result[CreateVO]{Data: CreateVO{Id: id, Token: token}})
+}
+
+func (h *TokenHandler) List(ctx *gin.Context) {
+	type ListReq struct {
+		Limit  int `json:"limit"`
+		Offset int `json:"offset"`
+	}
+	type TokenVO struct {
+		Id            int64    `json:"id"`
+		Biz           string   `json:"biz"`
+		Tpls          []string `json:"tpls"`
+		MonthlyQuota  int64    `json:"monthly_quota"`
+		RatePerMinute int64    `json:"rate_per_minute"`
+		// Used 本月已经用掉的额度
+		Used     int64 `json:"used"`
+		Revoked  bool  `json:"revoked"`
+		Creator  int64 `json:"creator"`
+		ExpireAt int64 `json:"expire_at"`
+		Ctime    int64 `json:"ctime"`
+	}
+	var req ListReq
+	err := ctx.Bind(&req)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "解析json错误，请传入正确参数"})
+		return
+	}
+	tokens, err := h.tokenSvc.List(ctx, req.Limit, req.Offset)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "系统错误"})
+		return
+	}
+	res := make([]TokenVO, 0, len(tokens))
+	for _, t := range tokens {
+		vo := TokenVO{
+			Id:            t.Id,
+			Biz:           t.Biz,
+			Tpls:          t.Tpls,
+			MonthlyQuota:  t.MonthlyQuota,
+			RatePerMinute: t.RatePerMinute,
+			Used:          t.Used,
+			Revoked:       t.Revoked,
+			Creator:       t.Creator,
+			Ctime:         t.Ctime.UnixMilli(),
+		}
+		if !t.ExpireAt.IsZero() {
+			vo.ExpireAt = t.ExpireAt.UnixMilli()
+		}
+		res = append(res, vo)
+	}
+	ctx.JSON(http.StatusOK, middlewares.Result[[]TokenVO]{Data: res})
+}
+
+func (h *TokenHandler) Revoke(ctx *gin.Context) {
+	type Req struct {
+		Id int64 `json:"id"`
+	}
+	var req Req
+	err := ctx.Bind(&req)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "解析json错误，请传入正确参数"})
+		return
+	}
+	err = h.tokenSvc.Revoke(ctx, req.Id)
+	if errors.Is(err, auth.ErrTokenNotFound) {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "凭证不存在"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "系统错误"})
+		return
+	}
+	ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "ok"})
+}
+
+// Send 业务方发送短信, 凭证放在 Authorization: Bearer xxx 里
+func (h *TokenHandler) Send(ctx *gin.Context) {
+	type SendReq struct {
+		TplId   string   `json:"tpl_id"`
+		Args    []string `json:"args"`
+		Numbers []string `json:"numbers"`
+	}
+	var req SendReq
+	err := ctx.Bind(&req)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "解析json错误，请传入正确参数"})
+		return
+	}
+	token := strings.TrimPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+	err = h.authSvc.Send(auth.WithToken(ctx, token), req.TplId, req.Args, req.Numbers...)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "ok"})
+	case errors.Is(err, auth.ErrInvalidToken):
+		// 不告诉调用方具体哪里不对
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: auth.ErrInvalidToken.Error()})
+	case errors.Is(err, auth.ErrNumbersRequired), errors.Is(err, auth.ErrTokenRevoked), errors.Is(err, auth.ErrTplNotAllowed),
+		errors.Is(err, auth.ErrRateLimited), errors.Is(err, auth.ErrQuotaExhausted):
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: err.Error()})
+	default:
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "发送失败"})
+	}
+}
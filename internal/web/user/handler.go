@@ -9,21 +9,26 @@ import (
 const (
 	emailRegexPattern = "^\\w+([-+.]\\w+)*@\\w+([-.]\\w+)*\\.\\w+([-.]\\w+)*$"
 	biz               = "login"
+	// 收藏夹目前只能收藏文章
+	collectBiz = "article"
 )
 
 type Handler struct {
-	svc    *service.UserService
-	smsSvc *service.CodeService
+	svc        *service.UserService
+	smsSvc     *service.CodeService
+	collectSvc *service.CollectionService
 	// 预编译正则表达式匹配邮箱格式
 	emailRegexExp *regexp.Regexp
 	jwtHdl        *jwt.Handler
 }
 
-func New(userSvc *service.UserService, smsSvc *service.CodeService, jwt *jwt.Handler) *Handler {
+func New(userSvc *service.UserService, smsSvc *service.CodeService,
+	collectSvc *service.CollectionService, jwt *jwt.Handler) *Handler {
 	return &Handler{
 		emailRegexExp: regexp.MustCompile(emailRegexPattern, regexp.None),
 		svc:           userSvc,
 		smsSvc:        smsSvc,
+		collectSvc:    collectSvc,
 		jwtHdl:        jwt,
 	}
 }
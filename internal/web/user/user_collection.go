@@ -0,0 +1,101 @@
+package user
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lutcoding/redbook/common/globalkey"
+	"github.com/lutcoding/redbook/internal/domain"
+	"github.com/lutcoding/redbook/pkg/ginx/middlewares"
+	"net/http"
+)
+
+func (h *Handler) CreateCollection(ctx *gin.Context) {
+	type Req struct {
+		Name string `json:"name"`
+	}
+	var req Req
+	err := ctx.Bind(&req)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "解析json错误，请传入正确参数"})
+		return
+	}
+	id, err := h.collectSvc.Create(ctx, domain.Collection{
+		Uid:  ctx.GetInt64(globalkey.JwtUserId),
+		Name: req.Name,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, middlewares.Result[int64]{Data: id})
+}
+
+func (h *Handler) ListCollections(ctx *gin.Context) {
+	type CollectionVO struct {
+		Id   int64  `json:"id"`
+		Name string `json:"name"`
+	}
+	cs, err := h.collectSvc.List(ctx, ctx.GetInt64(globalkey.JwtUserId))
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: err.Error()})
+		return
+	}
+	res := make([]CollectionVO, 0, len(cs)+1)
+	res = append(res, CollectionVO{Id: 0, Name: "默认收藏夹"})
+	for _, c := range cs {
+		res = append(res, CollectionVO{Id: c.Id, Name: c.Name})
+	}
+	ctx.JSON(http.StatusOK, middlewares.Result[[]CollectionVO]{Data: res})
+}
+
+func (h *Handler) ListCollectItems(ctx *gin.Context) {
+	type ListReq struct {
+		Cid    int64 `json:"cid"`
+		Limit  int   `json:"limit"`
+		Offset int   `json:"offset"`
+	}
+	type ItemVO struct {
+		Cid   int64 `json:"cid"`
+		BizId int64 `json:"biz_id"`
+		Ctime int64 `json:"ctime"`
+	}
+	var req ListReq
+	err := ctx.Bind(&req)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "解析json错误，请传入正确参数"})
+		return
+	}
+	items, err := h.collectSvc.ListItems(ctx, ctx.GetInt64(globalkey.JwtUserId), req.Cid, req.Limit, req.Offset)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: err.Error()})
+		return
+	}
+	res := make([]ItemVO, len(items))
+	for i, item := range items {
+		res[i] = ItemVO{
+			Cid:   item.Cid,
+			BizId: item.BizId,
+			Ctime: item.Ctime,
+		}
+	}
+	ctx.JSON(http.StatusOK, middlewares.Result[[]ItemVO]{Data: res})
+}
+
+// MoveCollectItem 把收藏的文章移动到另一个收藏夹
+func (h *Handler) MoveCollectItem(ctx *gin.Context) {
+	type Req struct {
+		Id  int64 `json:"id"`
+		Cid int64 `json:"cid"`
+	}
+	var req Req
+	err := ctx.Bind(&req)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "解析json错误，请传入正确参数"})
+		return
+	}
+	err = h.collectSvc.Move(ctx, ctx.GetInt64(globalkey.JwtUserId), collectBiz, req.Id, req.Cid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, middlewares.Result[int64]{Msg: "ok"})
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"strconv"
+	"time"
+)
+
+// Builder 统计 HTTP 请求数和响应时间, 按照路由模式(而不是具体的 URL)和状态码分组,
+// 避免 /articles/published/get/:id 这种路由产生大量的 label.
+type Builder struct {
+	Namespace string
+	Subsystem string
+	// Buckets 响应时间分布的桶, 单位秒, 默认 prometheus.DefBuckets
+	Buckets []float64
+}
+
+func NewBuilder(namespace, subsystem string) *Builder {
+	return &Builder{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Buckets:   prometheus.DefBuckets,
+	}
+}
+
+func (b *Builder) Build() gin.HandlerFunc {
+	labels := []string{"method", "pattern", "status"}
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: b.Namespace,
+		Subsystem: b.Subsystem,
+		Name:      "http_requests_total",
+		Help:      "HTTP 请求数",
+	}, labels)
+	histogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: b.Namespace,
+		Subsystem: b.Subsystem,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP 响应时间",
+		Buckets:   b.Buckets,
+	}, labels)
+	prometheus.MustRegister(counter, histogram)
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+		pattern := ctx.FullPath()
+		if pattern == "" {
+			// 没有匹配到路由, 比如 404
+			pattern = "unknown"
+		}
+		values := []string{ctx.Request.Method, pattern, strconv.Itoa(ctx.Writer.Status())}
+		counter.WithLabelValues(values...).Inc()
+		histogram.WithLabelValues(values...).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,111 @@
+package gormx
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+	"time"
+)
+
+const startTimeKey = "gormx:metrics:start"
+
+// MetricsPlugin 按照表名和操作类型统计 SQL 执行时间
+type MetricsPlugin struct {
+	vector *prometheus.HistogramVec
+}
+
+func NewMetricsPlugin(namespace, subsystem string) *MetricsPlugin {
+	vector := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "gorm_query_duration_seconds",
+		Help:      "GORM 执行 SQL 的时间",
+		Buckets:   []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+	}, []string{"table", "op", "status"})
+	prometheus.MustRegister(vector)
+	return &MetricsPlugin{
+		vector: vector,
+	}
+}
+
+func (p *MetricsPlugin) Name() string {
+	return "prometheus_metrics"
+}
+
+func (p *MetricsPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	err := cb.Create().Before("*").Register("prometheus:before_create", p.before)
+	if err != nil {
+		return err
+	}
+	err = cb.Create().After("*").Register("prometheus:after_create", p.after("create"))
+	if err != nil {
+		return err
+	}
+	err = cb.Query().Before("*").Register("prometheus:before_query", p.before)
+	if err != nil {
+		return err
+	}
+	err = cb.Query().After("*").Register("prometheus:after_query", p.after("query"))
+	if err != nil {
+		return err
+	}
+	err = cb.Update().Before("*").Register("prometheus:before_update", p.before)
+	if err != nil {
+		return err
+	}
+	err = cb.Update().After("*").Register("prometheus:after_update", p.after("update"))
+	if err != nil {
+		return err
+	}
+	err = cb.Delete().Before("*").Register("prometheus:before_delete", p.before)
+	if err != nil {
+		return err
+	}
+	err = cb.Delete().After("*").Register("prometheus:after_delete", p.after("delete"))
+	if err != nil {
+		return err
+	}
+	err = cb.Raw().Before("*").Register("prometheus:before_raw", p.before)
+	if err != nil {
+		return err
+	}
+	err = cb.Raw().After("*").Register("prometheus:after_raw", p.after("raw"))
+	if err != nil {
+		return err
+	}
+	err = cb.Row().Before("*").Register("prometheus:before_row", p.before)
+	if err != nil {
+		return err
+	}
+	err = cb.Row().After("*").Register("prometheus:after_row", p.after("row"))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (p *MetricsPlugin) before(db *gorm.DB) {
+	db.InstanceSet(startTimeKey, time.Now())
+}
+
+func (p *MetricsPlugin) after(op string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		val, ok := db.InstanceGet(startTimeKey)
+		if !ok {
+			return
+		}
+		start, ok := val.(time.Time)
+		if !ok {
+			return
+		}
+		table := db.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		status := "success"
+		if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+			status = "failed"
+		}
+		p.vector.WithLabelValues(table, op, status).Observe(time.Since(start).Seconds())
+	}
+}
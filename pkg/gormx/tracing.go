@@ -0,0 +1,109 @@
+package gormx
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const spanKey = "gormx:tracing:span"
+
+// TracingPlugin 为每一次 SQL 执行创建一个 span, 记录表名, 操作类型和 SQL 语句.
+// 需要通过 db.WithContext(ctx) 传入 ctx, span 才能挂到请求的链路上.
+type TracingPlugin struct {
+	tracer trace.Tracer
+}
+
+func NewTracingPlugin() *TracingPlugin {
+	return &TracingPlugin{
+		tracer: otel.Tracer("github.com/lutcoding/redbook/pkg/gormx"),
+	}
+}
+
+func (p *TracingPlugin) Name() string {
+	return "opentelemetry_tracing"
+}
+
+func (p *TracingPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	err := cb.Create().Before("*").Register("otel:before_create", p.before("create"))
+	if err != nil {
+		return err
+	}
+	err = cb.Create().After("*").Register("otel:after_create", p.after)
+	if err != nil {
+		return err
+	}
+	err = cb.Query().Before("*").Register("otel:before_query", p.before("query"))
+	if err != nil {
+		return err
+	}
+	err = cb.Query().After("*").Register("otel:after_query", p.after)
+	if err != nil {
+		return err
+	}
+	err = cb.Update().Before("*").Register("otel:before_update", p.before("update"))
+	if err != nil {
+		return err
+	}
+	err = cb.Update().After("*").Register("otel:after_update", p.after)
+	if err != nil {
+		return err
+	}
+	err = cb.Delete().Before("*").Register("otel:before_delete", p.before("delete"))
+	if err != nil {
+		return err
+	}
+	err = cb.Delete().After("*").Register("otel:after_delete", p.after)
+	if err != nil {
+		return err
+	}
+	err = cb.Raw().Before("*").Register("otel:before_raw", p.before("raw"))
+	if err != nil {
+		return err
+	}
+	err = cb.Raw().After("*").Register("otel:after_raw", p.after)
+	if err != nil {
+		return err
+	}
+	err = cb.Row().Before("*").Register("otel:before_row", p.before("row"))
+	if err != nil {
+		return err
+	}
+	return cb.Row().After("*").Register("otel:after_row", p.after)
+}
+
+func (p *TracingPlugin) before(op string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		_, span := p.tracer.Start(db.Statement.Context, "gorm:"+op,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system", "mysql"),
+				attribute.String("db.operation", op),
+			))
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+func (p *TracingPlugin) after(db *gorm.DB) {
+	val, ok := db.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span, ok := val.(trace.Span)
+	if !ok {
+		return
+	}
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.sql.table", db.Statement.Table),
+		attribute.String("db.statement", db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+}
@@ -0,0 +1,146 @@
+package localcache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Cache 进程内的 LRU 缓存, 每个 key 有过期时间.
+// 超过容量时淘汰最久没有访问的 key, 过期的 key 在访问时删除, 调用 StartCleanup 之后也会定期删除
+type Cache[V any] struct {
+	name     string
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	ll       *list.List
+	items    map[string]*list.Element
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+type entry[V any] struct {
+	key string
+	val V
+	ddl time.Time
+}
+
+// New name 用于监控, capacity 是最多保存的 key 的数量, ttl 是默认的过期时间
+func New[V any](name string, capacity int, ttl time.Duration) *Cache[V] {
+	return &Cache[V]{
+		name:     name,
+		capacity: capacity,
+		ttl:      ttl,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element, capacity),
+		stop:     make(chan struct{}),
+	}
+}
+
+// StartCleanup 每隔 interval 删除过期的 key, 不然不再访问的 key 会一直占着内存直到被淘汰.
+// 调用 Close 停止
+func (c *Cache[V]) StartCleanup(interval time.Duration) *Cache[V] {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+				c.DeleteExpired()
+			}
+		}
+	}()
+	return c
+}
+
+// Close 停止定期删除过期的 key, 缓存本身仍然可以使用
+func (c *Cache[V]) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.stop)
+	})
+	return nil
+}
+
+func (c *Cache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var zero V
+	elem, ok := c.items[key]
+	if !ok {
+		requestCounter.WithLabelValues(c.name, "miss").Inc()
+		return zero, false
+	}
+	e := elem.Value.(*entry[V])
+	if time.Now().After(e.ddl) {
+		c.removeElement(elem)
+		requestCounter.WithLabelValues(c.name, "miss").Inc()
+		return zero, false
+	}
+	c.ll.MoveToFront(elem)
+	requestCounter.WithLabelValues(c.name, "hit").Inc()
+	return e.val, true
+}
+
+func (c *Cache[V]) Set(key string, val V) {
+	c.SetWithTTL(key, val, c.ttl)
+}
+
+func (c *Cache[V]) SetWithTTL(key string, val V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ddl := time.Now().Add(ttl)
+	if elem, ok := c.items[key]; ok {
+		e := elem.Value.(*entry[V])
+		e.val, e.ddl = val, ddl
+		c.ll.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&entry[V]{key: key, val: val, ddl: ddl})
+	for c.capacity > 0 && c.ll.Len() > c.capacity {
+		c.removeElement(c.ll.Back())
+		evictionCounter.WithLabelValues(c.name).Inc()
+	}
+	sizeGauge.WithLabelValues(c.name).Set(float64(c.ll.Len()))
+}
+
+func (c *Cache[V]) Delete(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		if elem, ok := c.items[key]; ok {
+			c.removeElement(elem)
+		}
+	}
+}
+
+// DeleteExpired 删除所有过期的 key, 返回删除的数量
+func (c *Cache[V]) DeleteExpired() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	cnt := 0
+	for elem := c.ll.Back(); elem != nil; {
+		prev := elem.Prev()
+		if now.After(elem.Value.(*entry[V]).ddl) {
+			c.removeElement(elem)
+			cnt++
+		}
+		elem = prev
+	}
+	return cnt
+}
+
+func (c *Cache[V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *Cache[V]) removeElement(elem *list.Element) {
+	c.ll.Remove(elem)
+	delete(c.items, elem.Value.(*entry[V]).key)
+	sizeGauge.WithLabelValues(c.name).Set(float64(c.ll.Len()))
+}
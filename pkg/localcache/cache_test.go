@@ -0,0 +1,74 @@
+package localcache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCache_Evict(t *testing.T) {
+	c := New[int]("test_evict", 2, time.Minute)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	// 访问 a 之后, 最久没有访问的是 b
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+	c.Set("c", 3)
+
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+	val, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, val)
+	val, ok = c.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, 3, val)
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestCache_Expire(t *testing.T) {
+	c := New[string]("test_expire", 10, time.Minute)
+	c.SetWithTTL("a", "a", time.Millisecond)
+	c.Set("b", "b")
+	time.Sleep(time.Millisecond * 5)
+
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 1, c.Len())
+
+	c.SetWithTTL("c", "c", time.Millisecond)
+	time.Sleep(time.Millisecond * 5)
+	assert.Equal(t, 1, c.DeleteExpired())
+	_, ok = c.Get("b")
+	assert.True(t, ok)
+}
+
+func TestCache_Update(t *testing.T) {
+	c := New[int]("test_update", 10, time.Minute)
+	c.Set("a", 1)
+	c.Set("a", 2)
+	val, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 2, val)
+	assert.Equal(t, 1, c.Len())
+
+	c.Delete("a", "not_exist")
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+}
+
+func TestCache_StartCleanup(t *testing.T) {
+	c := New[string]("test_cleanup", 10, time.Millisecond).StartCleanup(time.Millisecond * 5)
+	defer c.Close()
+	c.Set("a", "a")
+	c.SetWithTTL("b", "b", time.Minute)
+
+	// 不访问也会被删除
+	assert.Eventually(t, func() bool {
+		return c.Len() == 1
+	}, time.Second, time.Millisecond*5)
+	val, ok := c.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, "b", val)
+	assert.NoError(t, c.Close())
+}
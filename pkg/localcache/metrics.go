@@ -0,0 +1,23 @@
+package localcache
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	// requestCounter 命中率 = hit / (hit + miss)
+	requestCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "localcache_requests_total",
+		Help: "本地缓存查询次数, result 为 hit, miss",
+	}, []string{"cache", "result"})
+	evictionCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "localcache_evictions_total",
+		Help: "超过容量被淘汰的 key 的数量",
+	}, []string{"cache"})
+	sizeGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "localcache_size",
+		Help: "本地缓存当前的 key 的数量",
+	}, []string{"cache"})
+)
+
+func init() {
+	prometheus.MustRegister(requestCounter, evictionCounter, sizeGauge)
+}
@@ -0,0 +1,86 @@
+package objstore
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocalStore(t.TempDir(), "http://localhost/objects", []byte("secret"))
+	require.NoError(t, err)
+
+	err = store.Put(ctx, "article/1", []byte("hello"), "text/plain")
+	require.NoError(t, err)
+	data, err := store.Get(ctx, "article/1")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	err = store.Delete(ctx, "article/1")
+	require.NoError(t, err)
+	_, err = store.Get(ctx, "article/1")
+	assert.Equal(t, ErrObjectNotFound, err)
+	// 重复删除不报错
+	assert.NoError(t, store.Delete(ctx, "article/1"))
+
+	assert.Error(t, store.Put(ctx, "../escape", []byte("x"), "text/plain"))
+}
+
+func TestLocalStore_Presign(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocalStore(t.TempDir(), "http://localhost/objects", []byte("secret"))
+	require.NoError(t, err)
+	require.NoError(t, store.Put(ctx, "media/a.txt", []byte("content"), "text/plain"))
+	handler := http.StripPrefix("/objects", store)
+
+	testCases := []struct {
+		name     string
+		expire   time.Duration
+		tamper   func(u string) string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "有效链接",
+			expire:   time.Minute,
+			tamper:   func(u string) string { return u },
+			wantCode: http.StatusOK,
+			wantBody: "content",
+		},
+		{
+			name:     "过期",
+			expire:   -time.Minute,
+			tamper:   func(u string) string { return u },
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:   "篡改 key",
+			expire: time.Minute,
+			tamper: func(u string) string {
+				return strings.Replace(u, "a.txt", "b.txt", 1)
+			},
+			wantCode: http.StatusForbidden,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := store.Presign(ctx, "media/a.txt", tc.expire)
+			require.NoError(t, err)
+			req := httptest.NewRequest(http.MethodGet, tc.tamper(u), nil)
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			if tc.wantBody != "" {
+				body, _ := io.ReadAll(recorder.Body)
+				assert.Equal(t, tc.wantBody, string(body))
+			}
+		})
+	}
+}
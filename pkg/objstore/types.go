@@ -0,0 +1,21 @@
+package objstore
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrObjectNotFound 对象不存在
+var ErrObjectNotFound = errors.New("对象不存在")
+
+// ObjectStore 对象存储, key 是对象在存储里的路径, 比如 article/1
+type ObjectStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get 对象不存在时返回 ErrObjectNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete 对象不存在时不返回错误
+	Delete(ctx context.Context, key string) error
+	// Presign 生成一个有效期为 expire 的下载链接, 不需要其他凭证就能访问
+	Presign(ctx context.Context, key string, expire time.Duration) (string, error)
+}
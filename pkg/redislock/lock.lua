@@ -0,0 +1,22 @@
+-- 锁
+local key = KEYS[1]
+-- fencing token 计数器
+local tokenKey = KEYS[2]
+-- 持有者的唯一标识
+local val = ARGV[1]
+-- 过期时间, 毫秒
+local expiration = ARGV[2]
+
+local cur = redis.call("GET", key)
+if cur == false then
+    redis.call("SET", key, val, "PX", expiration)
+    -- 每次加锁成功, token 单调递增
+    return redis.call("INCR", tokenKey)
+elseif cur == val then
+    -- 上一次加锁其实成功了, 只是超时没收到响应
+    redis.call("PEXPIRE", key, expiration)
+    return tonumber(redis.call("GET", tokenKey))
+else
+    -- 锁被别人持有
+    return 0
+end
@@ -0,0 +1,198 @@
+package redislock
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	//go:embed lock.lua
+	luaLock string
+	//go:embed refresh.lua
+	luaRefresh string
+	//go:embed unlock.lua
+	luaUnlock string
+)
+
+var (
+	ErrFailedToPreemptLock = errors.New("redislock: 抢锁失败")
+	// ErrLockNotHold 锁已经过期, 或者被别人持有
+	ErrLockNotHold = errors.New("redislock: 未持有锁")
+)
+
+// Client 基于 Redis 的分布式锁.
+// 每次加锁成功会返回一个单调递增的 fencing token,
+// 下游存储可以拒绝 token 比上一次更小的写入, 避免锁过期之后旧的持有者覆盖数据.
+// 锁和 token 计数器是两个 key, Redis 集群下需要用 hash tag 保证它们在同一个 slot, 比如 "{job}:ranking".
+type Client struct {
+	client redis.Cmdable
+}
+
+func NewClient(client redis.Cmdable) *Client {
+	return &Client{
+		client: client,
+	}
+}
+
+// TryLock 只尝试一次, 锁被别人持有时返回 ErrFailedToPreemptLock
+func (c *Client) TryLock(ctx context.Context, key string, expiration time.Duration) (*Lock, error) {
+	value := uuid.NewString()
+	token, err := c.lock(ctx, key, value, expiration)
+	if err != nil {
+		return nil, err
+	}
+	if token == 0 {
+		return nil, ErrFailedToPreemptLock
+	}
+	return newLock(c.client, key, value, expiration, token), nil
+}
+
+// Lock 抢锁失败时按照 retry 重试, timeout 是每一次加锁请求的超时时间
+func (c *Client) Lock(ctx context.Context, key string, expiration time.Duration,
+	retry RetryStrategy, timeout time.Duration) (*Lock, error) {
+	value := uuid.NewString()
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	for {
+		lctx, cancel := context.WithTimeout(ctx, timeout)
+		token, err := c.lock(lctx, key, value, expiration)
+		cancel()
+		if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			return nil, err
+		}
+		if token > 0 {
+			return newLock(c.client, key, value, expiration, token), nil
+		}
+		interval, ok := retry.Next()
+		if !ok {
+			if err != nil {
+				return nil, err
+			}
+			return nil, ErrFailedToPreemptLock
+		}
+		if timer == nil {
+			timer = time.NewTimer(interval)
+		} else {
+			timer.Reset(interval)
+		}
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (c *Client) lock(ctx context.Context, key, value string, expiration time.Duration) (int64, error) {
+	return c.client.Eval(ctx, luaLock, []string{key, tokenKey(key)},
+		value, expiration.Milliseconds()).Int64()
+}
+
+func tokenKey(key string) string {
+	return key + ":fencing"
+}
+
+type Lock struct {
+	client     redis.Cmdable
+	key        string
+	value      string
+	expiration time.Duration
+	token      int64
+
+	unlockOnce sync.Once
+	unlocked   chan struct{}
+}
+
+func newLock(client redis.Cmdable, key, value string, expiration time.Duration, token int64) *Lock {
+	return &Lock{
+		client:     client,
+		key:        key,
+		value:      value,
+		expiration: expiration,
+		token:      token,
+		unlocked:   make(chan struct{}),
+	}
+}
+
+func (l *Lock) Key() string {
+	return l.key
+}
+
+// Token fencing token, 同一个 key 每次加锁成功都比上一次大
+func (l *Lock) Token() int64 {
+	return l.token
+}
+
+// Refresh 续约, 把过期时间重置为加锁时的 expiration
+func (l *Lock) Refresh(ctx context.Context) error {
+	res, err := l.client.Eval(ctx, luaRefresh, []string{l.key},
+		l.value, l.expiration.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if res != 1 {
+		return ErrLockNotHold
+	}
+	return nil
+}
+
+// AutoRefresh 每隔 interval 续约一次, timeout 是每次续约的超时时间.
+// 会一直阻塞, 直到 Unlock 被调用(返回 nil) 或者续约失败(返回 error), 一般在单独的 goroutine 里调用.
+// 续约超时会立刻重试, 直到锁过期.
+func (l *Lock) AutoRefresh(interval time.Duration, timeout time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	lastRefresh := time.Now()
+	for {
+		select {
+		case <-l.unlocked:
+			return nil
+		case <-ticker.C:
+		}
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			err := l.Refresh(ctx)
+			cancel()
+			if err == nil {
+				lastRefresh = time.Now()
+				break
+			}
+			if !errors.Is(err, context.DeadlineExceeded) {
+				return err
+			}
+			if time.Since(lastRefresh) >= l.expiration {
+				return ErrLockNotHold
+			}
+			select {
+			case <-l.unlocked:
+				return nil
+			default:
+			}
+		}
+	}
+}
+
+// Unlock 释放锁, 同时结束 AutoRefresh
+func (l *Lock) Unlock(ctx context.Context) error {
+	l.unlockOnce.Do(func() {
+		close(l.unlocked)
+	})
+	res, err := l.client.Eval(ctx, luaUnlock, []string{l.key}, l.value).Int64()
+	if err != nil {
+		return err
+	}
+	if res != 1 {
+		return ErrLockNotHold
+	}
+	return nil
+}
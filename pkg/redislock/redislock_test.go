@@ -0,0 +1,135 @@
+package redislock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T) (*miniredis.Miniredis, *Client) {
+	mr := miniredis.RunT(t)
+	return mr, NewClient(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+}
+
+func TestClient_TryLock(t *testing.T) {
+	testCases := []struct {
+		name   string
+		before func(t *testing.T, mr *miniredis.Miniredis)
+
+		wantToken int64
+		wantErr   error
+	}{
+		{
+			name:      "加锁成功",
+			before:    func(t *testing.T, mr *miniredis.Miniredis) {},
+			wantToken: 1,
+		},
+		{
+			name: "token 单调递增",
+			before: func(t *testing.T, mr *miniredis.Miniredis) {
+				require.NoError(t, mr.Set("key1:fencing", "10"))
+			},
+			wantToken: 11,
+		},
+		{
+			name: "锁被别人持有",
+			before: func(t *testing.T, mr *miniredis.Miniredis) {
+				require.NoError(t, mr.Set("key1", "other"))
+			},
+			wantErr: ErrFailedToPreemptLock,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mr, c := newTestClient(t)
+			tc.before(t, mr)
+			l, err := c.TryLock(context.Background(), "key1", time.Minute)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantToken, l.Token())
+			assert.Equal(t, time.Minute, mr.TTL("key1"))
+		})
+	}
+}
+
+func TestClient_Lock(t *testing.T) {
+	mr, c := newTestClient(t)
+	first, err := c.TryLock(context.Background(), "key1", time.Minute)
+	require.NoError(t, err)
+
+	// 重试次数用完还没抢到
+	_, err = c.Lock(context.Background(), "key1", time.Minute,
+		&FixedIntervalRetry{Interval: time.Millisecond * 10, Max: 2}, time.Second)
+	assert.Equal(t, ErrFailedToPreemptLock, err)
+
+	// 重试期间锁被释放
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		_ = first.Unlock(context.Background())
+	}()
+	second, err := c.Lock(context.Background(), "key1", time.Minute,
+		&ExponentialBackoffRetry{InitialInterval: time.Millisecond * 10, MaxInterval: time.Millisecond * 40, Max: 10},
+		time.Second)
+	require.NoError(t, err)
+	assert.Greater(t, second.Token(), first.Token())
+	val, err := mr.Get("key1")
+	require.NoError(t, err)
+	assert.Equal(t, second.value, val)
+}
+
+func TestLock_RefreshAndUnlock(t *testing.T) {
+	mr, c := newTestClient(t)
+	l, err := c.TryLock(context.Background(), "key1", time.Minute)
+	require.NoError(t, err)
+
+	mr.FastForward(time.Second * 30)
+	require.NoError(t, l.Refresh(context.Background()))
+	assert.Equal(t, time.Minute, mr.TTL("key1"))
+
+	// 锁过期之后被别人拿到
+	mr.FastForward(time.Minute)
+	require.NoError(t, mr.Set("key1", "other"))
+	assert.Equal(t, ErrLockNotHold, l.Refresh(context.Background()))
+	assert.Equal(t, ErrLockNotHold, l.Unlock(context.Background()))
+	val, err := mr.Get("key1")
+	require.NoError(t, err)
+	assert.Equal(t, "other", val)
+}
+
+func TestLock_AutoRefresh(t *testing.T) {
+	mr, c := newTestClient(t)
+	l, err := c.TryLock(context.Background(), "key1", time.Second)
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- l.AutoRefresh(time.Millisecond*20, time.Second)
+	}()
+	mr.FastForward(time.Millisecond * 800)
+	time.Sleep(time.Millisecond * 100)
+	assert.Equal(t, time.Second, mr.TTL("key1"))
+
+	require.NoError(t, l.Unlock(context.Background()))
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Unlock 之后 AutoRefresh 没有退出")
+	}
+	assert.False(t, mr.Exists("key1"))
+}
+
+func TestLock_AutoRefreshLost(t *testing.T) {
+	mr, c := newTestClient(t)
+	l, err := c.TryLock(context.Background(), "key1", time.Second)
+	require.NoError(t, err)
+	require.NoError(t, mr.Set("key1", "other"))
+	assert.Equal(t, ErrLockNotHold, l.AutoRefresh(time.Millisecond*10, time.Second))
+}
@@ -0,0 +1,46 @@
+package redislock
+
+import "time"
+
+// RetryStrategy 抢锁失败之后的重试策略
+type RetryStrategy interface {
+	// Next 返回下一次重试前等待多久, 第二个返回值为 false 代表不再重试
+	Next() (time.Duration, bool)
+}
+
+// FixedIntervalRetry 等间隔重试, Max 为最大重试次数
+type FixedIntervalRetry struct {
+	Interval time.Duration
+	Max      int
+	cnt      int
+}
+
+func (r *FixedIntervalRetry) Next() (time.Duration, bool) {
+	r.cnt++
+	return r.Interval, r.cnt <= r.Max
+}
+
+// ExponentialBackoffRetry 指数退避重试, 每次间隔翻倍, 最多等待 MaxInterval
+type ExponentialBackoffRetry struct {
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Max             int
+	cnt             int
+	interval        time.Duration
+}
+
+func (r *ExponentialBackoffRetry) Next() (time.Duration, bool) {
+	r.cnt++
+	if r.cnt > r.Max {
+		return 0, false
+	}
+	if r.interval == 0 {
+		r.interval = r.InitialInterval
+	} else {
+		r.interval *= 2
+	}
+	if r.MaxInterval > 0 && r.interval > r.MaxInterval {
+		r.interval = r.MaxInterval
+	}
+	return r.interval, true
+}
@@ -0,0 +1,6 @@
+-- 只有锁还是自己的, 才能释放
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+    return redis.call("DEL", KEYS[1])
+else
+    return 0
+end
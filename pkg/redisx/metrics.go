@@ -0,0 +1,61 @@
+package redisx
+
+import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"net"
+	"time"
+)
+
+// MetricsHook 统计 redis 命令的执行时间, 按照命令名分组.
+// key 不存在(redis.Nil)不算失败.
+type MetricsHook struct {
+	vector *prometheus.HistogramVec
+}
+
+func NewMetricsHook(namespace, subsystem string) *MetricsHook {
+	vector := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "redis_command_duration_seconds",
+		Help:      "redis 命令执行时间",
+		Buckets:   []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25},
+	}, []string{"cmd", "status"})
+	prometheus.MustRegister(vector)
+	return &MetricsHook{
+		vector: vector,
+	}
+}
+
+func (h *MetricsHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (h *MetricsHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		h.vector.WithLabelValues(cmd.Name(), status(err)).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+func (h *MetricsHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		h.vector.WithLabelValues("pipeline", status(err)).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+func status(err error) string {
+	if err == nil || errors.Is(err, redis.Nil) {
+		return "success"
+	}
+	return "failed"
+}
@@ -0,0 +1,122 @@
+package saramax
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/IBM/sarama"
+	"time"
+)
+
+// BatchHandler 攒批消费, 凑够 batchSize 条或者等待超过 batchDuration 就处理一批.
+// 整批处理成功后才提交 offset, 失败时整批重试, 仍然失败则逐条转发到重试/死信 topic.
+type BatchHandler[T any] struct {
+	fn            func(msgs []*sarama.ConsumerMessage, ts []T) error
+	batchSize     int
+	batchDuration time.Duration
+	retry         Retry
+}
+
+func NewBatchHandler[T any](fn func(msgs []*sarama.ConsumerMessage, ts []T) error) *BatchHandler[T] {
+	return &BatchHandler[T]{
+		fn:            fn,
+		batchSize:     10,
+		batchDuration: time.Second,
+		retry:         DefaultRetry(),
+	}
+}
+
+func (h *BatchHandler[T]) SetBatchSize(size int) *BatchHandler[T] {
+	h.batchSize = size
+	return h
+}
+
+func (h *BatchHandler[T]) SetBatchDuration(duration time.Duration) *BatchHandler[T] {
+	h.batchDuration = duration
+	return h
+}
+
+func (h *BatchHandler[T]) SetRetry(retry Retry) *BatchHandler[T] {
+	h.retry = retry
+	return h
+}
+
+func (h *BatchHandler[T]) Setup(session sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (h *BatchHandler[T]) Cleanup(session sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (h *BatchHandler[T]) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	msgs := claim.Messages()
+	for {
+		batch := make([]*sarama.ConsumerMessage, 0, h.batchSize)
+		ts := make([]T, 0, h.batchSize)
+		// 反序列化失败的消息, 不参与批处理, 直接进入死信 topic
+		var bad []*sarama.ConsumerMessage
+		closed := false
+		ctx, cancel := context.WithTimeout(session.Context(), h.batchDuration)
+	collect:
+		for len(batch)+len(bad) < h.batchSize {
+			select {
+			case <-ctx.Done():
+				break collect
+			case msg, ok := <-msgs:
+				if !ok {
+					closed = true
+					break collect
+				}
+				var t T
+				err := json.Unmarshal(msg.Value, &t)
+				if err != nil {
+					logFailure(msg, "反序列化消息失败", err)
+					observeConsumed(msg, err)
+					bad = append(bad, msg)
+					continue
+				}
+				batch = append(batch, msg)
+				ts = append(ts, t)
+			}
+		}
+		cancel()
+		for _, msg := range bad {
+			if er := h.retry.deadLetter(msg); er != nil {
+				logFailure(msg, "转发失败消息失败", er)
+				return er
+			}
+		}
+		var failed []*sarama.ConsumerMessage
+		if len(batch) > 0 {
+			_, span := startBatchSpan(session.Context(), batch[0].Topic, batch)
+			err := h.retry.do(session.Context(), func() error {
+				return h.fn(batch, ts)
+			})
+			if err != nil {
+				for _, msg := range batch {
+					logFailure(msg, "批量处理消息失败", err)
+				}
+				failed = append(failed, batch...)
+			}
+			for _, msg := range batch {
+				observeConsumed(msg, err)
+			}
+			EndSpan(span, err)
+		}
+		for _, msg := range failed {
+			if er := h.retry.forward(msg); er != nil {
+				logFailure(msg, "转发失败消息失败", er)
+				return er
+			}
+		}
+		for _, msg := range batch {
+			session.MarkMessage(msg, "")
+		}
+		for _, msg := range bad {
+			session.MarkMessage(msg, "")
+		}
+		if closed || session.Context().Err() != nil {
+			return nil
+		}
+	}
+}
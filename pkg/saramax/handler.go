@@ -0,0 +1,65 @@
+package saramax
+
+import (
+	"encoding/json"
+	"github.com/IBM/sarama"
+)
+
+// Handler 逐条消费, 消息会被 JSON 反序列化成 T.
+// 只有处理成功, 或者失败后已经转发到重试/死信 topic, 才会提交 offset.
+type Handler[T any] struct {
+	fn    func(msg *sarama.ConsumerMessage, t T) error
+	retry Retry
+}
+
+func NewHandler[T any](fn func(msg *sarama.ConsumerMessage, t T) error) *Handler[T] {
+	return &Handler[T]{
+		fn:    fn,
+		retry: DefaultRetry(),
+	}
+}
+
+func (h *Handler[T]) SetRetry(retry Retry) *Handler[T] {
+	h.retry = retry
+	return h
+}
+
+func (h *Handler[T]) Setup(session sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (h *Handler[T]) Cleanup(session sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (h *Handler[T]) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		_, span := startConsumerSpan(session.Context(), msg)
+		var t T
+		forward := h.retry.forward
+		err := json.Unmarshal(msg.Value, &t)
+		if err != nil {
+			// 反序列化失败重试也没用
+			logFailure(msg, "反序列化消息失败", err)
+			forward = h.retry.deadLetter
+		} else {
+			err = h.retry.do(session.Context(), func() error {
+				return h.fn(msg, t)
+			})
+			if err != nil {
+				logFailure(msg, "处理消息失败", err)
+			}
+		}
+		observeConsumed(msg, err)
+		EndSpan(span, err)
+		if err != nil {
+			if er := forward(msg); er != nil {
+				// 不提交 offset, 返回之后会从上一次提交的位置重新消费
+				logFailure(msg, "转发失败消息失败", er)
+				return er
+			}
+		}
+		session.MarkMessage(msg, "")
+	}
+	return nil
+}
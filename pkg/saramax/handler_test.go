@@ -0,0 +1,216 @@
+package saramax
+
+import (
+	"context"
+	"errors"
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type testEvent struct {
+	Id int64 `json:"id"`
+}
+
+func TestHandler_ConsumeClaim(t *testing.T) {
+	testCases := []struct {
+		name  string
+		msgs  []*sarama.ConsumerMessage
+		fn    func(msg *sarama.ConsumerMessage, evt testEvent) error
+		retry func(t *testing.T) Retry
+
+		wantMarked []int64
+		wantErr    error
+	}{
+		{
+			name: "处理成功",
+			msgs: []*sarama.ConsumerMessage{
+				{Topic: "test", Offset: 1, Value: []byte(`{"id":1}`)},
+				{Topic: "test", Offset: 2, Value: []byte(`{"id":2}`)},
+			},
+			fn: func(msg *sarama.ConsumerMessage, evt testEvent) error {
+				return nil
+			},
+			retry: func(t *testing.T) Retry {
+				return Retry{}
+			},
+			wantMarked: []int64{1, 2},
+		},
+		{
+			name: "重试之后成功",
+			msgs: []*sarama.ConsumerMessage{
+				{Topic: "test", Offset: 1, Value: []byte(`{"id":1}`)},
+			},
+			fn: func() func(msg *sarama.ConsumerMessage, evt testEvent) error {
+				cnt := 0
+				return func(msg *sarama.ConsumerMessage, evt testEvent) error {
+					cnt++
+					if cnt < 3 {
+						return errors.New("mock error")
+					}
+					return nil
+				}
+			}(),
+			retry: func(t *testing.T) Retry {
+				return Retry{MaxRetries: 3, InitialInterval: time.Millisecond}
+			},
+			wantMarked: []int64{1},
+		},
+		{
+			name: "重试失败转发到重试 topic",
+			msgs: []*sarama.ConsumerMessage{
+				{Topic: "test", Offset: 1, Value: []byte(`{"id":1}`)},
+			},
+			fn: func(msg *sarama.ConsumerMessage, evt testEvent) error {
+				return errors.New("mock error")
+			},
+			retry: func(t *testing.T) Retry {
+				producer := mocks.NewSyncProducer(t, nil)
+				producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+					assert.Equal(t, "test_retry", msg.Topic)
+					assert.Equal(t, HeaderOriginTopic, string(msg.Headers[0].Key))
+					assert.Equal(t, "test", string(msg.Headers[0].Value))
+					return nil
+				})
+				return Retry{
+					MaxRetries:      1,
+					InitialInterval: time.Millisecond,
+					Producer:        producer,
+					RetryTopic:      "test_retry",
+					DeadLetterTopic: "test_dlq",
+				}
+			},
+			wantMarked: []int64{1},
+		},
+		{
+			name: "反序列化失败直接进入死信 topic",
+			msgs: []*sarama.ConsumerMessage{
+				{Topic: "test", Offset: 1, Value: []byte(`abc`)},
+			},
+			fn: func(msg *sarama.ConsumerMessage, evt testEvent) error {
+				t.Fatal("不应该被调用")
+				return nil
+			},
+			retry: func(t *testing.T) Retry {
+				producer := mocks.NewSyncProducer(t, nil)
+				producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+					assert.Equal(t, "test_dlq", msg.Topic)
+					return nil
+				})
+				return Retry{
+					Producer:        producer,
+					RetryTopic:      "test_retry",
+					DeadLetterTopic: "test_dlq",
+				}
+			},
+			wantMarked: []int64{1},
+		},
+		{
+			name: "转发失败不提交",
+			msgs: []*sarama.ConsumerMessage{
+				{Topic: "test", Offset: 1, Value: []byte(`{"id":1}`)},
+			},
+			fn: func(msg *sarama.ConsumerMessage, evt testEvent) error {
+				return errors.New("mock error")
+			},
+			retry: func(t *testing.T) Retry {
+				producer := mocks.NewSyncProducer(t, nil)
+				producer.ExpectSendMessageAndFail(sarama.ErrOutOfBrokers)
+				return Retry{
+					Producer:        producer,
+					DeadLetterTopic: "test_dlq",
+				}
+			},
+			wantErr: sarama.ErrOutOfBrokers,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			session := &fakeSession{ctx: context.Background()}
+			claim := newFakeClaim(tc.msgs)
+			h := NewHandler[testEvent](tc.fn).SetRetry(tc.retry(t))
+			err := h.ConsumeClaim(session, claim)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantMarked, session.marked)
+		})
+	}
+}
+
+func TestBatchHandler_ConsumeClaim(t *testing.T) {
+	session := &fakeSession{ctx: context.Background()}
+	claim := newFakeClaim([]*sarama.ConsumerMessage{
+		{Topic: "test", Offset: 1, Value: []byte(`{"id":1}`)},
+		{Topic: "test", Offset: 2, Value: []byte(`{"id":2}`)},
+		{Topic: "test", Offset: 3, Value: []byte(`{"id":3}`)},
+	})
+	var batches [][]testEvent
+	h := NewBatchHandler[testEvent](func(msgs []*sarama.ConsumerMessage, ts []testEvent) error {
+		batches = append(batches, ts)
+		return nil
+	}).SetBatchSize(2).SetBatchDuration(time.Millisecond * 100)
+	err := h.ConsumeClaim(session, claim)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]testEvent{{{Id: 1}, {Id: 2}}, {{Id: 3}}}, batches)
+	assert.Equal(t, []int64{1, 2, 3}, session.marked)
+}
+
+func TestBatchHandler_DecodeFailed(t *testing.T) {
+	session := &fakeSession{ctx: context.Background()}
+	claim := newFakeClaim([]*sarama.ConsumerMessage{
+		{Topic: "test", Offset: 1, Value: []byte(`{"id":1}`)},
+		{Topic: "test", Offset: 2, Value: []byte(`abc`)},
+	})
+	producer := mocks.NewSyncProducer(t, nil)
+	// 来自原 topic 也不进入重试 topic
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		assert.Equal(t, "test_dlq", msg.Topic)
+		return nil
+	})
+	var batches [][]testEvent
+	h := NewBatchHandler[testEvent](func(msgs []*sarama.ConsumerMessage, ts []testEvent) error {
+		batches = append(batches, ts)
+		return nil
+	}).SetBatchSize(2).SetRetry(Retry{
+		Producer:        producer,
+		RetryTopic:      "test_retry",
+		DeadLetterTopic: "test_dlq",
+	})
+	err := h.ConsumeClaim(session, claim)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]testEvent{{{Id: 1}}}, batches)
+	assert.Equal(t, []int64{1, 2}, session.marked)
+}
+
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	ctx    context.Context
+	marked []int64
+}
+
+func (s *fakeSession) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.marked = append(s.marked, msg.Offset)
+}
+
+type fakeClaim struct {
+	sarama.ConsumerGroupClaim
+	msgs chan *sarama.ConsumerMessage
+}
+
+func newFakeClaim(msgs []*sarama.ConsumerMessage) *fakeClaim {
+	ch := make(chan *sarama.ConsumerMessage, len(msgs))
+	for _, msg := range msgs {
+		ch <- msg
+	}
+	close(ch)
+	return &fakeClaim{msgs: ch}
+}
+
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage {
+	return c.msgs
+}
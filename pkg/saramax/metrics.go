@@ -0,0 +1,57 @@
+package saramax
+
+import (
+	"github.com/IBM/sarama"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	consumedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "saramax_consumed_messages_total",
+		Help: "消费的消息数, status 为 success, failed(重试后仍失败)",
+	}, []string{"topic", "status"})
+	producedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "saramax_produced_messages_total",
+		Help: "发送的消息数",
+	}, []string{"topic", "status"})
+)
+
+func init() {
+	prometheus.MustRegister(consumedCounter, producedCounter)
+}
+
+func observeConsumed(msg *sarama.ConsumerMessage, err error) {
+	consumedCounter.WithLabelValues(msg.Topic, status(err)).Inc()
+}
+
+func status(err error) string {
+	if err != nil {
+		return "failed"
+	}
+	return "success"
+}
+
+// MetricsSyncProducer 装饰 sarama.SyncProducer, 按 topic 统计发送成功和失败的消息数
+type MetricsSyncProducer struct {
+	sarama.SyncProducer
+}
+
+func NewMetricsSyncProducer(producer sarama.SyncProducer) *MetricsSyncProducer {
+	return &MetricsSyncProducer{
+		SyncProducer: producer,
+	}
+}
+
+func (p *MetricsSyncProducer) SendMessage(msg *sarama.ProducerMessage) (partition int32, offset int64, err error) {
+	partition, offset, err = p.SyncProducer.SendMessage(msg)
+	producedCounter.WithLabelValues(msg.Topic, status(err)).Inc()
+	return
+}
+
+func (p *MetricsSyncProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	err := p.SyncProducer.SendMessages(msgs)
+	for _, msg := range msgs {
+		producedCounter.WithLabelValues(msg.Topic, status(err)).Inc()
+	}
+	return err
+}
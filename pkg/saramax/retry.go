@@ -0,0 +1,110 @@
+package saramax
+
+import (
+	"context"
+	"github.com/IBM/sarama"
+	"go.uber.org/zap"
+	"time"
+)
+
+// HeaderOriginTopic 转发到重试 topic 或死信 topic 时, 记录消息最初来自哪个 topic
+const HeaderOriginTopic = "x-origin-topic"
+
+// Retry 消费失败后的处理策略.
+// 先在本地按指数退避重试 MaxRetries 次, 仍然失败则转发:
+// 来自原 topic 的消息转发到 RetryTopic, 来自 RetryTopic 的消息转发到 DeadLetterTopic.
+// RetryTopic 为空时直接转发到 DeadLetterTopic, 两者都为空时只记录日志.
+type Retry struct {
+	MaxRetries int
+	// 第一次重试前等待多久, 之后每次翻倍
+	InitialInterval time.Duration
+	// 最大等待间隔
+	MaxInterval time.Duration
+
+	Producer        sarama.SyncProducer
+	RetryTopic      string
+	DeadLetterTopic string
+}
+
+// DefaultRetry 本地重试 3 次, 间隔 100ms, 200ms, 400ms, 不转发
+func DefaultRetry() Retry {
+	return Retry{
+		MaxRetries:      3,
+		InitialInterval: time.Millisecond * 100,
+		MaxInterval:     time.Second * 5,
+	}
+}
+
+// do 执行 fn, 失败时按退避策略重试, ctx 结束时放弃重试
+func (r Retry) do(ctx context.Context, fn func() error) error {
+	err := fn()
+	interval := r.InitialInterval
+	for i := 0; i < r.MaxRetries && err != nil; i++ {
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(interval):
+		}
+		interval *= 2
+		if r.MaxInterval > 0 && interval > r.MaxInterval {
+			interval = r.MaxInterval
+		}
+		err = fn()
+	}
+	return err
+}
+
+// forward 把处理失败的消息转发到重试 topic 或死信 topic
+func (r Retry) forward(msg *sarama.ConsumerMessage) error {
+	topic := r.RetryTopic
+	if topic == "" || msg.Topic == r.RetryTopic {
+		topic = r.DeadLetterTopic
+	}
+	return r.send(msg, topic)
+}
+
+// deadLetter 重试也没用的消息直接转发到死信 topic, 比如反序列化失败
+func (r Retry) deadLetter(msg *sarama.ConsumerMessage) error {
+	return r.send(msg, r.DeadLetterTopic)
+}
+
+func (r Retry) send(msg *sarama.ConsumerMessage, topic string) error {
+	if topic == "" || r.Producer == nil {
+		return nil
+	}
+	origin := msg.Topic
+	headers := make([]sarama.RecordHeader, 0, len(msg.Headers)+1)
+	for _, h := range msg.Headers {
+		if string(h.Key) == HeaderOriginTopic {
+			origin = string(h.Value)
+			continue
+		}
+		headers = append(headers, *h)
+	}
+	headers = append(headers, sarama.RecordHeader{
+		Key:   []byte(HeaderOriginTopic),
+		Value: []byte(origin),
+	})
+	_, _, err := r.Producer.SendMessage(&sarama.ProducerMessage{
+		Topic:   topic,
+		Key:     sarama.ByteEncoder(msg.Key),
+		Value:   sarama.ByteEncoder(msg.Value),
+		Headers: headers,
+	})
+	if err == nil {
+		zap.L().Warn("消息已转发",
+			zap.String("topic", msg.Topic),
+			zap.String("to", topic),
+			zap.Int32("partition", msg.Partition),
+			zap.Int64("offset", msg.Offset))
+	}
+	return err
+}
+
+func logFailure(msg *sarama.ConsumerMessage, logMsg string, err error) {
+	zap.L().Error(logMsg,
+		zap.String("topic", msg.Topic),
+		zap.Int32("partition", msg.Partition),
+		zap.Int64("offset", msg.Offset),
+		zap.Error(err))
+}
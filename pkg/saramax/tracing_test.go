@@ -0,0 +1,35 @@
+package saramax
+
+import (
+	"context"
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"testing"
+)
+
+func TestTracePropagation(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	tp := sdktrace.NewTracerProvider()
+	defer tp.Shutdown(context.Background())
+	otel.SetTracerProvider(tp)
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "request")
+	defer parent.End()
+	pm := &sarama.ProducerMessage{Topic: "article_read"}
+	_, span := StartProducerSpan(ctx, pm)
+	EndSpan(span, nil)
+
+	// 模拟 broker 投递给消费者
+	cm := &sarama.ConsumerMessage{Topic: pm.Topic}
+	for i := range pm.Headers {
+		cm.Headers = append(cm.Headers, &pm.Headers[i])
+	}
+	sc := trace.SpanContextFromContext(ExtractContext(context.Background(), cm))
+	assert.True(t, sc.IsValid())
+	assert.Equal(t, parent.SpanContext().TraceID(), sc.TraceID())
+	assert.Equal(t, span.SpanContext().SpanID(), sc.SpanID())
+}
@@ -0,0 +1,241 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lutcoding/redbook/pkg/redislock"
+	"github.com/redis/go-redis/v9"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+const (
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+	// StatusInterrupted 执行过程中丢失了锁或者调度器被关闭
+	StatusInterrupted = "interrupted"
+)
+
+// Job 定时任务, Run 需要响应 ctx 的取消, 丢失锁或者关闭调度器时 ctx 会被取消
+type Job interface {
+	Name() string
+	Run(ctx context.Context) error
+}
+
+// Record 任务最近一次执行的情况
+type Record struct {
+	Instance  string
+	LastRunAt time.Time
+	Duration  time.Duration
+	Status    string
+	Err       string
+}
+
+// Scheduler 分布式定时任务调度器.
+// 每个任务对应一把 Redis 锁, 多个实例之间只有抢到锁的实例会执行这个任务,
+// 持有锁期间会自动续约, 续约失败就认为丢失了锁, 停止执行并重新抢锁.
+type Scheduler struct {
+	client   redis.Cmdable
+	locker   *redislock.Client
+	instance string
+	entries  []entry
+	// 锁的过期时间, 每 1/3 过期时间续约一次
+	lockExpiration time.Duration
+	// 没抢到锁的实例多久之后再试
+	retryInterval time.Duration
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type entry struct {
+	job      Job
+	schedule cron.Schedule
+}
+
+func NewScheduler(client redis.Cmdable) *Scheduler {
+	host, _ := os.Hostname()
+	return &Scheduler{
+		client:         client,
+		locker:         redislock.NewClient(client),
+		instance:       host + ":" + uuid.NewString(),
+		lockExpiration: time.Second * 30,
+		retryInterval:  time.Second * 10,
+	}
+}
+
+func (s *Scheduler) SetLockExpiration(expiration time.Duration) *Scheduler {
+	s.lockExpiration = expiration
+	return s
+}
+
+func (s *Scheduler) SetRetryInterval(interval time.Duration) *Scheduler {
+	s.retryInterval = interval
+	return s
+}
+
+// AddJob spec 可以是标准的 cron 表达式, 比如 "*/5 * * * *",
+// 也可以是固定间隔, 比如 "@every 1m".
+// 必须在 Start 之前调用.
+func (s *Scheduler) AddJob(spec string, job Job) error {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return fmt.Errorf("任务 %s 的调度表达式不合法: %w", job.Name(), err)
+	}
+	s.entries = append(s.entries, entry{job: job, schedule: schedule})
+	return nil
+}
+
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	for _, e := range s.entries {
+		s.wg.Add(1)
+		go s.loop(ctx, e)
+	}
+}
+
+// Stop 停止调度, 等待正在执行的任务退出并释放锁
+func (s *Scheduler) Stop(ctx context.Context) error {
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// LastRecord 查询任务最近一次执行的情况, 任何实例执行的都可以查到
+func (s *Scheduler) LastRecord(ctx context.Context, name string) (Record, error) {
+	res, err := s.client.HGetAll(ctx, s.recordKey(name)).Result()
+	if err != nil {
+		return Record{}, err
+	}
+	if len(res) == 0 {
+		return Record{}, redis.Nil
+	}
+	runAt, _ := strconv.ParseInt(res["last_run_at"], 10, 64)
+	duration, _ := strconv.ParseInt(res["duration"], 10, 64)
+	return Record{
+		Instance:  res["instance"],
+		LastRunAt: time.UnixMilli(runAt),
+		Duration:  time.Duration(duration) * time.Millisecond,
+		Status:    res["status"],
+		Err:       res["err"],
+	}, nil
+}
+
+func (s *Scheduler) loop(ctx context.Context, e entry) {
+	defer s.wg.Done()
+	key := "scheduler:lock:" + e.job.Name()
+	for {
+		l, err := s.locker.TryLock(ctx, key, s.lockExpiration)
+		switch err {
+		case nil:
+			zap.L().Info("抢占任务成功", zap.String("job", e.job.Name()),
+				zap.String("instance", s.instance), zap.Int64("token", l.Token()))
+			s.hold(ctx, l, e)
+		case redislock.ErrFailedToPreemptLock:
+			// 其他实例正在执行
+		default:
+			zap.L().Error("抢占任务锁失败", zap.String("job", e.job.Name()), zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.retryInterval):
+		}
+	}
+}
+
+// hold 持有锁期间按照调度执行任务, 丢失锁或者 ctx 结束时返回
+func (s *Scheduler) hold(ctx context.Context, l *redislock.Lock, e entry) {
+	holdCtx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+		unlockCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		err := l.Unlock(unlockCtx)
+		if err != nil && err != redislock.ErrLockNotHold {
+			zap.L().Error("释放任务锁失败", zap.String("job", e.job.Name()), zap.Error(err))
+		}
+	}()
+	go func() {
+		// 续约失败就认为丢失了锁, 取消正在执行的任务
+		err := l.AutoRefresh(s.lockExpiration/3, time.Second)
+		if err != nil {
+			zap.L().Warn("任务锁续约失败", zap.String("job", e.job.Name()), zap.Error(err))
+			cancel()
+		}
+	}()
+
+	now := time.Now()
+	for {
+		timer := time.NewTimer(time.Until(e.schedule.Next(now)))
+		select {
+		case <-holdCtx.Done():
+			timer.Stop()
+			return
+		case now = <-timer.C:
+		}
+		s.run(holdCtx, e)
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, e entry) {
+	start := time.Now()
+	err := s.safeRun(ctx, e.job)
+	rec := Record{
+		Instance:  s.instance,
+		LastRunAt: start,
+		Duration:  time.Since(start),
+		Status:    StatusSuccess,
+	}
+	if err != nil {
+		rec.Status, rec.Err = StatusFailed, err.Error()
+		if ctx.Err() != nil {
+			rec.Status = StatusInterrupted
+		}
+		zap.L().Error("执行任务失败", zap.String("job", e.job.Name()),
+			zap.String("status", rec.Status), zap.Error(err))
+	}
+	recordCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = s.client.HSet(recordCtx, s.recordKey(e.job.Name()),
+		"instance", rec.Instance,
+		"last_run_at", rec.LastRunAt.UnixMilli(),
+		"duration", rec.Duration.Milliseconds(),
+		"status", rec.Status,
+		"err", rec.Err).Err()
+	if err != nil {
+		zap.L().Error("记录任务执行结果失败", zap.String("job", e.job.Name()), zap.Error(err))
+	}
+}
+
+func (s *Scheduler) safeRun(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("任务 panic: %v", r)
+		}
+	}()
+	return job.Run(ctx)
+}
+
+func (s *Scheduler) recordKey(name string) string {
+	return "scheduler:job:" + name
+}
@@ -0,0 +1,95 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countJob struct {
+	cnt atomic.Int64
+}
+
+func (j *countJob) Name() string {
+	return "count"
+}
+
+func (j *countJob) Run(ctx context.Context) error {
+	j.cnt.Add(1)
+	return nil
+}
+
+func TestScheduler_OnlyOneInstanceRuns(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+	jobs := []*countJob{{}, {}}
+	schedulers := make([]*Scheduler, len(jobs))
+	for i := range jobs {
+		schedulers[i] = NewScheduler(client).SetRetryInterval(time.Millisecond * 100)
+		require.NoError(t, schedulers[i].AddJob("@every 1s", jobs[i]))
+		schedulers[i].Start()
+	}
+	time.Sleep(time.Millisecond * 2500)
+	for _, s := range schedulers {
+		assert.NoError(t, s.Stop(context.Background()))
+	}
+
+	cnt0, cnt1 := jobs[0].cnt.Load(), jobs[1].cnt.Load()
+	assert.True(t, cnt0 == 0 || cnt1 == 0, "两个实例都执行了任务: %d, %d", cnt0, cnt1)
+	// 定时器有误差, 2.5s 内执行的次数不固定, 只确认执行过并且没有多出来的
+	assert.GreaterOrEqual(t, cnt0+cnt1, int64(1))
+	assert.LessOrEqual(t, cnt0+cnt1, int64(3))
+
+	rec, err := schedulers[0].LastRecord(context.Background(), "count")
+	require.NoError(t, err)
+	assert.Equal(t, StatusSuccess, rec.Status)
+	// 停止之后释放了锁
+	assert.False(t, mr.Exists("scheduler:lock:count"))
+}
+
+type blockJob struct {
+	started chan struct{}
+	err     chan error
+}
+
+func (j *blockJob) Name() string {
+	return "block"
+}
+
+func (j *blockJob) Run(ctx context.Context) error {
+	close(j.started)
+	<-ctx.Done()
+	j.err <- ctx.Err()
+	return ctx.Err()
+}
+
+func TestScheduler_LockLost(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	job := &blockJob{started: make(chan struct{}), err: make(chan error, 1)}
+	s := NewScheduler(client).SetLockExpiration(time.Millisecond * 300)
+	require.NoError(t, s.AddJob("@every 1s", job))
+	s.Start()
+	defer s.Stop(context.Background())
+
+	select {
+	case <-job.started:
+	case <-time.After(time.Second * 3):
+		t.Fatal("任务没有被执行")
+	}
+	// 模拟锁过期后被其他实例抢走
+	require.NoError(t, mr.Set("scheduler:lock:block", "other"))
+	select {
+	case err := <-job.err:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("丢失锁之后任务没有被取消")
+	}
+}
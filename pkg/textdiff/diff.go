@@ -0,0 +1,112 @@
+package textdiff
+
+import "strings"
+
+type Op uint8
+
+const (
+	OpEqual Op = iota
+	OpInsert
+	OpDelete
+)
+
+func (o Op) String() string {
+	switch o {
+	case OpInsert:
+		return "+"
+	case OpDelete:
+		return "-"
+	default:
+		return "="
+	}
+}
+
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// maxCells LCS 需要 len(a)*len(b) 的表, 超过之后不再逐行比较, 直接整段替换
+const maxCells = 4_000_000
+
+// Lines 按行比较 a 和 b, 返回把 a 变成 b 的编辑序列
+func Lines(a, b string) []Line {
+	as, bs := split(a), split(b)
+	// 去掉相同的前缀和后缀, 减小 LCS 表
+	prefix := 0
+	for prefix < len(as) && prefix < len(bs) && as[prefix] == bs[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(as)-prefix && suffix < len(bs)-prefix &&
+		as[len(as)-1-suffix] == bs[len(bs)-1-suffix] {
+		suffix++
+	}
+	res := make([]Line, 0, len(as)+len(bs))
+	for _, l := range as[:prefix] {
+		res = append(res, Line{Op: OpEqual, Text: l})
+	}
+	res = append(res, lcs(as[prefix:len(as)-suffix], bs[prefix:len(bs)-suffix])...)
+	for _, l := range as[len(as)-suffix:] {
+		res = append(res, Line{Op: OpEqual, Text: l})
+	}
+	return res
+}
+
+func lcs(as, bs []string) []Line {
+	res := make([]Line, 0, len(as)+len(bs))
+	if len(as)*len(bs) > maxCells {
+		for _, l := range as {
+			res = append(res, Line{Op: OpDelete, Text: l})
+		}
+		for _, l := range bs {
+			res = append(res, Line{Op: OpInsert, Text: l})
+		}
+		return res
+	}
+	// dp[i][j] 是 as[i:] 和 bs[j:] 的最长公共子序列长度
+	dp := make([][]int, len(as)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(bs)+1)
+	}
+	for i := len(as) - 1; i >= 0; i-- {
+		for j := len(bs) - 1; j >= 0; j-- {
+			if as[i] == bs[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else if dp[i+1][j] >= dp[i][j+1] {
+				dp[i][j] = dp[i+1][j]
+			} else {
+				dp[i][j] = dp[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(as) && j < len(bs) {
+		switch {
+		case as[i] == bs[j]:
+			res = append(res, Line{Op: OpEqual, Text: as[i]})
+			i++
+			j++
+		case dp[i+1][j] >= dp[i][j+1]:
+			res = append(res, Line{Op: OpDelete, Text: as[i]})
+			i++
+		default:
+			res = append(res, Line{Op: OpInsert, Text: bs[j]})
+			j++
+		}
+	}
+	for ; i < len(as); i++ {
+		res = append(res, Line{Op: OpDelete, Text: as[i]})
+	}
+	for ; j < len(bs); j++ {
+		res = append(res, Line{Op: OpInsert, Text: bs[j]})
+	}
+	return res
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
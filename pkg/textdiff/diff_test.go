@@ -0,0 +1,44 @@
+package textdiff
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	testCases := []struct {
+		name string
+		a, b string
+		want []Line
+	}{
+		{
+			name: "相同",
+			a:    "a\nb",
+			b:    "a\nb",
+			want: []Line{{OpEqual, "a"}, {OpEqual, "b"}},
+		},
+		{
+			name: "从空到有",
+			a:    "",
+			b:    "a",
+			want: []Line{{OpInsert, "a"}},
+		},
+		{
+			name: "修改中间一行",
+			a:    "a\nb\nc",
+			b:    "a\nx\nc",
+			want: []Line{{OpEqual, "a"}, {OpDelete, "b"}, {OpInsert, "x"}, {OpEqual, "c"}},
+		},
+		{
+			name: "插入和删除",
+			a:    "a\nb\nc\nd",
+			b:    "b\nc\ne\nd",
+			want: []Line{{OpDelete, "a"}, {OpEqual, "b"}, {OpEqual, "c"}, {OpInsert, "e"}, {OpEqual, "d"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Lines(tc.a, tc.b))
+		})
+	}
+}